@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"random-api-go/config"
+	"random-api-go/database"
+	"random-api-go/model"
+	"random-api-go/service"
+)
+
+// cliCommands 命令行子命令, 第一个参数为子命令名
+var cliCommands = map[string]func(args []string) error{
+	"admin":      runAdminCommand,
+	"endpoint":   runEndpointCommand,
+	"datasource": runDataSourceCommand,
+	"config":     runConfigCommand,
+	"domain":     runDomainCommand,
+	"export":     runExportCommand,
+	"import":     runImportCommand,
+	"db":         runDBCommand,
+}
+
+// cliUsage 子命令总览
+const cliUsage = `用法: random-api-go [子命令]
+不带子命令时启动服务。子命令直接操作数据目录中的数据库:
+  admin       本地管理员账号 (create / passwd / reset-totp / list)
+  endpoint    端点 (list / create / delete)
+  datasource  数据源 (sync)
+  config      系统配置 (get / set)
+  domain      referer 黑名单 (block / unblock)
+  export      导出配置文档
+  import      导入配置文档
+  db          数据库 (backup)`
+
+// runCLI 处理命令行子命令, 返回 false 表示不是子命令, 按正常方式启动服务
+// 子命令只加载配置与数据库, 不启动 HTTP 服务和数据预加载
+func runCLI(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		fmt.Fprintln(os.Stderr, cliUsage)
+		return true
+	}
+	command, ok := cliCommands[args[0]]
+	if !ok {
+		return false
+	}
+
+	if err := command(args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	return true
+}
+
+// adminUsage 本地管理员子命令说明
+const adminUsage = `用法:
+  random-api-go admin create -username <name> [-password <password>] [-role admin|editor|viewer] [-endpoints 1,2]
+  random-api-go admin passwd -username <name> [-password <password>]
+  random-api-go admin reset-totp -username <name>
+  random-api-go admin list
+
+未指定 -password 时依次读取环境变量 ADMIN_PASSWORD 与标准输入`
+
+// runAdminCommand 本地管理员账号管理
+func runAdminCommand(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, adminUsage)
+		return errors.New("missing admin subcommand")
+	}
+
+	fs := flag.NewFlagSet("admin "+args[0], flag.ContinueOnError)
+	username := fs.String("username", "", "本地管理员用户名")
+	password := fs.String("password", "", "密码 (至少 8 位)")
+	role := fs.String("role", service.RoleAdmin, "角色: admin / editor / viewer")
+	endpoints := fs.String("endpoints", "", "editor 可管理的端点 ID (逗号分隔), 为空表示全部")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if err := openCLIDatabase(); err != nil {
+		return err
+	}
+	defer database.Close()
+
+	switch args[0] {
+	case "create":
+		if *username == "" {
+			return errors.New("-username is required")
+		}
+		if _, err := service.FindLocalAdmin(*username); err == nil {
+			return fmt.Errorf("local admin %q already exists", *username)
+		}
+		pw, err := readCLIPassword(*password)
+		if err != nil {
+			return err
+		}
+		admin, err := service.CreateLocalAdmin(*username, pw, *role, *endpoints)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("已创建本地管理员 %s (id=%d, role=%s)\n", admin.Username, admin.ID, admin.Role)
+	case "passwd":
+		admin, err := findCLIAdmin(*username)
+		if err != nil {
+			return err
+		}
+		pw, err := readCLIPassword(*password)
+		if err != nil {
+			return err
+		}
+		if err := service.SetLocalAdminPassword(admin.ID, pw); err != nil {
+			return err
+		}
+		fmt.Printf("已重置 %s 的密码, 现有会话已注销\n", admin.Username)
+	case "reset-totp":
+		admin, err := findCLIAdmin(*username)
+		if err != nil {
+			return err
+		}
+		if err := service.DisableLocalAdminTOTP(admin.ID, "", false); err != nil {
+			return err
+		}
+		fmt.Printf("已关闭 %s 的二次验证\n", admin.Username)
+	case "list":
+		admins, err := service.ListLocalAdmins()
+		if err != nil {
+			return err
+		}
+		for _, a := range admins {
+			fmt.Printf("%d\t%s\trole=%s\ttotp=%t\tdisabled=%t\n", a.ID, a.Username, a.Role, a.TOTPEnabled, a.Disabled)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, adminUsage)
+		return fmt.Errorf("unknown admin subcommand %q", args[0])
+	}
+	return nil
+}
+
+// openCLIDatabase 加载配置并打开数据库, 不启动预加载器的定时刷新
+func openCLIDatabase() error {
+	if err := config.Load(); err != nil {
+		return err
+	}
+	service.DisablePreloaderAutoStart()
+	if err := database.Initialize(config.Get().Storage.DataDir); err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	return nil
+}
+
+// findCLIAdmin 按用户名查找本地管理员
+func findCLIAdmin(username string) (*model.LocalAdmin, error) {
+	if username == "" {
+		return nil, errors.New("-username is required")
+	}
+	admin, err := service.FindLocalAdmin(username)
+	if err != nil {
+		return nil, fmt.Errorf("local admin %q not found", username)
+	}
+	return admin, nil
+}
+
+// readCLIPassword 读取密码: 参数 > ADMIN_PASSWORD 环境变量 > 标准输入第一行
+func readCLIPassword(password string) (string, error) {
+	if password == "" {
+		password = os.Getenv("ADMIN_PASSWORD")
+	}
+	if password == "" {
+		fmt.Fprint(os.Stderr, "Password: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("failed to read password: %w", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+	if err := service.ValidateLocalAdminPassword(password); err != nil {
+		return "", err
+	}
+	return password, nil
+}
@@ -0,0 +1,480 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"random-api-go/config"
+	"random-api-go/database"
+	"random-api-go/model"
+	"random-api-go/service"
+
+	"gopkg.in/yaml.v3"
+)
+
+// manageUsage 数据管理子命令说明 (标志需写在位置参数之前)
+const manageUsage = `用法:
+  random-api-go endpoint list [-json]
+  random-api-go endpoint create -name <name> -url <url> [-description <text>] [-inactive] [-hidden]
+  random-api-go endpoint delete <id>
+  random-api-go datasource sync <id>
+  random-api-go config get [key]
+  random-api-go config set [-type string|json|number|boolean] <key> <value>
+  random-api-go domain block [-reason <text>] <domain>
+  random-api-go domain unblock <domain>
+  random-api-go export [-format json|yaml] [-secrets] [-o file]
+  random-api-go import [-mode merge|replace] [-dry-run] <file|->
+  random-api-go db backup [-o file]
+
+修改记入审计日志, 操作者为 system。修改后向运行中的服务 (数据目录中的 server.pid) 发送 SIGHUP,
+服务随即重新加载内存缓存; 无法通知时输出提示, 需手动发送 SIGHUP 或重启服务`
+
+// cliContext 命令行发起的变更使用的 context, 审计记录中操作者为 system
+func cliContext() context.Context {
+	return context.Background()
+}
+
+// parseCLIFlags 解析子命令标志, 要求恰好 n 个位置参数
+func parseCLIFlags(fs *flag.FlagSet, args []string, n int, names string) ([]string, error) {
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() != n {
+		fmt.Fprintln(os.Stderr, manageUsage)
+		return nil, fmt.Errorf("%s expects %s", fs.Name(), names)
+	}
+	return fs.Args(), nil
+}
+
+// parseCLIID 解析数字 ID
+func parseCLIID(s string) (uint, error) {
+	id, err := strconv.ParseUint(s, 10, 32)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("invalid id %q", s)
+	}
+	return uint(id), nil
+}
+
+// splitCLISubcommand 取出二级子命令, 缺失时输出用法
+func splitCLISubcommand(command string, args []string) (string, []string, error) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, manageUsage)
+		return "", nil, fmt.Errorf("missing %s subcommand", command)
+	}
+	return args[0], args[1:], nil
+}
+
+// runEndpointCommand 端点管理
+func runEndpointCommand(args []string) error {
+	sub, args, err := splitCLISubcommand("endpoint", args)
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("endpoint "+sub, flag.ContinueOnError)
+	switch sub {
+	case "list":
+		asJSON := fs.Bool("json", false, "以 JSON 输出 (含数据源与替换规则)")
+		if _, err := parseCLIFlags(fs, args, 0, "no arguments"); err != nil {
+			return err
+		}
+		if err := openCLIDatabase(); err != nil {
+			return err
+		}
+		defer database.Close()
+
+		endpoints, err := service.GetEndpointService().ListEndpoints()
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			return writeCLIJSON(os.Stdout, endpoints)
+		}
+		for _, e := range endpoints {
+			fmt.Printf("%d\t%s\t/%s\tactive=%t\tdata_sources=%d\tmanaged=%t\n",
+				e.ID, e.Name, strings.TrimPrefix(e.URL, "/"), e.IsActive, len(e.DataSources), e.Managed)
+		}
+	case "create":
+		name := fs.String("name", "", "端点名称")
+		url := fs.String("url", "", "端点路径, 如 pics")
+		description := fs.String("description", "", "描述")
+		inactive := fs.Bool("inactive", false, "创建为停用状态")
+		hidden := fs.Bool("hidden", false, "不在首页展示")
+		if _, err := parseCLIFlags(fs, args, 0, "no arguments"); err != nil {
+			return err
+		}
+		if *name == "" || *url == "" {
+			return errors.New("-name and -url are required")
+		}
+		if err := openCLIDatabase(); err != nil {
+			return err
+		}
+		defer database.Close()
+
+		endpoint := model.APIEndpoint{
+			Name:           *name,
+			URL:            *url,
+			Description:    *description,
+			IsActive:       !*inactive,
+			ShowOnHomepage: !*hidden,
+		}
+		endpointService := service.GetEndpointService()
+		if err := endpointService.CreateEndpoint(cliContext(), &endpoint); err != nil {
+			return err
+		}
+		// 字段默认值为 true, 创建时写不进 false, 需要再更新一次
+		if *inactive || *hidden {
+			endpoint.IsActive, endpoint.ShowOnHomepage = !*inactive, !*hidden
+			if err := endpointService.UpdateEndpoint(cliContext(), &endpoint); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("已创建端点 %s (id=%d, url=%s)\n", endpoint.Name, endpoint.ID, endpoint.URL)
+		notifyRunningServer()
+	case "delete":
+		positional, err := parseCLIFlags(fs, args, 1, "an endpoint id")
+		if err != nil {
+			return err
+		}
+		id, err := parseCLIID(positional[0])
+		if err != nil {
+			return err
+		}
+		if err := openCLIDatabase(); err != nil {
+			return err
+		}
+		defer database.Close()
+
+		endpointService := service.GetEndpointService()
+		endpoint, err := endpointService.GetEndpoint(id)
+		if err != nil {
+			return err
+		}
+		if err := service.CheckManagedEdit("endpoint", endpoint.URL, endpoint.Managed); err != nil {
+			return err
+		}
+		if err := endpointService.DeleteEndpoint(cliContext(), id); err != nil {
+			return err
+		}
+		fmt.Printf("已删除端点 %s (id=%d)\n", endpoint.Name, endpoint.ID)
+		notifyRunningServer()
+	default:
+		fmt.Fprintln(os.Stderr, manageUsage)
+		return fmt.Errorf("unknown endpoint subcommand %q", sub)
+	}
+	return nil
+}
+
+// runDataSourceCommand 数据源管理
+func runDataSourceCommand(args []string) error {
+	sub, args, err := splitCLISubcommand("datasource", args)
+	if err != nil {
+		return err
+	}
+	if sub != "sync" {
+		fmt.Fprintln(os.Stderr, manageUsage)
+		return fmt.Errorf("unknown datasource subcommand %q", sub)
+	}
+
+	fs := flag.NewFlagSet("datasource sync", flag.ContinueOnError)
+	positional, err := parseCLIFlags(fs, args, 1, "a data source id")
+	if err != nil {
+		return err
+	}
+	id, err := parseCLIID(positional[0])
+	if err != nil {
+		return err
+	}
+	if err := openCLIDatabase(); err != nil {
+		return err
+	}
+	defer database.Close()
+
+	var dataSource model.DataSource
+	if err := database.DB.First(&dataSource, id).Error; err != nil {
+		return fmt.Errorf("data source %d not found", id)
+	}
+	if err := service.GetEndpointService().RefreshDataSource(id); err != nil {
+		return fmt.Errorf("failed to sync data source: %w", err)
+	}
+	fmt.Printf("已同步数据源 %s (id=%d, type=%s)\n", dataSource.Name, dataSource.ID, dataSource.Type)
+	notifyRunningServer()
+	return nil
+}
+
+// runConfigCommand 系统配置读写
+func runConfigCommand(args []string) error {
+	sub, args, err := splitCLISubcommand("config", args)
+	if err != nil {
+		return err
+	}
+
+	fs := flag.NewFlagSet("config "+sub, flag.ContinueOnError)
+	switch sub {
+	case "get":
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if fs.NArg() > 1 {
+			return errors.New("config get expects at most one key")
+		}
+		if err := openCLIDatabase(); err != nil {
+			return err
+		}
+		defer database.Close()
+
+		if fs.NArg() == 1 {
+			cfg := cliConfigSnapshot(fs.Arg(0))
+			if cfg == nil {
+				return fmt.Errorf("config %q not found", fs.Arg(0))
+			}
+			fmt.Println(cfg.Value)
+			return nil
+		}
+		configs, err := database.ListConfigs()
+		if err != nil {
+			return err
+		}
+		for _, cfg := range configs {
+			fmt.Printf("%s\t%s\t%s\n", cfg.Key, cfg.Type, cfg.Value)
+		}
+	case "set":
+		configType := fs.String("type", "string", "配置类型: string / json / number / boolean")
+		positional, err := parseCLIFlags(fs, args, 2, "a key and a value")
+		if err != nil {
+			return err
+		}
+		key, value := positional[0], positional[1]
+		if err := openCLIDatabase(); err != nil {
+			return err
+		}
+		defer database.Close()
+
+		before := cliConfigSnapshot(key)
+		if before != nil {
+			if err := service.CheckManagedEdit("config", key, before.Managed); err != nil {
+				return err
+			}
+		}
+		if err := database.SetConfig(cliContext(), key, value, *configType); err != nil {
+			return err
+		}
+		action := service.AuditActionUpdate
+		if before == nil {
+			action = service.AuditActionCreate
+		}
+		service.RecordAudit(cliContext(), action, "config", key, before, cliConfigSnapshot(key))
+		fmt.Printf("已设置 %s\n", key)
+		notifyRunningServer()
+	default:
+		fmt.Fprintln(os.Stderr, manageUsage)
+		return fmt.Errorf("unknown config subcommand %q", sub)
+	}
+	return nil
+}
+
+// cliConfigSnapshot 读取配置项, 不存在时返回 nil
+func cliConfigSnapshot(key string) *model.Config {
+	var cfg model.Config
+	if err := database.DB.Where("key = ?", key).Limit(1).Find(&cfg).Error; err != nil || cfg.ID == 0 {
+		return nil
+	}
+	return &cfg
+}
+
+// runDomainCommand referer 域名拉黑与解除
+func runDomainCommand(args []string) error {
+	sub, args, err := splitCLISubcommand("domain", args)
+	if err != nil {
+		return err
+	}
+	if sub != "block" && sub != "unblock" {
+		fmt.Fprintln(os.Stderr, manageUsage)
+		return fmt.Errorf("unknown domain subcommand %q", sub)
+	}
+
+	fs := flag.NewFlagSet("domain "+sub, flag.ContinueOnError)
+	reason := fs.String("reason", "", "拉黑原因")
+	positional, err := parseCLIFlags(fs, args, 1, "a domain")
+	if err != nil {
+		return err
+	}
+	domain := strings.ToLower(strings.TrimSpace(positional[0]))
+	if domain == "" || domain == "direct" || domain == "unknown" {
+		return errors.New("direct/unknown cannot be blocked individually, use the referer policy instead")
+	}
+	if err := openCLIDatabase(); err != nil {
+		return err
+	}
+	defer database.Close()
+
+	var existing model.BlockedDomain
+	database.DB.Where("domain = ? AND match_type = ? AND path = ?", domain, service.BlockMatchExact, "").Limit(1).Find(&existing)
+	if err := service.CheckManagedEdit("blocked domain", domain, existing.Managed); err != nil {
+		return err
+	}
+	blocked := sub == "block"
+	if err := service.GetDomainStatsService().SetBlocked(domain, *reason, blocked); err != nil {
+		return err
+	}
+	service.RecordAudit(cliContext(), sub, "domain", domain, nil, map[string]interface{}{"reason": *reason})
+	if blocked {
+		fmt.Printf("已拉黑 %s\n", domain)
+	} else {
+		fmt.Printf("已解除 %s\n", domain)
+	}
+	notifyRunningServer()
+	return nil
+}
+
+// runExportCommand 导出配置文档, 默认输出到标准输出
+func runExportCommand(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "json", "文档格式: json / yaml")
+	secrets := fs.Bool("secrets", false, "导出敏感值原文 (默认以 ****** 代替)")
+	output := fs.String("o", "", "输出文件, 默认标准输出")
+	if _, err := parseCLIFlags(fs, args, 0, "no arguments"); err != nil {
+		return err
+	}
+	if *format != "json" && *format != "yaml" {
+		return errors.New("invalid format, supported formats: json, yaml")
+	}
+	if err := openCLIDatabase(); err != nil {
+		return err
+	}
+	defer database.Close()
+
+	doc, err := service.GetEndpointService().ExportConfig(*secrets)
+	if err != nil {
+		return fmt.Errorf("failed to export config: %w", err)
+	}
+	service.RecordAudit(cliContext(), "export", "configuration", *format, nil, map[string]interface{}{"secrets": *secrets})
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	if *format == "yaml" {
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(doc); err != nil {
+			return err
+		}
+		err = encoder.Close()
+	} else {
+		err = writeCLIJSON(w, doc)
+	}
+	if err == nil && *output != "" {
+		fmt.Fprintf(os.Stderr, "已导出到 %s\n", *output)
+	}
+	return err
+}
+
+// runImportCommand 导入配置文档, 输出导入计划; 文档有错误时不执行并返回错误
+func runImportCommand(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	mode := fs.String("mode", service.ImportModeMerge, "导入方式: merge / replace")
+	dryRun := fs.Bool("dry-run", false, "只输出变更, 不执行")
+	positional, err := parseCLIFlags(fs, args, 1, "a file path or - for stdin")
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	if positional[0] == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(positional[0])
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read document: %w", err)
+	}
+	doc, err := service.ParseConfigDocument(data)
+	if err != nil {
+		return err
+	}
+	if err := openCLIDatabase(); err != nil {
+		return err
+	}
+	defer database.Close()
+
+	plan, err := service.GetEndpointService().ImportConfig(cliContext(), doc, *mode, *dryRun)
+	if err != nil {
+		return err
+	}
+	for _, change := range plan.Changes {
+		fmt.Printf("%s\t%s\t%s\n", change.Action, change.Kind, change.Key)
+	}
+	for _, warning := range plan.Warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+	}
+	for _, planErr := range plan.Errors {
+		fmt.Fprintf(os.Stderr, "error: %s\n", planErr)
+	}
+	if len(plan.Errors) > 0 {
+		return errors.New("import document is invalid, nothing was applied")
+	}
+	state := "已执行"
+	if !plan.Applied {
+		state = "未执行 (dry-run)"
+	}
+	fmt.Printf("%s: 新增 %d, 更新 %d, 删除 %d, 未变化 %d\n",
+		state, plan.Summary.Create, plan.Summary.Update, plan.Summary.Delete, plan.Summary.Unchanged)
+	if plan.Applied && len(plan.Changes) > 0 {
+		notifyRunningServer()
+	}
+	return nil
+}
+
+// runDBCommand 数据库维护
+func runDBCommand(args []string) error {
+	sub, args, err := splitCLISubcommand("db", args)
+	if err != nil {
+		return err
+	}
+	if sub != "backup" {
+		fmt.Fprintln(os.Stderr, manageUsage)
+		return fmt.Errorf("unknown db subcommand %q", sub)
+	}
+
+	fs := flag.NewFlagSet("db backup", flag.ContinueOnError)
+	output := fs.String("o", "", "备份文件, 默认 {DATA_DIR}/backups/data-{时间}.db")
+	if _, err := parseCLIFlags(fs, args, 0, "no arguments"); err != nil {
+		return err
+	}
+	if err := openCLIDatabase(); err != nil {
+		return err
+	}
+	defer database.Close()
+
+	dest := *output
+	if dest == "" {
+		dest = filepath.Join(config.Get().Storage.DataDir, "backups", "data-"+time.Now().Format("20060102-150405")+".db")
+	}
+	if err := database.Backup(dest); err != nil {
+		return err
+	}
+	fmt.Printf("已备份到 %s\n", dest)
+	return nil
+}
+
+// writeCLIJSON 以缩进 JSON 输出
+func writeCLIJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
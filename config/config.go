@@ -22,15 +22,46 @@ type Config struct {
 	}
 
 	OAuth struct {
-		ClientID     string
-		ClientSecret string
+		Provider           string // 预设: czl / github / oidc
+		ProviderName       string // 登录按钮显示的名称, 为空时使用预设名称
+		ClientID           string
+		ClientSecret       string
+		Issuer             string // OIDC issuer, 设置后通过 discovery 获取端点
+		AuthorizeURL       string // 以下端点与声明映射为空时使用预设或 discovery 结果
+		TokenURL           string
+		UserInfoURL        string
+		Scopes             string
+		IDClaim            string
+		UsernameClaim      string
+		EmailClaim         string
+		EmailVerifiedClaim string // 邮箱是否已验证的声明, 为空时使用预设; none 表示信任提供方返回的邮箱
+		PKCE               string // true / false, 为空时使用预设 (discovery 声明支持 S256 时启用)
+	}
+
+	Admin struct {
+		Owner        string   // 初始管理员 (用户 ID / 用户名 / 邮箱), 始终允许登录
+		AllowedUsers []string // 允许登录管理后台的用户, 与数据库中的管理员名单合并
+		LocalLogin   bool     // 是否允许本地管理员账号密码登录
 	}
 
 	App struct {
 		BaseURL string
 	}
+
+	Security struct {
+		TrustedProxies []string // 可信代理 IP/CIDR, 只有来自这些地址的请求才采信转发头
+		ClientIPHeader string   // 可信代理写入的客户端 IP 头 (如 CF-Connecting-IP), 优先于 X-Forwarded-For
+	}
+
+	Declarative struct {
+		ConfigFile string // 声明式配置文件 (YAML / JSON), 启动时与收到 SIGHUP 时同步到数据库
+		DriftMode  string // 管理后台修改受管对象: reject 拒绝 / flag 允许并标记为漂移
+	}
 }
 
+// defaultTrustedProxies 默认只信任本机与内网地址
+const defaultTrustedProxies = "127.0.0.0/8,::1/128,10.0.0.0/8,172.16.0.0/12,192.168.0.0/16,fc00::/7"
+
 var (
 	cfg Config
 	RNG *rand.Rand
@@ -92,12 +123,37 @@ func Load() error {
 	cfg.Storage.DataDir = getEnv("DATA_DIR", "./data")
 
 	// OAuth配置
+	cfg.OAuth.Provider = strings.ToLower(getEnv("OAUTH_PROVIDER", "czl"))
+	cfg.OAuth.ProviderName = getEnv("OAUTH_PROVIDER_NAME", "")
 	cfg.OAuth.ClientID = getEnv("OAUTH_CLIENT_ID", "")
 	cfg.OAuth.ClientSecret = getEnv("OAUTH_CLIENT_SECRET", "")
+	cfg.OAuth.Issuer = getEnv("OAUTH_ISSUER", "")
+	cfg.OAuth.AuthorizeURL = getEnv("OAUTH_AUTHORIZE_URL", "")
+	cfg.OAuth.TokenURL = getEnv("OAUTH_TOKEN_URL", "")
+	cfg.OAuth.UserInfoURL = getEnv("OAUTH_USERINFO_URL", "")
+	cfg.OAuth.Scopes = getEnv("OAUTH_SCOPES", "")
+	cfg.OAuth.IDClaim = getEnv("OAUTH_ID_CLAIM", "")
+	cfg.OAuth.UsernameClaim = getEnv("OAUTH_USERNAME_CLAIM", "")
+	cfg.OAuth.EmailClaim = getEnv("OAUTH_EMAIL_CLAIM", "")
+	cfg.OAuth.EmailVerifiedClaim = getEnv("OAUTH_EMAIL_VERIFIED_CLAIM", "")
+	cfg.OAuth.PKCE = getEnv("OAUTH_PKCE", "")
+
+	// 管理员配置 (均未配置且数据库名单为空时, 任何 OAuth 用户都能登录)
+	cfg.Admin.Owner = strings.TrimSpace(getEnv("ADMIN_OWNER", ""))
+	cfg.Admin.AllowedUsers = getListEnv("ADMIN_ALLOWED_USERS", "")
+	cfg.Admin.LocalLogin = getEnv("ADMIN_LOCAL_LOGIN", "true") != "false"
 
 	// 应用配置
 	cfg.App.BaseURL = getEnv("BASE_URL", "http://localhost:5003")
 
+	// 安全配置 (TRUSTED_PROXIES=none 表示不信任任何代理, 始终使用连接地址)
+	cfg.Security.TrustedProxies = getListEnv("TRUSTED_PROXIES", defaultTrustedProxies)
+	cfg.Security.ClientIPHeader = getEnv("CLIENT_IP_HEADER", "")
+
+	// 声明式配置 (为空表示不使用, 数据库为唯一配置来源)
+	cfg.Declarative.ConfigFile = getEnv("CONFIG_FILE", "")
+	cfg.Declarative.DriftMode = strings.ToLower(getEnv("CONFIG_DRIFT_MODE", "reject"))
+
 	return nil
 }
 
@@ -127,6 +183,21 @@ func getIntEnv(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// getListEnv 获取逗号分隔的列表类型环境变量, 值为 none 时返回空列表
+func getListEnv(key, defaultValue string) []string {
+	value := getEnv(key, defaultValue)
+	if strings.EqualFold(strings.TrimSpace(value), "none") {
+		return nil
+	}
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // getDurationEnv 获取时间间隔类型的环境变量
 func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -83,17 +84,59 @@ func autoMigrate() error {
 		return err
 	}
 
+	// blocked_domains 的唯一键从 domain 扩展为 (domain, match_type, path)
+	if err := migrateBlockedDomainsRuleKey(); err != nil {
+		return err
+	}
+
+	// url_signers.ttl 去掉列默认值前, 清除 type_a/b/c 记录按默认值写入的 ttl
+	if err := migrateURLSignerTTL(); err != nil {
+		return err
+	}
+
 	return DB.AutoMigrate(
 		&model.APIEndpoint{},
 		&model.DataSource{},
 		&model.URLReplaceRule{},
+		&model.URLSigner{},
+		&model.EndpointPolicy{},
 		&model.Config{},
 		&model.DomainStats{},
 		&model.DailyDomainStats{},
 		&model.BlockedDomain{},
+		&model.AbuseEvent{},
+		&model.IPRule{},
+		&model.RateLimitPolicy{},
+		&model.APIKey{},
+		&model.DailyAPIKeyUsage{},
+		&model.DailyBotBlockStats{},
+		&model.AdminUser{},
+		&model.LocalAdmin{},
+		&model.AdminSession{},
+		&model.PersonalAccessToken{},
+		&model.AuditEvent{},
+		&model.EndpointRevision{},
 	)
 }
 
+// migrateURLSignerTTL 清除 type_a/b/c 签名配置上的 ttl
+// 旧表结构的 ttl 列默认值为 3600, 这三种算法的记录都被写入了 3600 (有效期实际由 CDN 侧配置);
+// 只在 ttl 列仍带默认值的旧表上执行, AutoMigrate 随后去掉默认值, 之后不再执行
+func migrateURLSignerTTL() error {
+	var legacy int64
+	if err := DB.Raw("SELECT COUNT(*) FROM pragma_table_info('url_signers') WHERE name='ttl' AND dflt_value IS NOT NULL").Scan(&legacy).Error; err != nil {
+		return err
+	}
+	if legacy == 0 {
+		return nil
+	}
+	log.Println("迁移 url_signers: 去掉 ttl 列默认值, 清除 type_a/b/c 签名配置的 ttl")
+	if err := DB.Exec("UPDATE url_signers SET ttl = 0 WHERE algorithm <> 'hmac_query'").Error; err != nil {
+		return fmt.Errorf("清除 url_signers 的 ttl 失败: %w", err)
+	}
+	return nil
+}
+
 // migrateDomainStatsAddPath 在引入 path 维度前清理旧的 domain 聚合表
 // 旧数据按 domain 唯一, 加 path 列后无法回填具体路径, 保留会让总数翻倍
 func migrateDomainStatsAddPath() error {
@@ -120,6 +163,15 @@ func migrateDomainStatsAddPath() error {
 	return nil
 }
 
+// migrateBlockedDomainsRuleKey 删除 blocked_domains 旧的 domain 单列唯一索引
+// 新索引由 AutoMigrate 按 (domain, match_type, path) 创建, 旧记录默认为 exact + 全部路径
+func migrateBlockedDomainsRuleKey() error {
+	if err := DB.Exec("DROP INDEX IF EXISTS idx_blocked_domains_domain").Error; err != nil {
+		return fmt.Errorf("删除 blocked_domains 旧唯一索引失败: %w", err)
+	}
+	return nil
+}
+
 // cleanupOldConstraints 清理旧的CHECK约束
 func cleanupOldConstraints() error {
 	// 检查data_sources表是否存在且包含CHECK约束
@@ -194,6 +246,66 @@ func cleanupOldConstraints() error {
 	return nil
 }
 
+// txContextKey context 中保存进行中事务的键
+type txContextKey struct{}
+
+// txState 进行中的事务及提交后要执行的操作
+type txState struct {
+	tx          *gorm.DB
+	afterCommit []func()
+}
+
+// txFromContext 获取 ctx 中进行中的事务, 没有时返回 nil
+func txFromContext(ctx context.Context) *txState {
+	if ctx == nil {
+		return nil
+	}
+	state, _ := ctx.Value(txContextKey{}).(*txState)
+	return state
+}
+
+// Conn 返回 ctx 中进行中的事务, 没有时返回 DB
+// SQLite 只使用一个连接, 事务期间的读写都必须经过 Conn(ctx), 直接使用 DB 会一直等待连接
+func Conn(ctx context.Context) *gorm.DB {
+	if state := txFromContext(ctx); state != nil {
+		return state.tx
+	}
+	return DB
+}
+
+// AfterCommit 登记事务提交后执行的操作 (刷新内存缓存等); ctx 中没有事务时立即执行, 事务回滚时丢弃
+func AfterCommit(ctx context.Context, fn func()) {
+	if state := txFromContext(ctx); state != nil {
+		state.afterCommit = append(state.afterCommit, fn)
+		return
+	}
+	fn()
+}
+
+// Transaction 在一个事务中执行 fn, fn 内通过 Conn(ctx) 访问数据库; 提交后依次执行 AfterCommit 登记的操作
+// ctx 中已有事务时直接在该事务中执行
+//
+// SQLite 只有一个连接, 事务持有它直到结束: fn 调用到的所有代码都不能再使用 DB, 包括 GetConfig 缓存未命中时的读取
+// 和服务单例首次创建时的加载, 否则会永远等待连接。调用方需要在开启事务前完成这些读取 (先创建用到的服务单例,
+// 配置项在事务外读出后传入), 并确认 fn 经过的服务方法都通过 Conn(ctx) 访问数据库、刷新缓存通过 AfterCommit 延后
+func Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if txFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+	state := &txState{}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		state.tx = tx
+		return fn(context.WithValue(ctx, txContextKey{}, state))
+	})
+	if err != nil {
+		return err
+	}
+	for _, f := range state.afterCommit {
+		f()
+	}
+	return nil
+}
+
 // Close 关闭数据库连接
 func Close() error {
 	if DB != nil {
@@ -206,6 +318,20 @@ func Close() error {
 	return nil
 }
 
+// Backup 将数据库在线备份到 dest (VACUUM INTO), 服务运行中也可以执行; dest 已存在时返回错误
+func Backup(dest string) error {
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("backup file %s already exists", dest)
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	if err := DB.Exec("VACUUM INTO ?", dest).Error; err != nil {
+		return fmt.Errorf("failed to backup database: %w", err)
+	}
+	return nil
+}
+
 // GetConfig 获取配置值（带缓存）
 func GetConfig(key string, defaultValue string) string {
 	// 先检查缓存
@@ -257,10 +383,11 @@ func cacheConfig(key, value string, ttl time.Duration) {
 	}
 }
 
-// SetConfig 设置配置值
-func SetConfig(key, value, configType string) error {
+// SetConfig 设置配置值 (在 ctx 的事务中执行时, 提交后才清理缓存)
+func SetConfig(ctx context.Context, key, value, configType string) error {
+	db := Conn(ctx)
 	var config model.Config
-	err := DB.Where("key = ?", key).First(&config).Error
+	err := db.Where("key = ?", key).First(&config).Error
 
 	if err == gorm.ErrRecordNotFound {
 		// 创建新配置
@@ -269,7 +396,7 @@ func SetConfig(key, value, configType string) error {
 			Value: value,
 			Type:  configType,
 		}
-		if err := DB.Create(&config).Error; err != nil {
+		if err := db.Create(&config).Error; err != nil {
 			return err
 		}
 	} else if err != nil {
@@ -278,13 +405,13 @@ func SetConfig(key, value, configType string) error {
 		// 更新现有配置
 		config.Value = value
 		config.Type = configType
-		if err := DB.Save(&config).Error; err != nil {
+		if err := db.Save(&config).Error; err != nil {
 			return err
 		}
 	}
 
 	// 清理缓存
-	invalidateConfigCache(key)
+	AfterCommit(ctx, func() { invalidateConfigCache(key) })
 	return nil
 }
 
@@ -296,6 +423,14 @@ func invalidateConfigCache(key string) {
 	log.Printf("已清理配置 %s 的缓存", key)
 }
 
+// ClearConfigCache 清理全部配置缓存 (其他进程修改了配置时使用)
+func ClearConfigCache() {
+	configCacheMutex.Lock()
+	defer configCacheMutex.Unlock()
+	configCache = make(map[string]*CachedConfig)
+	log.Println("已清理全部配置缓存")
+}
+
 // ListConfigs 列出所有配置
 func ListConfigs() ([]model.Config, error) {
 	var configs []model.Config
@@ -303,15 +438,15 @@ func ListConfigs() ([]model.Config, error) {
 	return configs, err
 }
 
-// DeleteConfig 删除配置
-func DeleteConfig(key string) error {
-	err := DB.Where("key = ?", key).Delete(&model.Config{}).Error
+// DeleteConfig 删除配置 (在 ctx 的事务中执行时, 提交后才清理缓存)
+func DeleteConfig(ctx context.Context, key string) error {
+	err := Conn(ctx).Where("key = ?", key).Delete(&model.Config{}).Error
 	if err != nil {
 		return err
 	}
 
 	// 清理缓存
-	invalidateConfigCache(key)
+	AfterCommit(ctx, func() { invalidateConfigCache(key) })
 	return nil
 }
 
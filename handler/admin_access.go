@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"net/http"
+
+	"random-api-go/database"
+	"random-api-go/middleware"
+	"random-api-go/model"
+	"random-api-go/service"
+)
+
+// requireRole 检查当前管理员是否具备指定角色, 不足时返回 403
+// 路由层已按角色拦截, 处理器内再次检查, 避免新路由漏配
+func requireRole(w http.ResponseWriter, r *http.Request, role string) bool {
+	if !middleware.GetAccess(r).HasRole(role) {
+		http.Error(w, "Insufficient role", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// requireEndpointAccess 检查当前管理员是否可以管理指定端点 (editor 受端点范围限制)
+func requireEndpointAccess(w http.ResponseWriter, r *http.Request, endpointID uint) bool {
+	if !middleware.GetAccess(r).CanAccessEndpoint(endpointID) {
+		http.Error(w, "No access to this endpoint", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// rejectManagedEdit 受声明式配置文件管理的对象在 reject 模式下不能在管理后台修改, 返回 true 表示已拒绝 (409)
+func rejectManagedEdit(w http.ResponseWriter, kind, key string, managed bool) bool {
+	if err := service.CheckManagedEdit(kind, key, managed); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return true
+	}
+	return false
+}
+
+// endpointManaged 端点是否受配置文件管理 (端点不存在时为 false)
+func endpointManaged(id uint) bool {
+	var endpoint model.APIEndpoint
+	database.DB.Select("id", "managed").Limit(1).Find(&endpoint, id)
+	return endpoint.Managed
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"net/http"
+
+	"random-api-go/database"
+	"random-api-go/model"
+	"random-api-go/service"
+)
+
+// auditUpdate 重新读取更新后的记录, 与更新前的记录比较后写入审计
+func auditUpdate[T any](r *http.Request, targetType string, id uint, before *T) {
+	var after T
+	if err := database.DB.First(&after, id).Error; err != nil {
+		return
+	}
+	service.RecordAudit(r.Context(), service.AuditActionUpdate, targetType, id, before, &after)
+}
+
+// configSnapshot 读取配置项用于审计比较, 不存在时返回 nil
+func configSnapshot(key string) *model.Config {
+	var cfg model.Config
+	if err := database.DB.Where("key = ?", key).Limit(1).Find(&cfg).Error; err != nil || cfg.ID == 0 {
+		return nil
+	}
+	return &cfg
+}
+
+// auditConfig 写入配置项变更审计 (before 为修改前的快照)
+func auditConfig(r *http.Request, key string, before *model.Config) {
+	after := configSnapshot(key)
+	action := service.AuditActionUpdate
+	switch {
+	case before == nil:
+		action = service.AuditActionCreate
+	case after == nil:
+		action = service.AuditActionDelete
+	}
+	service.RecordAudit(r.Context(), action, "config", key, before, after)
+}
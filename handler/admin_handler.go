@@ -2,6 +2,7 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -9,11 +10,14 @@ import (
 	"net/url"
 	"random-api-go/config"
 	"random-api-go/database"
+	"random-api-go/middleware"
 	"random-api-go/model"
 	"random-api-go/service"
 	"strconv"
 	"strings"
+	"time"
 
+	"gopkg.in/yaml.v3"
 	"gorm.io/gorm"
 )
 
@@ -31,6 +35,10 @@ func NewAdminHandler() *AdminHandler {
 
 // ListEndpoints 列出所有端点
 func (h *AdminHandler) ListEndpoints(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleEditor) {
+		return
+	}
+
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -42,6 +50,16 @@ func (h *AdminHandler) ListEndpoints(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 限定了端点范围的 editor 只能看到自己负责的端点
+	access := middleware.GetAccess(r)
+	visible := endpoints[:0]
+	for _, endpoint := range endpoints {
+		if access.CanAccessEndpoint(endpoint.ID) {
+			visible = append(visible, endpoint)
+		}
+	}
+	endpoints = visible
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
@@ -51,6 +69,10 @@ func (h *AdminHandler) ListEndpoints(w http.ResponseWriter, r *http.Request) {
 
 // CreateEndpoint 创建端点
 func (h *AdminHandler) CreateEndpoint(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -67,8 +89,10 @@ func (h *AdminHandler) CreateEndpoint(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Name and URL are required", http.StatusBadRequest)
 		return
 	}
+	// 受管标记只能由配置文件同步设置
+	endpoint.Managed = false
 
-	if err := h.endpointService.CreateEndpoint(&endpoint); err != nil {
+	if err := h.endpointService.CreateEndpoint(r.Context(), &endpoint); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to create endpoint: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -82,6 +106,10 @@ func (h *AdminHandler) CreateEndpoint(w http.ResponseWriter, r *http.Request) {
 
 // GetEndpoint 获取端点详情
 func (h *AdminHandler) GetEndpoint(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleEditor) {
+		return
+	}
+
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -93,6 +121,9 @@ func (h *AdminHandler) GetEndpoint(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid endpoint ID", http.StatusBadRequest)
 		return
 	}
+	if !requireEndpointAccess(w, r, uint(id)) {
+		return
+	}
 
 	endpoint, err := h.endpointService.GetEndpoint(uint(id))
 	if err != nil {
@@ -109,6 +140,10 @@ func (h *AdminHandler) GetEndpoint(w http.ResponseWriter, r *http.Request) {
 
 // UpdateEndpoint 更新端点
 func (h *AdminHandler) UpdateEndpoint(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
 	if r.Method != http.MethodPut {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -121,6 +156,10 @@ func (h *AdminHandler) UpdateEndpoint(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if rejectManagedEdit(w, "endpoint", idStr, endpointManaged(uint(id))) {
+		return
+	}
+
 	var endpoint model.APIEndpoint
 	if err := json.NewDecoder(r.Body).Decode(&endpoint); err != nil {
 		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
@@ -128,7 +167,7 @@ func (h *AdminHandler) UpdateEndpoint(w http.ResponseWriter, r *http.Request) {
 	}
 
 	endpoint.ID = uint(id)
-	if err := h.endpointService.UpdateEndpoint(&endpoint); err != nil {
+	if err := h.endpointService.UpdateEndpoint(r.Context(), &endpoint); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to update endpoint: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -142,6 +181,10 @@ func (h *AdminHandler) UpdateEndpoint(w http.ResponseWriter, r *http.Request) {
 
 // DeleteEndpoint 删除端点
 func (h *AdminHandler) DeleteEndpoint(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
 	if r.Method != http.MethodDelete {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -154,7 +197,11 @@ func (h *AdminHandler) DeleteEndpoint(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.endpointService.DeleteEndpoint(uint(id)); err != nil {
+	if rejectManagedEdit(w, "endpoint", idStr, endpointManaged(uint(id))) {
+		return
+	}
+
+	if err := h.endpointService.DeleteEndpoint(r.Context(), uint(id)); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to delete endpoint: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -168,6 +215,10 @@ func (h *AdminHandler) DeleteEndpoint(w http.ResponseWriter, r *http.Request) {
 
 // CreateDataSource 创建数据源
 func (h *AdminHandler) CreateDataSource(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleEditor) {
+		return
+	}
+
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -184,9 +235,16 @@ func (h *AdminHandler) CreateDataSource(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Name, Type and Config are required", http.StatusBadRequest)
 		return
 	}
+	if !requireEndpointAccess(w, r, dataSource.EndpointID) {
+		return
+	}
+	if rejectManagedEdit(w, "endpoint", strconv.Itoa(int(dataSource.EndpointID)), endpointManaged(dataSource.EndpointID)) {
+		return
+	}
+	dataSource.Managed = false
 
 	// 使用服务创建数据源（会自动预加载）
-	if err := h.endpointService.CreateDataSource(&dataSource); err != nil {
+	if err := h.endpointService.CreateDataSource(r.Context(), &dataSource); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to create data source: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -212,6 +270,10 @@ func (h *AdminHandler) HandleEndpointDataSources(w http.ResponseWriter, r *http.
 
 // ListEndpointDataSources 列出指定端点的数据源
 func (h *AdminHandler) ListEndpointDataSources(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleEditor) {
+		return
+	}
+
 	// 从URL路径中提取端点ID
 	path := r.URL.Path
 	// 路径格式: /api/admin/endpoints/{id}/data-sources
@@ -227,6 +289,9 @@ func (h *AdminHandler) ListEndpointDataSources(w http.ResponseWriter, r *http.Re
 		http.Error(w, "Invalid endpoint ID", http.StatusBadRequest)
 		return
 	}
+	if !requireEndpointAccess(w, r, uint(endpointID)) {
+		return
+	}
 
 	var dataSources []model.DataSource
 	if err := database.DB.Where("endpoint_id = ?", endpointID).Order("created_at DESC").Find(&dataSources).Error; err != nil {
@@ -243,6 +308,10 @@ func (h *AdminHandler) ListEndpointDataSources(w http.ResponseWriter, r *http.Re
 
 // CreateEndpointDataSource 为指定端点创建数据源
 func (h *AdminHandler) CreateEndpointDataSource(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleEditor) {
+		return
+	}
+
 	// 从URL路径中提取端点ID
 	path := r.URL.Path
 	// 路径格式: /api/admin/endpoints/{id}/data-sources
@@ -258,6 +327,9 @@ func (h *AdminHandler) CreateEndpointDataSource(w http.ResponseWriter, r *http.R
 		http.Error(w, "Invalid endpoint ID", http.StatusBadRequest)
 		return
 	}
+	if !requireEndpointAccess(w, r, uint(endpointID)) {
+		return
+	}
 
 	var dataSource model.DataSource
 	if err := json.NewDecoder(r.Body).Decode(&dataSource); err != nil {
@@ -284,9 +356,13 @@ func (h *AdminHandler) CreateEndpointDataSource(w http.ResponseWriter, r *http.R
 		http.Error(w, fmt.Sprintf("Failed to verify endpoint: %v", err), http.StatusInternalServerError)
 		return
 	}
+	if rejectManagedEdit(w, "endpoint", endpoint.URL, endpoint.Managed) {
+		return
+	}
+	dataSource.Managed = false
 
 	// 使用服务创建数据源（会自动预加载）
-	if err := h.endpointService.CreateDataSource(&dataSource); err != nil {
+	if err := h.endpointService.CreateDataSource(r.Context(), &dataSource); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to create data source: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -314,6 +390,10 @@ func (h *AdminHandler) HandleDataSourceByID(w http.ResponseWriter, r *http.Reque
 
 // GetDataSource 获取数据源详情
 func (h *AdminHandler) GetDataSource(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleEditor) {
+		return
+	}
+
 	// 从URL路径中提取数据源ID
 	path := r.URL.Path
 	// 路径格式: /api/admin/data-sources/{id}
@@ -339,6 +419,9 @@ func (h *AdminHandler) GetDataSource(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("Failed to get data source: %v", err), http.StatusInternalServerError)
 		return
 	}
+	if !requireEndpointAccess(w, r, dataSource.EndpointID) {
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -349,6 +432,10 @@ func (h *AdminHandler) GetDataSource(w http.ResponseWriter, r *http.Request) {
 
 // UpdateDataSource 更新数据源
 func (h *AdminHandler) UpdateDataSource(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleEditor) {
+		return
+	}
+
 	// 从URL路径中提取数据源ID
 	path := r.URL.Path
 	// 路径格式: /api/admin/data-sources/{id}
@@ -374,6 +461,12 @@ func (h *AdminHandler) UpdateDataSource(w http.ResponseWriter, r *http.Request)
 		http.Error(w, fmt.Sprintf("Failed to get data source: %v", err), http.StatusInternalServerError)
 		return
 	}
+	if !requireEndpointAccess(w, r, dataSource.EndpointID) {
+		return
+	}
+	if rejectManagedEdit(w, "data source", dataSource.Name, dataSource.Managed) {
+		return
+	}
 
 	var updateData model.DataSource
 	if err := json.NewDecoder(r.Body).Decode(&updateData); err != nil {
@@ -395,7 +488,7 @@ func (h *AdminHandler) UpdateDataSource(w http.ResponseWriter, r *http.Request)
 	dataSource.IsActive = updateData.IsActive
 
 	// 使用服务更新数据源（会自动预加载）
-	if err := h.endpointService.UpdateDataSource(&dataSource); err != nil {
+	if err := h.endpointService.UpdateDataSource(r.Context(), &dataSource); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to update data source: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -409,6 +502,10 @@ func (h *AdminHandler) UpdateDataSource(w http.ResponseWriter, r *http.Request)
 
 // DeleteDataSource 删除数据源
 func (h *AdminHandler) DeleteDataSource(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleEditor) {
+		return
+	}
+
 	// 从URL路径中提取数据源ID
 	path := r.URL.Path
 	// 路径格式: /api/admin/data-sources/{id}
@@ -425,8 +522,24 @@ func (h *AdminHandler) DeleteDataSource(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	var dataSource model.DataSource
+	if err := database.DB.First(&dataSource, dataSourceID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			http.Error(w, "Data source not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to get data source: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if !requireEndpointAccess(w, r, dataSource.EndpointID) {
+		return
+	}
+	if rejectManagedEdit(w, "data source", dataSource.Name, dataSource.Managed) {
+		return
+	}
+
 	// 使用服务删除数据源
-	if err := h.endpointService.DeleteDataSource(uint(dataSourceID)); err != nil {
+	if err := h.endpointService.DeleteDataSource(r.Context(), uint(dataSourceID)); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to delete data source: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -440,6 +553,10 @@ func (h *AdminHandler) DeleteDataSource(w http.ResponseWriter, r *http.Request)
 
 // SyncDataSource 同步数据源
 func (h *AdminHandler) SyncDataSource(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleEditor) {
+		return
+	}
+
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -470,6 +587,9 @@ func (h *AdminHandler) SyncDataSource(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("Failed to get data source: %v", err), http.StatusInternalServerError)
 		return
 	}
+	if !requireEndpointAccess(w, r, dataSource.EndpointID) {
+		return
+	}
 
 	// 使用服务刷新数据源
 	if err := h.endpointService.RefreshDataSource(uint(dataSourceID)); err != nil {
@@ -493,13 +613,17 @@ func (h *AdminHandler) SyncDataSource(w http.ResponseWriter, r *http.Request) {
 
 // ListURLReplaceRules 列出URL替换规则
 func (h *AdminHandler) ListURLReplaceRules(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	var rules []model.URLReplaceRule
-	if err := database.DB.Preload("Endpoint").Order("created_at DESC").Find(&rules).Error; err != nil {
+	if err := database.DB.Preload("Endpoint").Order("priority ASC, id ASC").Find(&rules).Error; err != nil {
 		http.Error(w, fmt.Sprintf("Failed to query URL replace rules: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -513,6 +637,10 @@ func (h *AdminHandler) ListURLReplaceRules(w http.ResponseWriter, r *http.Reques
 
 // CreateURLReplaceRule 创建URL替换规则
 func (h *AdminHandler) CreateURLReplaceRule(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -530,8 +658,17 @@ func (h *AdminHandler) CreateURLReplaceRule(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// 使用GORM创建URL替换规则
-	if err := database.DB.Create(&rule).Error; err != nil {
+	if err := service.ValidateURLReplaceRule(&rule); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid URL replace rule: %v", err), http.StatusBadRequest)
+		return
+	}
+	if rule.EndpointID != nil && rejectManagedEdit(w, "endpoint", strconv.Itoa(int(*rule.EndpointID)), endpointManaged(*rule.EndpointID)) {
+		return
+	}
+	rule.Managed = false
+
+	// 通过服务创建，同时刷新规则缓存
+	if err := h.endpointService.CreateURLReplaceRule(r.Context(), &rule); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to create URL replace rule: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -545,6 +682,10 @@ func (h *AdminHandler) CreateURLReplaceRule(w http.ResponseWriter, r *http.Reque
 
 // HandleURLReplaceRuleByID 处理URL替换规则的更新和删除操作
 func (h *AdminHandler) HandleURLReplaceRuleByID(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
 	// 从URL路径中提取规则ID
 	path := r.URL.Path
 	// 路径格式: /api/admin/url-replace-rules/{id}
@@ -585,6 +726,11 @@ func (h *AdminHandler) updateURLReplaceRule(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if err := service.ValidateURLReplaceRule(&rule); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid URL replace rule: %v", err), http.StatusBadRequest)
+		return
+	}
+
 	// 检查规则是否存在
 	var existingRule model.URLReplaceRule
 	if err := database.DB.First(&existingRule, ruleID).Error; err != nil {
@@ -595,10 +741,17 @@ func (h *AdminHandler) updateURLReplaceRule(w http.ResponseWriter, r *http.Reque
 		http.Error(w, fmt.Sprintf("Failed to get URL replace rule: %v", err), http.StatusInternalServerError)
 		return
 	}
+	if rejectManagedEdit(w, "URL replace rule", existingRule.Name, existingRule.Managed) {
+		return
+	}
+	if rule.EndpointID != nil && rejectManagedEdit(w, "endpoint", strconv.Itoa(int(*rule.EndpointID)), endpointManaged(*rule.EndpointID)) {
+		return
+	}
 
 	// 更新规则
 	rule.ID = ruleID
-	if err := database.DB.Save(&rule).Error; err != nil {
+	rule.Managed = existingRule.Managed
+	if err := h.endpointService.UpdateURLReplaceRule(r.Context(), &rule); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to update URL replace rule: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -622,9 +775,12 @@ func (h *AdminHandler) deleteURLReplaceRule(w http.ResponseWriter, r *http.Reque
 		http.Error(w, fmt.Sprintf("Failed to get URL replace rule: %v", err), http.StatusInternalServerError)
 		return
 	}
+	if rejectManagedEdit(w, "URL replace rule", rule.Name, rule.Managed) {
+		return
+	}
 
 	// 删除规则
-	if err := database.DB.Delete(&rule, ruleID).Error; err != nil {
+	if err := h.endpointService.DeleteURLReplaceRule(r.Context(), ruleID); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to delete URL replace rule: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -636,272 +792,526 @@ func (h *AdminHandler) deleteURLReplaceRule(w http.ResponseWriter, r *http.Reque
 	})
 }
 
-// GetHomePageConfig 获取首页配置
-func (h *AdminHandler) GetHomePageConfig(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// TestURLReplaceRules 用示例URL调试替换链 (POST /api/admin/url-replace-rules/test, body: {url, endpoint_id})
+// endpoint_id 省略时只执行全局规则
+func (h *AdminHandler) TestURLReplaceRules(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleEditor) {
 		return
 	}
 
-	content := database.GetConfig("homepage_content", "")
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"data":    map[string]string{"content": content},
-	})
-}
-
-// UpdateHomePageConfig 更新首页配置
-func (h *AdminHandler) UpdateHomePageConfig(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost && r.Method != http.MethodPut {
+	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var requestData struct {
-		Content string `json:"content"`
+	var req struct {
+		URL        string `json:"url"`
+		EndpointID uint   `json:"endpoint_id"`
 	}
-
-	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
 		return
 	}
+	if req.URL == "" {
+		http.Error(w, "url is required", http.StatusBadRequest)
+		return
+	}
 
-	// 设置首页配置
-	if err := database.SetConfig("homepage_content", requestData.Content, "string"); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to update home page config: %v", err), http.StatusInternalServerError)
+	result, steps, err := h.endpointService.TraceURLReplaceRules(req.URL, req.EndpointID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to test URL replace rules: %v", err), http.StatusNotFound)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
-		"message": "Home page config updated successfully",
+		"data": map[string]interface{}{
+			"input":  req.URL,
+			"output": result,
+			"steps":  steps,
+		},
 	})
 }
 
-// GetOAuthConfig 获取OAuth配置
-func (h *AdminHandler) GetOAuthConfig(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// HandleEndpointPolicy 处理端点访问策略 (GET/PUT /api/admin/endpoints/{id}/policy)
+func (h *AdminHandler) HandleEndpointPolicy(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
 		return
 	}
 
-	cfg := config.Get()
+	// 路径格式: /api/admin/endpoints/{id}/policy
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) < 5 {
+		http.Error(w, "Invalid endpoint ID", http.StatusBadRequest)
+		return
+	}
+
+	endpointID, err := strconv.ParseUint(parts[4], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid endpoint ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.endpointService.GetEndpoint(uint(endpointID)); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get endpoint: %v", err), http.StatusNotFound)
+		return
+	}
+
+	policyService := service.GetEndpointPolicyService()
+
+	switch r.Method {
+	case http.MethodGet:
+		policy, err := policyService.GetPolicy(uint(endpointID))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to get endpoint policy: %v", err), http.StatusInternalServerError)
+			return
+		}
 
-	// 检查OAuth配置是否完整
-	if cfg.OAuth.ClientID == "" || cfg.OAuth.ClientSecret == "" {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": false,
-			"error":   "OAuth配置未设置，请检查环境变量OAUTH_CLIENT_ID和OAUTH_CLIENT_SECRET",
+			"success": true,
+			"data":    policy,
 		})
-		return
-	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"data": map[string]string{
-			"client_id": cfg.OAuth.ClientID,
-			"base_url":  cfg.App.BaseURL,
-			// 不返回client_secret，出于安全考虑
-		},
-	})
-}
+	case http.MethodPut:
+		// 未传的字段沿用默认值
+		policy := service.DefaultEndpointPolicy(uint(endpointID))
+		if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+			return
+		}
+		policy.EndpointID = uint(endpointID)
 
-// VerifyOAuthToken 验证OAuth令牌
-func (h *AdminHandler) VerifyOAuthToken(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
+		if policy.RefererFallbackURL != "" {
+			if u, err := url.Parse(policy.RefererFallbackURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				http.Error(w, "referer_fallback_url must be an http(s) URL", http.StatusBadRequest)
+				return
+			}
+		}
+
+		before, _ := policyService.GetPolicy(uint(endpointID))
+		if err := policyService.SavePolicy(&policy); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to save endpoint policy: %v", err), http.StatusBadRequest)
+			return
+		}
+		service.RecordAudit(r.Context(), service.AuditActionUpdate, "endpoint_policy", endpointID, before, &policy)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"data":    policy,
+		})
+
+	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
 	}
+}
 
-	var request struct {
-		Code        string `json:"code"`
-		RedirectURI string `json:"redirect_uri,omitempty"`
+// HandleEndpointRevisions 处理端点配置版本
+// GET  /api/admin/endpoints/{id}/revisions                  版本列表
+// GET  /api/admin/endpoints/{id}/revisions/{rev}            版本快照
+// GET  /api/admin/endpoints/{id}/revisions/diff?from=&to=   比较两个版本 (to 省略时为最新版本)
+// POST /api/admin/endpoints/{id}/revisions/{rev}/restore    恢复到指定版本
+func (h *AdminHandler) HandleEndpointRevisions(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleEditor) {
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+	// 路径格式: /api/admin/endpoints/{id}/revisions[/...]
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 5 {
+		http.Error(w, "Invalid endpoint ID", http.StatusBadRequest)
 		return
 	}
-
-	if request.Code == "" {
-		http.Error(w, "Authorization code is required", http.StatusBadRequest)
+	endpointID, err := strconv.ParseUint(parts[3], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid endpoint ID", http.StatusBadRequest)
 		return
 	}
-
-	// 如果没有提供redirect_uri，使用默认值
-	redirectURI := request.RedirectURI
-	if redirectURI == "" {
-		// 从请求头中获取Origin，构建redirect_uri
-		origin := r.Header.Get("Origin")
-		if origin == "" {
-			origin = "http://localhost:3000" // 默认值
-		}
-		redirectURI = origin + "/admin/callback"
+	if !requireEndpointAccess(w, r, uint(endpointID)) {
+		return
 	}
+	rest := parts[5:]
 
-	cfg := config.Get()
-
-	// 使用授权码换取访问令牌
-	tokenResp, err := h.exchangeCodeForToken(request.Code, cfg.OAuth.ClientID, cfg.OAuth.ClientSecret, redirectURI)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to exchange code for token: %v", err), http.StatusUnauthorized)
+	var data interface{}
+	switch {
+	case len(rest) == 0 && r.Method == http.MethodGet:
+		data, err = h.endpointService.ListRevisions(uint(endpointID))
+	case len(rest) == 1 && rest[0] == "diff" && r.Method == http.MethodGet:
+		from, _ := strconv.Atoi(r.URL.Query().Get("from"))
+		to, _ := strconv.Atoi(r.URL.Query().Get("to"))
+		if from <= 0 {
+			http.Error(w, "from is required", http.StatusBadRequest)
+			return
+		}
+		data, err = h.endpointService.DiffRevisions(uint(endpointID), from, to)
+	case len(rest) == 1 && r.Method == http.MethodGet:
+		revision, convErr := strconv.Atoi(rest[0])
+		if convErr != nil {
+			http.Error(w, "Invalid revision", http.StatusBadRequest)
+			return
+		}
+		data, err = h.endpointService.GetRevision(uint(endpointID), revision)
+	case len(rest) == 2 && rest[1] == "restore":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		// 恢复会修改端点本身, 与更新端点一样需要 admin
+		if !requireRole(w, r, middleware.RoleAdmin) {
+			return
+		}
+		revision, convErr := strconv.Atoi(rest[0])
+		if convErr != nil {
+			http.Error(w, "Invalid revision", http.StatusBadRequest)
+			return
+		}
+		if rejectManagedEdit(w, "endpoint", strconv.FormatUint(endpointID, 10), endpointManaged(uint(endpointID))) {
+			return
+		}
+		data, err = h.endpointService.RestoreRevision(r.Context(), uint(endpointID), revision)
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
 		return
 	}
 
-	// 验证令牌并获取用户信息
-	userInfo, err := h.getUserInfo(tokenResp.AccessToken)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get user info: %v", err), http.StatusUnauthorized)
+		if errors.Is(err, service.ErrRevisionNotFound) || errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to handle revision request: %v", err), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
-		"data": map[string]interface{}{
-			"access_token": tokenResp.AccessToken,
-			"user_info":    userInfo,
-		},
+		"data":    data,
 	})
 }
 
-// TokenResponse OAuth令牌响应结构
-type TokenResponse struct {
-	AccessToken  string `json:"access_token"`
-	TokenType    string `json:"token_type"`
-	ExpiresIn    int    `json:"expires_in"`
-	RefreshToken string `json:"refresh_token"`
-}
-
-// UserInfo 用户信息结构
-type UserInfo struct {
-	ID       int    `json:"id"` // CZL Connect返回的是数字ID
-	Username string `json:"username"`
-	Nickname string `json:"nickname"`
-	Email    string `json:"email"`
-	Avatar   string `json:"avatar"`
-}
-
-// exchangeCodeForToken 使用授权码换取访问令牌
-func (h *AdminHandler) exchangeCodeForToken(code, clientID, clientSecret, redirectURI string) (*TokenResponse, error) {
-	// 检查必要的OAuth配置
-	if clientID == "" || clientSecret == "" {
-		return nil, fmt.Errorf("OAuth配置缺失: client_id=%s, client_secret=%s", clientID, clientSecret)
+// ListURLSigners 列出CDN URL签名配置
+func (h *AdminHandler) ListURLSigners(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
 	}
 
-	// 记录调试信息（不包含敏感信息）
-	log.Printf("OAuth token exchange: client_id=%s, redirect_uri=%s", clientID, redirectURI)
-
-	// 尝试方法1：使用Basic Auth进行客户端认证
-	tokenResp, err := h.tryTokenExchangeWithBasicAuth(code, clientID, clientSecret, redirectURI)
-	if err == nil {
-		return tokenResp, nil
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
-	log.Printf("Basic Auth failed, trying with client credentials in body: %v", err)
-
-	// 尝试方法2：在请求体中发送client credentials
-	return h.tryTokenExchangeWithBodyAuth(code, clientID, clientSecret, redirectURI)
-}
-
-// tryTokenExchangeWithBasicAuth 使用Basic Auth进行token交换
-func (h *AdminHandler) tryTokenExchangeWithBasicAuth(code, clientID, clientSecret, redirectURI string) (*TokenResponse, error) {
-	data := url.Values{}
-	data.Set("grant_type", "authorization_code")
-	data.Set("code", code)
-	data.Set("redirect_uri", redirectURI)
-
-	req, err := http.NewRequest("POST", "https://connect.czl.net/api/oauth2/token", strings.NewReader(data.Encode()))
+	signers, err := h.endpointService.ListURLSigners()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to query URL signers: %v", err), http.StatusInternalServerError)
+		return
 	}
-
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.SetBasicAuth(clientID, clientSecret)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("HTTP request failed: %v", err)
+	// 密钥只在创建时返回
+	for i := range signers {
+		service.MaskURLSignerSecret(&signers[i])
 	}
-	defer resp.Body.Close()
 
-	return h.parseTokenResponse(resp, "Basic Auth")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    signers,
+	})
 }
 
-// tryTokenExchangeWithBodyAuth 在请求体中发送client credentials
-func (h *AdminHandler) tryTokenExchangeWithBodyAuth(code, clientID, clientSecret, redirectURI string) (*TokenResponse, error) {
-	data := url.Values{}
-	data.Set("grant_type", "authorization_code")
-	data.Set("code", code)
-	data.Set("client_id", clientID)
-	data.Set("client_secret", clientSecret)
-	data.Set("redirect_uri", redirectURI)
-
-	resp, err := http.Post("https://connect.czl.net/api/oauth2/token",
-		"application/x-www-form-urlencoded",
-		strings.NewReader(data.Encode()))
-	if err != nil {
-		return nil, fmt.Errorf("HTTP request failed: %v", err)
+// CreateURLSigner 创建CDN URL签名配置
+func (h *AdminHandler) CreateURLSigner(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
 	}
-	defer resp.Body.Close()
 
-	return h.parseTokenResponse(resp, "Body Auth")
-}
-
-// parseTokenResponse 解析token响应
-func (h *AdminHandler) parseTokenResponse(resp *http.Response, method string) (*TokenResponse, error) {
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %v", err)
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
-	log.Printf("OAuth token response (%s): status=%d, body_length=%d", method, resp.StatusCode, len(body))
-
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("OAuth token exchange failed (%s): status=%d, response=%s", method, resp.StatusCode, string(body))
-		return nil, fmt.Errorf("token request failed with status: %d, body: %s", resp.StatusCode, string(body))
+	var signer model.URLSigner
+	if err := json.NewDecoder(r.Body).Decode(&signer); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
 	}
 
-	var tokenResp TokenResponse
-	if err := json.Unmarshal(body, &tokenResp); err != nil {
-		return nil, fmt.Errorf("failed to parse token response: %v, body: %s", err, string(body))
+	// 验证必填字段
+	if signer.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
 	}
 
-	return &tokenResp, nil
-}
+	if err := service.ValidateURLSigner(&signer); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid URL signer: %v", err), http.StatusBadRequest)
+		return
+	}
 
-// getUserInfo 获取用户信息
-func (h *AdminHandler) getUserInfo(accessToken string) (*UserInfo, error) {
-	req, err := http.NewRequest("GET", "https://connect.czl.net/api/oauth2/userinfo", nil)
-	if err != nil {
-		return nil, err
+	if err := h.endpointService.CreateURLSigner(r.Context(), &signer); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create URL signer: %v", err), http.StatusInternalServerError)
+		return
 	}
 
-	req.Header.Set("Authorization", "Bearer "+accessToken)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    signer,
+	})
+}
+
+// HandleURLSignerByID 处理CDN URL签名配置的更新和删除操作
+func (h *AdminHandler) HandleURLSignerByID(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	// 路径格式: /api/admin/url-signers/{id}
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) < 5 {
+		http.Error(w, "Invalid signer ID", http.StatusBadRequest)
+		return
+	}
+
+	signerID, err := strconv.Atoi(parts[4])
 	if err != nil {
-		return nil, err
+		http.Error(w, "Invalid signer ID", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		h.updateURLSigner(w, r, uint(signerID))
+	case http.MethodDelete:
+		h.deleteURLSigner(w, r, uint(signerID))
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// updateURLSigner 更新CDN URL签名配置
+func (h *AdminHandler) updateURLSigner(w http.ResponseWriter, r *http.Request, signerID uint) {
+	var signer model.URLSigner
+	if err := json.NewDecoder(r.Body).Decode(&signer); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if signer.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+
+	var existing model.URLSigner
+	if err := database.DB.First(&existing, signerID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			http.Error(w, "URL signer not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to get URL signer: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// 未填写或提交隐藏后的密钥时沿用原密钥
+	if signer.Secret == "" || service.IsMaskedURLSignerSecret(signer.Secret) {
+		signer.Secret = existing.Secret
+	}
+
+	if err := service.ValidateURLSigner(&signer); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid URL signer: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	signer.ID = signerID
+	signer.CreatedAt = existing.CreatedAt
+	if err := h.endpointService.UpdateURLSigner(r.Context(), &signer); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to update URL signer: %v", err), http.StatusInternalServerError)
+		return
+	}
+	service.MaskURLSignerSecret(&signer)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    signer,
+	})
+}
+
+// deleteURLSigner 删除CDN URL签名配置
+func (h *AdminHandler) deleteURLSigner(w http.ResponseWriter, r *http.Request, signerID uint) {
+	var signer model.URLSigner
+	if err := database.DB.First(&signer, signerID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			http.Error(w, "URL signer not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to get URL signer: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.endpointService.DeleteURLSigner(r.Context(), signerID); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to delete URL signer: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "URL signer deleted successfully",
+	})
+}
+
+// GetHomePageConfig 获取首页配置
+func (h *AdminHandler) GetHomePageConfig(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	content := database.GetConfig("homepage_content", "")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    map[string]string{"content": content},
+	})
+}
+
+// UpdateHomePageConfig 更新首页配置
+func (h *AdminHandler) UpdateHomePageConfig(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
+	if r.Method != http.MethodPost && r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var requestData struct {
+		Content string `json:"content"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// 设置首页配置
+	before := configSnapshot("homepage_content")
+	if before != nil && rejectManagedEdit(w, "config", before.Key, before.Managed) {
+		return
+	}
+	if err := database.SetConfig(r.Context(), "homepage_content", requestData.Content, "string"); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to update home page config: %v", err), http.StatusInternalServerError)
+		return
+	}
+	auditConfig(r, "homepage_content", before)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Home page config updated successfully",
+	})
+}
+
+// GetOAuthConfig 获取登录配置 (OAuth 提供方与本地账号登录是否可用)
+func (h *AdminHandler) GetOAuthConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cfg := config.Get()
+	localLogin := cfg.Admin.LocalLogin && service.HasLocalAdmins()
+
+	// 检查OAuth配置是否完整 (启用 PKCE 的公共客户端可以不配置 client_secret)
+	if cfg.OAuth.ClientID == "" {
+		w.Header().Set("Content-Type", "application/json")
+		if localLogin {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": true,
+				"data": map[string]interface{}{
+					"oauth_enabled": false,
+					"local_login":   true,
+				},
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   "OAuth配置未设置，请检查环境变量OAUTH_CLIENT_ID和OAUTH_CLIENT_SECRET，或使用命令行创建本地管理员",
+		})
+		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("userinfo request failed with status: %d", resp.StatusCode)
+	provider, err := service.GetOAuthProvider()
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		if localLogin {
+			// 提供方不可用时仍可使用本地账号登录
+			log.Printf("OAuth provider unavailable, falling back to local login: %v", err)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": true,
+				"data": map[string]interface{}{
+					"oauth_enabled": false,
+					"local_login":   true,
+					"oauth_error":   err.Error(),
+				},
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("OAuth提供方配置错误: %v", err),
+		})
+		return
 	}
 
-	var userInfo UserInfo
-	if err := json.NewDecoder(resp.Body).Decode(&userInfo); err != nil {
-		return nil, err
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"client_id":     cfg.OAuth.ClientID,
+			"base_url":      cfg.App.BaseURL,
+			"provider":      cfg.OAuth.Provider,
+			"provider_name": provider.Name,
+			"login_url":     "/api/admin/oauth/login",
+			"oauth_enabled": true,
+			"local_login":   localLogin,
+			// 不返回client_secret，出于安全考虑
+		},
+	})
+}
+
+// HandleOAuthLogin 发起登录: 生成 state 与 PKCE 参数后跳转到提供方授权页
+func (h *AdminHandler) HandleOAuthLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if service.GetAdminUserService().IsOpen() {
+		http.Error(w, service.ErrAdminAllowlistEmpty.Error(), http.StatusForbidden)
+		return
 	}
 
-	return &userInfo, nil
+	authURL, state, err := service.StartOAuthLogin(middleware.GetRealIP(r))
+	if errors.Is(err, service.ErrTooManyOAuthLogins) {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	if err != nil {
+		log.Printf("OAuth login failed to start: %v", err)
+		http.Redirect(w, r, "/admin?error="+url.QueryEscape("oauth_config_error"), http.StatusFound)
+		return
+	}
+	// state 绑定到发起登录的浏览器, 回调时核对
+	middleware.SetOAuthStateCookie(w, r, state)
+	http.Redirect(w, r, authURL, http.StatusFound)
 }
 
 // HandleEndpoints 处理端点列表相关请求
@@ -942,9 +1352,13 @@ func (h *AdminHandler) HandleOAuthCallback(w http.ResponseWriter, r *http.Reques
 	state := r.URL.Query().Get("state")
 	errorParam := r.URL.Query().Get("error")
 
+	// state cookie 只用于本次回调
+	stateBound := middleware.OAuthStateMatches(r, state)
+	middleware.ClearOAuthStateCookie(w, r)
+
 	if errorParam != "" {
 		// OAuth授权失败，重定向到前端错误页面
-		http.Redirect(w, r, fmt.Sprintf("/admin?error=%s", errorParam), http.StatusFound)
+		http.Redirect(w, r, "/admin?error="+url.QueryEscape(errorParam), http.StatusFound)
 		return
 	}
 
@@ -954,44 +1368,51 @@ func (h *AdminHandler) HandleOAuthCallback(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// 注意：在实际应用中，应该验证state参数防止CSRF攻击
-	// 这里我们记录state但不强制验证，因为前端state存储在localStorage中
-	log.Printf("OAuth callback: code received, state=%s", state)
-
-	cfg := config.Get()
-
-	// 使用配置的BASE_URL构建回调地址
-	redirectURI := fmt.Sprintf("%s/api/admin/oauth/callback", cfg.App.BaseURL)
-	log.Printf("OAuth callback redirect_uri: %s (from BASE_URL: %s)", redirectURI, cfg.App.BaseURL)
-
-	// 使用授权码换取访问令牌
-	tokenResp, err := h.exchangeCodeForToken(code, cfg.OAuth.ClientID, cfg.OAuth.ClientSecret, redirectURI)
+	// state 必须来自本浏览器通过 /api/admin/oauth/login 发起的登录 (一次性, 10 分钟内有效), 防止 CSRF
+	if !stateBound {
+		log.Printf("OAuth callback rejected: state does not match the browser that started the login")
+		http.Redirect(w, r, "/admin?error=login_failed", http.StatusFound)
+		return
+	}
+	// 错误信息可能包含提供方令牌接口的响应内容, 只记录在服务端日志, 不放进跳转地址
+	_, userInfo, err := service.CompleteOAuthLogin(r.Context(), state, code)
 	if err != nil {
-		// 令牌交换失败，重定向到前端错误页面
-		http.Redirect(w, r, fmt.Sprintf("/admin?error=token_exchange_failed&details=%s", err.Error()), http.StatusFound)
+		log.Printf("OAuth callback failed: %v", err)
+		http.Redirect(w, r, "/admin?error=login_failed", http.StatusFound)
 		return
 	}
 
-	// 验证令牌并获取用户信息
-	userInfo, err := h.getUserInfo(tokenResp.AccessToken)
-	if err != nil {
-		// 获取用户信息失败，重定向到前端错误页面
-		http.Redirect(w, r, fmt.Sprintf("/admin?error=userinfo_failed&details=%s", err.Error()), http.StatusFound)
+	// 不在管理员名单中的用户不发放令牌; 名单为空时拒绝所有 OAuth 用户
+	adminService := service.GetAdminUserService()
+	if adminService.IsOpen() {
+		log.Printf("OAuth login rejected: user %s (id=%s), admin allowlist is empty", userInfo.Username, userInfo.ID)
+		http.Redirect(w, r, "/admin?error=admin_allowlist_empty", http.StatusFound)
+		return
+	}
+	if !adminService.IsAllowed(userInfo.Identity()) {
+		log.Printf("OAuth login rejected: user %s (id=%s) is not an admin", userInfo.Username, userInfo.ID)
+		http.Redirect(w, r, "/admin?error=access_denied", http.StatusFound)
 		return
 	}
 
-	// 成功获取令牌和用户信息，重定向到前端并传递token
-	// 注意：在生产环境中，应该使用更安全的方式传递token，比如设置HttpOnly cookie
-	redirectURL := fmt.Sprintf("/admin?token=%s&user=%s&state=%s",
-		tokenResp.AccessToken,
-		userInfo.Username,
-		state)
+	token, session, err := service.CreateAdminSession(userInfo, nil, middleware.GetRealIP(r), r.UserAgent())
+	if err != nil {
+		log.Printf("OAuth callback failed to create session: %v", err)
+		http.Redirect(w, r, "/admin?error=login_failed", http.StatusFound)
+		return
+	}
 
-	http.Redirect(w, r, redirectURL, http.StatusFound)
+	// 会话令牌只写入 HttpOnly cookie, 不出现在 URL 与浏览器历史中
+	middleware.SetSessionCookie(w, r, token, session.ExpiresAt)
+	http.Redirect(w, r, "/admin", http.StatusFound)
 }
 
 // UpdateEndpointSortOrder 更新端点排序
 func (h *AdminHandler) UpdateEndpointSortOrder(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
 	if r.Method != http.MethodPut {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -1009,6 +1430,15 @@ func (h *AdminHandler) UpdateEndpointSortOrder(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	// 受管端点的排序由配置文件决定
+	for _, order := range request.EndpointOrders {
+		var endpoint model.APIEndpoint
+		database.DB.Select("id", "managed", "sort_order").Limit(1).Find(&endpoint, order.ID)
+		if endpoint.SortOrder != order.SortOrder && rejectManagedEdit(w, "endpoint", strconv.Itoa(int(order.ID)), endpoint.Managed) {
+			return
+		}
+	}
+
 	// 批量更新排序
 	for _, order := range request.EndpointOrders {
 		if err := database.DB.Model(&model.APIEndpoint{}).
@@ -1018,6 +1448,7 @@ func (h *AdminHandler) UpdateEndpointSortOrder(w http.ResponseWriter, r *http.Re
 			return
 		}
 	}
+	service.RecordAudit(r.Context(), "reorder", "endpoint", "", nil, map[string]interface{}{"endpoint_orders": request.EndpointOrders})
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -1028,6 +1459,10 @@ func (h *AdminHandler) UpdateEndpointSortOrder(w http.ResponseWriter, r *http.Re
 
 // ListConfigs 列出所有配置
 func (h *AdminHandler) ListConfigs(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -1038,6 +1473,10 @@ func (h *AdminHandler) ListConfigs(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("Failed to list configs: %v", err), http.StatusInternalServerError)
 		return
 	}
+	// 敏感配置只返回脱敏后的值, 与导出一致
+	for i := range configs {
+		configs[i].Value = service.MaskConfigValue(configs[i].Key, configs[i].Value)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -1048,6 +1487,10 @@ func (h *AdminHandler) ListConfigs(w http.ResponseWriter, r *http.Request) {
 
 // CreateOrUpdateConfig 创建或更新配置
 func (h *AdminHandler) CreateOrUpdateConfig(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
 	if r.Method != http.MethodPost && r.Method != http.MethodPut {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -1073,10 +1516,19 @@ func (h *AdminHandler) CreateOrUpdateConfig(w http.ResponseWriter, r *http.Reque
 		requestData.Type = "string"
 	}
 
-	if err := database.SetConfig(requestData.Key, requestData.Value, requestData.Type); err != nil {
+	before := configSnapshot(requestData.Key)
+	if before != nil && rejectManagedEdit(w, "config", before.Key, before.Managed) {
+		return
+	}
+	// 列表中的敏感配置是脱敏值, 原样提交时保留原值
+	if before != nil && service.IsMaskedConfigValue(requestData.Key, requestData.Value) {
+		requestData.Value = before.Value
+	}
+	if err := database.SetConfig(r.Context(), requestData.Key, requestData.Value, requestData.Type); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to set config: %v", err), http.StatusInternalServerError)
 		return
 	}
+	auditConfig(r, requestData.Key, before)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -1087,6 +1539,10 @@ func (h *AdminHandler) CreateOrUpdateConfig(w http.ResponseWriter, r *http.Reque
 
 // DeleteConfigByKey 删除配置
 func (h *AdminHandler) DeleteConfigByKey(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
 	if r.Method != http.MethodDelete {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -1112,10 +1568,15 @@ func (h *AdminHandler) DeleteConfigByKey(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := database.DeleteConfig(key); err != nil {
+	before := configSnapshot(key)
+	if before != nil && rejectManagedEdit(w, "config", before.Key, before.Managed) {
+		return
+	}
+	if err := database.DeleteConfig(r.Context(), key); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to delete config: %v", err), http.StatusInternalServerError)
 		return
 	}
+	auditConfig(r, key, before)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -1126,6 +1587,10 @@ func (h *AdminHandler) DeleteConfigByKey(w http.ResponseWriter, r *http.Request)
 
 // GetDomainStats 获取域名访问统计 (按 24h / 7d / 30d / total 四个维度返回排行)
 func (h *AdminHandler) GetDomainStats(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleViewer) {
+		return
+	}
+
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -1168,6 +1633,10 @@ func (h *AdminHandler) GetDomainStats(w http.ResponseWriter, r *http.Request) {
 
 // GetDomainPathStats 返回某个域名下的 path 调用排行 (?domain=xxx&range=24h|7d|30d|total)
 func (h *AdminHandler) GetDomainPathStats(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleViewer) {
+		return
+	}
+
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -1200,6 +1669,10 @@ func (h *AdminHandler) GetDomainPathStats(w http.ResponseWriter, r *http.Request
 
 // UpdateDomainBlockStatus 切换某个域名的禁用状态 (PUT /api/admin/domain-stats/block, body: {domain, blocked, reason})
 func (h *AdminHandler) UpdateDomainBlockStatus(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
 	if r.Method != http.MethodPut && r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -1221,10 +1694,20 @@ func (h *AdminHandler) UpdateDomainBlockStatus(w http.ResponseWriter, r *http.Re
 		http.Error(w, "direct/unknown 不支持单独禁用, 请使用 referer 策略开关", http.StatusBadRequest)
 		return
 	}
+	var existing model.BlockedDomain
+	database.DB.Where("domain = ? AND match_type = ? AND path = ?", strings.ToLower(req.Domain), service.BlockMatchExact, "").Limit(1).Find(&existing)
+	if rejectManagedEdit(w, "blocked domain", existing.Domain, existing.Managed) {
+		return
+	}
 	if err := service.GetDomainStatsService().SetBlocked(req.Domain, req.Reason, req.Blocked); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to update block status: %v", err), http.StatusInternalServerError)
 		return
 	}
+	action := "unblock"
+	if req.Blocked {
+		action = "block"
+	}
+	service.RecordAudit(r.Context(), action, "domain", strings.ToLower(req.Domain), nil, map[string]interface{}{"reason": req.Reason})
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
@@ -1234,6 +1717,10 @@ func (h *AdminHandler) UpdateDomainBlockStatus(w http.ResponseWriter, r *http.Re
 
 // GetDomainTrend 返回最近 N 天每日总访问量 (?days=30, 默认 30, 最多 90)
 func (h *AdminHandler) GetDomainTrend(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleViewer) {
+		return
+	}
+
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -1262,20 +1749,1595 @@ func (h *AdminHandler) GetDomainTrend(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// ListBlockedDomains 列出所有被禁用的域名
-func (h *AdminHandler) ListBlockedDomains(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+// CreateBlockedDomain 新增黑名单规则 (POST /api/admin/blocked-domains, body: {domain, match_type, path, reason})
+// match_type: exact / suffix / etld1 / regex, 省略时 *.spam.com 视为 suffix
+func (h *AdminHandler) CreateBlockedDomain(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
+	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	rows, err := service.GetDomainStatsService().ListBlockedDomains()
+	var rule model.BlockedDomain
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := service.NormalizeBlockedDomain(&rule); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid block rule: %v", err), http.StatusBadRequest)
+		return
+	}
+	var existing model.BlockedDomain
+	database.DB.Where("domain = ? AND match_type = ? AND path = ?", rule.Domain, rule.MatchType, rule.Path).Limit(1).Find(&existing)
+	if rejectManagedEdit(w, "blocked domain", rule.Domain, existing.Managed) {
+		return
+	}
+	rule.Managed = false
+	if err := service.GetDomainStatsService().CreateBlockRule(r.Context(), &rule); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create block rule: %v", err), http.StatusInternalServerError)
+		return
+	}
+	service.RecordAudit(r.Context(), service.AuditActionCreate, "blocked_domain", rule.ID, nil, &rule)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    rule,
+	})
+}
+
+// DeleteBlockedDomain 删除黑名单规则 (DELETE /api/admin/blocked-domains/{id})
+func (h *AdminHandler) DeleteBlockedDomain(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	idStr := strings.TrimPrefix(r.URL.Path, "/api/admin/blocked-domains/")
+	id, err := strconv.ParseUint(idStr, 10, 32)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to list blocked domains: %v", err), http.StatusInternalServerError)
+		http.Error(w, "Invalid rule ID", http.StatusBadRequest)
+		return
+	}
+	var before model.BlockedDomain
+	database.DB.Limit(1).Find(&before, id)
+	if rejectManagedEdit(w, "blocked domain", before.Domain, before.Managed) {
+		return
+	}
+	if err := service.GetDomainStatsService().DeleteBlockRule(r.Context(), uint(id)); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			http.Error(w, "Block rule not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to delete block rule: %v", err), http.StatusInternalServerError)
 		return
 	}
+	service.RecordAudit(r.Context(), service.AuditActionDelete, "blocked_domain", id, &before, nil)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
-		"data":    rows,
+		"message": "Block rule deleted successfully",
 	})
 }
+
+// ListAbuseEvents 列出滥用检测事件 (GET /api/admin/abuse-events?domain=&limit=100)
+func (h *AdminHandler) ListAbuseEvents(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleViewer) {
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	events, err := service.GetDomainStatsService().ListAbuseEvents(r.URL.Query().Get("domain"), limit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to list abuse events: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    events,
+	})
+}
+
+// ListAuditEvents 分页查询管理操作审计记录
+// GET /api/admin/audit?actor=&action=&target_type=&target_id=&since=&until=&page=1&page_size=50
+// since / until 支持 RFC3339 或 2006-01-02
+func (h *AdminHandler) ListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := service.AuditFilter{
+		Actor:      query.Get("actor"),
+		Action:     query.Get("action"),
+		TargetType: query.Get("target_type"),
+		TargetID:   query.Get("target_id"),
+	}
+	filter.Page, _ = strconv.Atoi(query.Get("page"))
+	filter.PageSize, _ = strconv.Atoi(query.Get("page_size"))
+	for name, dest := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			if t, err = time.ParseInLocation("2006-01-02", value, time.Local); err != nil {
+				http.Error(w, fmt.Sprintf("Invalid %s: use RFC3339 or YYYY-MM-DD", name), http.StatusBadRequest)
+				return
+			}
+		}
+		*dest = t
+	}
+
+	events, total, err := service.ListAuditEvents(filter)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to list audit events: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"events": events,
+			"total":  total,
+		},
+	})
+}
+
+// ExportConfig 导出完整配置 (GET /api/admin/export?format=json|yaml&secrets=true)
+// 默认不含敏感值, 以 ****** 代替, 导入时保留目标环境中的原值
+func (h *AdminHandler) ExportConfig(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "yaml" {
+		http.Error(w, "Invalid format, supported formats: json, yaml", http.StatusBadRequest)
+		return
+	}
+	includeSecrets := r.URL.Query().Get("secrets") == "true"
+
+	doc, err := h.endpointService.ExportConfig(includeSecrets)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to export config: %v", err), http.StatusInternalServerError)
+		return
+	}
+	service.RecordAudit(r.Context(), "export", "configuration", format, nil, map[string]interface{}{"secrets": includeSecrets})
+
+	filename := fmt.Sprintf("random-api-config-%s.%s", doc.ExportedAt.Format("20060102-150405"), format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if format == "yaml" {
+		w.Header().Set("Content-Type", "application/yaml; charset=utf-8")
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		encoder.Encode(doc)
+		encoder.Close()
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(doc)
+}
+
+// ImportConfig 导入配置文档 (POST /api/admin/import?mode=merge|replace&dry_run=true, body 为 JSON 或 YAML)
+// 返回导入计划; 计划有错误时不会执行, 响应状态码为 422
+func (h *AdminHandler) ImportConfig(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 10<<20))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read body: %v", err), http.StatusBadRequest)
+		return
+	}
+	doc, err := service.ParseConfigDocument(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	dryRun := query.Get("dry_run") == "true"
+	plan, err := h.endpointService.ImportConfig(r.Context(), doc, query.Get("mode"), dryRun)
+	if err != nil {
+		if plan == nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to import config: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if len(plan.Errors) > 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   "Import document is invalid",
+			"data":    plan,
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    plan,
+	})
+}
+
+// GetConfigFileStatus 声明式配置文件状态 (GET /api/admin/config-file), drift 为数据库与文件的差异
+func (h *AdminHandler) GetConfigFileStatus(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    h.endpointService.GetConfigFileStatus(),
+	})
+}
+
+// ReconcileConfigFile 立即同步声明式配置文件 (POST /api/admin/config-file/reconcile), 与发送 SIGHUP 效果相同
+func (h *AdminHandler) ReconcileConfigFile(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !service.ConfigFileEnabled() {
+		http.Error(w, "CONFIG_FILE is not configured", http.StatusBadRequest)
+		return
+	}
+
+	plan, err := h.endpointService.ReconcileConfigFile()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to reconcile config file: %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    plan,
+	})
+}
+
+// GetBotBlockStats 机器人拦截统计 (GET /api/admin/bot-stats?days=7)
+func (h *AdminHandler) GetBotBlockStats(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleViewer) {
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	days, _ := strconv.Atoi(r.URL.Query().Get("days"))
+	rows, err := service.GetBotBlockStats(days)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to query bot block stats: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    rows,
+	})
+}
+
+// ListBlockedDomains 列出所有被禁用的域名
+func (h *AdminHandler) ListBlockedDomains(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleViewer) {
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rows, err := service.GetDomainStatsService().ListBlockedDomains()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to list blocked domains: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    rows,
+	})
+}
+
+// ListIPRules 列出IP规则
+func (h *AdminHandler) ListIPRules(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rules, err := service.GetIPRuleService().ListRules()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to query IP rules: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    rules,
+	})
+}
+
+// CreateIPRule 创建IP规则
+func (h *AdminHandler) CreateIPRule(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var rule model.IPRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := service.ValidateIPRule(&rule); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid IP rule: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := service.GetIPRuleService().CreateRule(&rule); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create IP rule: %v", err), http.StatusInternalServerError)
+		return
+	}
+	service.RecordAudit(r.Context(), service.AuditActionCreate, "ip_rule", rule.ID, nil, &rule)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    rule,
+	})
+}
+
+// HandleIPRuleByID 处理IP规则的更新和删除操作
+func (h *AdminHandler) HandleIPRuleByID(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
+	// 路径格式: /api/admin/ip-rules/{id}
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) < 5 {
+		http.Error(w, "Invalid rule ID", http.StatusBadRequest)
+		return
+	}
+
+	ruleID, err := strconv.Atoi(parts[4])
+	if err != nil {
+		http.Error(w, "Invalid rule ID", http.StatusBadRequest)
+		return
+	}
+
+	var existing model.IPRule
+	if err := database.DB.First(&existing, ruleID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			http.Error(w, "IP rule not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to get IP rule: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		var rule model.IPRule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := service.ValidateIPRule(&rule); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid IP rule: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		rule.ID = existing.ID
+		rule.CreatedAt = existing.CreatedAt
+		if err := service.GetIPRuleService().UpdateRule(&rule); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to update IP rule: %v", err), http.StatusInternalServerError)
+			return
+		}
+		auditUpdate(r, "ip_rule", existing.ID, &existing)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"data":    rule,
+		})
+	case http.MethodDelete:
+		if err := service.GetIPRuleService().DeleteRule(existing.ID); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to delete IP rule: %v", err), http.StatusInternalServerError)
+			return
+		}
+		service.RecordAudit(r.Context(), service.AuditActionDelete, "ip_rule", existing.ID, &existing, nil)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "IP rule deleted successfully",
+		})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ListRateLimitPolicies 列出限流策略
+func (h *AdminHandler) ListRateLimitPolicies(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	policies, err := service.GetRateLimitService().ListPolicies()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to query rate limit policies: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    policies,
+	})
+}
+
+// CreateRateLimitPolicy 创建限流策略
+func (h *AdminHandler) CreateRateLimitPolicy(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var policy model.RateLimitPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if policy.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := service.ValidateRateLimitPolicy(&policy); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid rate limit policy: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := service.GetRateLimitService().CreatePolicy(&policy); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create rate limit policy: %v", err), http.StatusInternalServerError)
+		return
+	}
+	service.RecordAudit(r.Context(), service.AuditActionCreate, "rate_limit_policy", policy.ID, nil, &policy)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    policy,
+	})
+}
+
+// HandleRateLimitPolicyByID 处理限流策略的更新和删除操作
+func (h *AdminHandler) HandleRateLimitPolicyByID(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
+	// 路径格式: /api/admin/rate-limits/{id}
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) < 5 {
+		http.Error(w, "Invalid policy ID", http.StatusBadRequest)
+		return
+	}
+
+	policyID, err := strconv.Atoi(parts[4])
+	if err != nil {
+		http.Error(w, "Invalid policy ID", http.StatusBadRequest)
+		return
+	}
+
+	var existing model.RateLimitPolicy
+	if err := database.DB.First(&existing, policyID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			http.Error(w, "Rate limit policy not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to get rate limit policy: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		var policy model.RateLimitPolicy
+		if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+			return
+		}
+		if policy.Name == "" {
+			http.Error(w, "Name is required", http.StatusBadRequest)
+			return
+		}
+		if err := service.ValidateRateLimitPolicy(&policy); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid rate limit policy: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		policy.ID = existing.ID
+		policy.CreatedAt = existing.CreatedAt
+		if err := service.GetRateLimitService().UpdatePolicy(&policy); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to update rate limit policy: %v", err), http.StatusInternalServerError)
+			return
+		}
+		auditUpdate(r, "rate_limit_policy", existing.ID, &existing)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"data":    policy,
+		})
+	case http.MethodDelete:
+		if err := service.GetRateLimitService().DeletePolicy(existing.ID); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to delete rate limit policy: %v", err), http.StatusInternalServerError)
+			return
+		}
+		service.RecordAudit(r.Context(), service.AuditActionDelete, "rate_limit_policy", existing.ID, &existing, nil)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Rate limit policy deleted successfully",
+		})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ListAPIKeys 列出API Key (不含明文)
+func (h *AdminHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	keys, err := service.GetAPIKeyService().ListKeys()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to query API keys: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    keys,
+	})
+}
+
+// CreateAPIKey 创建API Key, 明文只在响应中返回一次
+func (h *AdminHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var key model.APIKey
+	if err := json.NewDecoder(r.Body).Decode(&key); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := service.ValidateAPIKey(&key); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid API key: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	raw, err := service.GetAPIKeyService().CreateKey(&key)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create API key: %v", err), http.StatusInternalServerError)
+		return
+	}
+	service.RecordAudit(r.Context(), service.AuditActionCreate, "api_key", key.ID, nil, &key)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    key,
+		"key":     raw,
+	})
+}
+
+// HandleAPIKeyByID 处理API Key的更新、删除与用量查询
+func (h *AdminHandler) HandleAPIKeyByID(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
+	// 路径格式: /api/admin/api-keys/{id} 或 /api/admin/api-keys/{id}/usage
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) < 5 {
+		http.Error(w, "Invalid API key ID", http.StatusBadRequest)
+		return
+	}
+
+	keyID, err := strconv.Atoi(parts[4])
+	if err != nil {
+		http.Error(w, "Invalid API key ID", http.StatusBadRequest)
+		return
+	}
+
+	var existing model.APIKey
+	if err := database.DB.First(&existing, keyID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			http.Error(w, "API key not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to get API key: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if len(parts) > 5 && parts[5] == "usage" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		days, _ := strconv.Atoi(r.URL.Query().Get("days"))
+		usage, err := service.GetAPIKeyService().GetUsage(existing.ID, days)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to query API key usage: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"data":    usage,
+		})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		var key model.APIKey
+		if err := json.NewDecoder(r.Body).Decode(&key); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := service.ValidateAPIKey(&key); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid API key: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		key.ID = existing.ID
+		if err := service.GetAPIKeyService().UpdateKey(&key); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to update API key: %v", err), http.StatusInternalServerError)
+			return
+		}
+		auditUpdate(r, "api_key", existing.ID, &existing)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"data":    key,
+		})
+	case http.MethodDelete:
+		if err := service.GetAPIKeyService().DeleteKey(existing.ID); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to delete API key: %v", err), http.StatusInternalServerError)
+			return
+		}
+		service.RecordAudit(r.Context(), service.AuditActionDelete, "api_key", existing.ID, &existing, nil)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "API key deleted successfully",
+		})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// CreateSignedLink 为端点生成带过期时间的签名链接
+func (h *AdminHandler) CreateSignedLink(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req service.SignedLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if req.EndpointID == 0 {
+		http.Error(w, "endpoint_id is required", http.StatusBadRequest)
+		return
+	}
+
+	link, err := h.endpointService.MintSignedLink(&req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create signed link: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    link,
+	})
+}
+
+// ListAdminUsers 列出管理员名单 (数据库名单 + 环境变量配置)
+func (h *AdminHandler) ListAdminUsers(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	adminService := service.GetAdminUserService()
+	users, err := adminService.ListUsers()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to query admin users: %v", err), http.StatusInternalServerError)
+		return
+	}
+	owner, envUsers := adminService.EnvIdentifiers()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"users":     users,
+			"owner":     owner,
+			"env_users": envUsers,
+			"open":      adminService.IsOpen(),
+		},
+	})
+}
+
+// CreateAdminUser 添加管理员
+func (h *AdminHandler) CreateAdminUser(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var user model.AdminUser
+	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := service.ValidateAdminUser(&user); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid admin user: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	actor := middleware.GetUserInfo(r)
+	user.ID = 0
+	user.CreatedBy = actor.Username
+	if err := service.GetAdminUserService().CreateUser(&user, actor.Identity()); err != nil {
+		writeAdminUserError(w, "create", err)
+		return
+	}
+	service.RecordAudit(r.Context(), service.AuditActionCreate, "admin_user", user.ID, nil, &user)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    user,
+	})
+}
+
+// HandleAdminUserByID 处理管理员名单项的更新和删除操作
+func (h *AdminHandler) HandleAdminUserByID(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
+	// 路径格式: /api/admin/users/{id}
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) < 5 {
+		http.Error(w, "Invalid admin user ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(parts[4])
+	if err != nil {
+		http.Error(w, "Invalid admin user ID", http.StatusBadRequest)
+		return
+	}
+
+	var existing model.AdminUser
+	if err := database.DB.First(&existing, userID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			http.Error(w, "Admin user not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to get admin user: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	actor := middleware.GetUserInfo(r)
+	switch r.Method {
+	case http.MethodPut:
+		var user model.AdminUser
+		if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := service.ValidateAdminUser(&user); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid admin user: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		user.ID = existing.ID
+		user.CreatedBy = existing.CreatedBy
+		user.CreatedAt = existing.CreatedAt
+		if err := service.GetAdminUserService().UpdateUser(&user, actor.Identity()); err != nil {
+			writeAdminUserError(w, "update", err)
+			return
+		}
+		auditUpdate(r, "admin_user", existing.ID, &existing)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"data":    user,
+		})
+	case http.MethodDelete:
+		if err := service.GetAdminUserService().DeleteUser(existing.ID, actor.Identity()); err != nil {
+			writeAdminUserError(w, "delete", err)
+			return
+		}
+		service.RecordAudit(r.Context(), service.AuditActionDelete, "admin_user", existing.ID, &existing, nil)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Admin user deleted successfully",
+		})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeAdminUserError 输出管理员名单操作错误, 会导致自己失去权限的修改返回 409
+func writeAdminUserError(w http.ResponseWriter, action string, err error) {
+	if errors.Is(err, service.ErrAdminLockout) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	http.Error(w, fmt.Sprintf("Failed to %s admin user: %v", action, err), http.StatusInternalServerError)
+}
+
+// LocalAdminLogin 本地管理员账号密码登录 (支持二次验证码)
+func (h *AdminHandler) LocalAdminLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !config.Get().Admin.LocalLogin {
+		http.Error(w, "Local login is disabled", http.StatusForbidden)
+		return
+	}
+
+	var request struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		TOTPCode string `json:"totp_code,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	ip := middleware.GetRealIP(r)
+	admin, err := service.AuthenticateLocalAdmin(request.Username, request.Password, request.TOTPCode, ip)
+	if err != nil {
+		var throttled *service.LoginThrottledError
+		switch {
+		case errors.As(err, &throttled):
+			w.Header().Set("Retry-After", strconv.Itoa(int(throttled.RetryAfter.Seconds())+1))
+			writeLoginError(w, http.StatusTooManyRequests, err, false)
+		case errors.Is(err, service.ErrLocalLoginTOTPRequired):
+			writeLoginError(w, http.StatusUnauthorized, err, true)
+		case errors.Is(err, service.ErrLocalLoginInvalid), errors.Is(err, service.ErrLocalLoginTOTPInvalid):
+			log.Printf("Local login failed: user %q from %s", request.Username, ip)
+			writeLoginError(w, http.StatusUnauthorized, err, errors.Is(err, service.ErrLocalLoginTOTPInvalid))
+		default:
+			http.Error(w, fmt.Sprintf("Failed to login: %v", err), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	userInfo := service.LocalAdminUserInfo(admin)
+	token, session, err := service.CreateAdminSession(userInfo, &admin.ID, ip, r.UserAgent())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create session: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	middleware.SetSessionCookie(w, r, token, session.ExpiresAt)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"expires_at": session.ExpiresAt,
+			"user_info":  userInfo,
+		},
+	})
+}
+
+// writeLoginError 输出登录失败信息, totp_required 提示前端显示验证码输入框
+func writeLoginError(w http.ResponseWriter, status int, err error, totpRequired bool) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":       false,
+		"error":         err.Error(),
+		"totp_required": totpRequired,
+	})
+}
+
+// ListLocalAdmins 列出本地管理员账号
+func (h *AdminHandler) ListLocalAdmins(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admins, err := service.ListLocalAdmins()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to query local admins: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    admins,
+	})
+}
+
+// CreateLocalAdmin 创建本地管理员账号
+func (h *AdminHandler) CreateLocalAdmin(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		Username    string `json:"username"`
+		Password    string `json:"password"`
+		Role        string `json:"role,omitempty"`
+		EndpointIDs string `json:"endpoint_ids,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if strings.TrimSpace(request.Username) == "" {
+		http.Error(w, "Username is required", http.StatusBadRequest)
+		return
+	}
+	if err := service.ValidateLocalAdminPassword(request.Password); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid password: %v", err), http.StatusBadRequest)
+		return
+	}
+	if _, err := service.FindLocalAdmin(request.Username); err == nil {
+		http.Error(w, "Username already exists", http.StatusConflict)
+		return
+	}
+
+	admin, err := service.CreateLocalAdmin(request.Username, request.Password, request.Role, request.EndpointIDs)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create local admin: %v", err), http.StatusBadRequest)
+		return
+	}
+	service.RecordAudit(r.Context(), service.AuditActionCreate, "local_admin", admin.ID, nil, admin)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    admin,
+	})
+}
+
+// HandleLocalAdminByID 处理本地管理员账号的更新 (重置密码 / 启用禁用 / 角色) 和删除操作
+func (h *AdminHandler) HandleLocalAdminByID(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleAdmin) {
+		return
+	}
+
+	// 路径格式: /api/admin/local-admins/{id}
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) < 5 {
+		http.Error(w, "Invalid local admin ID", http.StatusBadRequest)
+		return
+	}
+
+	adminID, err := strconv.Atoi(parts[4])
+	if err != nil {
+		http.Error(w, "Invalid local admin ID", http.StatusBadRequest)
+		return
+	}
+
+	var existing model.LocalAdmin
+	if err := database.DB.First(&existing, adminID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			http.Error(w, "Local admin not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to get local admin: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// 不能禁用或删除自己正在使用的账号
+	selfID, isLocal := service.LocalAdminID(middleware.GetUserInfo(r))
+	isSelf := isLocal && selfID == existing.ID
+
+	switch r.Method {
+	case http.MethodPut:
+		var request struct {
+			Password    *string `json:"password,omitempty"`
+			Disabled    *bool   `json:"disabled,omitempty"`
+			ResetTOTP   bool    `json:"reset_totp,omitempty"`
+			Role        *string `json:"role,omitempty"`
+			EndpointIDs *string `json:"endpoint_ids,omitempty"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+			return
+		}
+		if request.Disabled != nil && *request.Disabled && isSelf {
+			http.Error(w, "Cannot disable your own account", http.StatusConflict)
+			return
+		}
+		if request.Role != nil && isSelf && *request.Role != service.RoleAdmin {
+			http.Error(w, "Cannot change your own role", http.StatusConflict)
+			return
+		}
+		if request.Role != nil || request.EndpointIDs != nil {
+			role, endpointIDs := existing.Role, existing.EndpointIDs
+			if request.Role != nil {
+				role = *request.Role
+			}
+			if request.EndpointIDs != nil {
+				endpointIDs = *request.EndpointIDs
+			}
+			if err := service.SetLocalAdminRole(existing.ID, role, endpointIDs); err != nil {
+				http.Error(w, fmt.Sprintf("Invalid role: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+		if request.Password != nil {
+			if err := service.SetLocalAdminPassword(existing.ID, *request.Password); err != nil {
+				http.Error(w, fmt.Sprintf("Failed to update password: %v", err), http.StatusBadRequest)
+				return
+			}
+			service.RecordAudit(r.Context(), "reset_password", "local_admin", existing.ID, nil, nil)
+		}
+		if request.Disabled != nil {
+			if err := service.SetLocalAdminDisabled(existing.ID, *request.Disabled); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		if request.ResetTOTP {
+			if err := service.DisableLocalAdminTOTP(existing.ID, "", false); err != nil {
+				http.Error(w, fmt.Sprintf("Failed to reset two-factor authentication: %v", err), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		before := existing
+		database.DB.First(&existing, existing.ID)
+		service.RecordAudit(r.Context(), service.AuditActionUpdate, "local_admin", existing.ID, &before, &existing)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"data":    existing,
+		})
+	case http.MethodDelete:
+		if isSelf {
+			http.Error(w, "Cannot delete your own account", http.StatusConflict)
+			return
+		}
+		if err := service.DeleteLocalAdmin(existing.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		service.RecordAudit(r.Context(), service.AuditActionDelete, "local_admin", existing.ID, &existing, nil)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Local admin deleted successfully",
+		})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// currentLocalAdminID 获取当前登录的本地账号 ID, 非本地账号会话时返回 403
+func currentLocalAdminID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	id, ok := service.LocalAdminID(middleware.GetUserInfo(r))
+	if !ok {
+		http.Error(w, "Only available for local admin accounts", http.StatusForbidden)
+	}
+	return id, ok
+}
+
+// SetupLocalTOTP 为当前本地账号生成二次验证密钥 (需再调用 enable 确认)
+func (h *AdminHandler) SetupLocalTOTP(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleViewer) {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, ok := currentLocalAdminID(w, r)
+	if !ok {
+		return
+	}
+
+	secret, otpURL, err := service.BeginLocalAdminTOTP(id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to setup two-factor authentication: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": map[string]string{
+			"secret":      secret,
+			"otpauth_url": otpURL,
+		},
+	})
+}
+
+// EnableLocalTOTP 验证码确认后开启二次验证
+func (h *AdminHandler) EnableLocalTOTP(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleViewer) {
+		return
+	}
+
+	h.changeLocalTOTP(w, r, true)
+}
+
+// DisableLocalTOTP 验证码确认后关闭二次验证
+func (h *AdminHandler) DisableLocalTOTP(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleViewer) {
+		return
+	}
+
+	h.changeLocalTOTP(w, r, false)
+}
+
+// changeLocalTOTP 开启或关闭当前本地账号的二次验证
+func (h *AdminHandler) changeLocalTOTP(w http.ResponseWriter, r *http.Request, enable bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, ok := currentLocalAdminID(w, r)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	if enable {
+		err = service.EnableLocalAdminTOTP(id, request.Code)
+	} else {
+		err = service.DisableLocalAdminTOTP(id, request.Code, true)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	action := "disable_totp"
+	if enable {
+		action = "enable_totp"
+	}
+	service.RecordAudit(r.Context(), action, "local_admin", id, nil, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Two-factor authentication updated successfully",
+	})
+}
+
+// ChangeLocalPassword 当前本地账号修改密码 (其他会话会被注销, 当前会话同样需要重新登录)
+func (h *AdminHandler) ChangeLocalPassword(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleViewer) {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, ok := currentLocalAdminID(w, r)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		OldPassword string `json:"old_password"`
+		NewPassword string `json:"new_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := service.ChangeLocalAdminPassword(id, request.OldPassword, request.NewPassword); err != nil {
+		if errors.Is(err, service.ErrLocalLoginInvalid) {
+			http.Error(w, "Current password is incorrect", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to change password: %v", err), http.StatusBadRequest)
+		return
+	}
+	service.RecordAudit(r.Context(), "change_password", "local_admin", id, nil, nil)
+	middleware.ClearSessionCookie(w, r)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Password changed successfully, please login again",
+	})
+}
+
+// GetCurrentAdmin 获取当前登录的管理员与会话信息
+func (h *AdminHandler) GetCurrentAdmin(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleViewer) {
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data := map[string]interface{}{
+		"user_info": middleware.GetUserInfo(r),
+		"access":    middleware.GetAccess(r),
+	}
+	if session := middleware.GetSession(r); session != nil {
+		data["session"] = map[string]interface{}{
+			"id":           session.ID,
+			"source":       session.Source,
+			"ip":           session.IP,
+			"user_agent":   session.UserAgent,
+			"created_at":   session.CreatedAt,
+			"expires_at":   session.ExpiresAt,
+			"last_seen_at": session.LastSeenAt,
+		}
+	}
+	if token := middleware.GetAccessToken(r); token != nil {
+		data["access_token"] = token
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    data,
+	})
+}
+
+// requireSession 会话相关操作只对会话登录有效, 个人访问令牌请求返回 400
+func requireSession(w http.ResponseWriter, r *http.Request) bool {
+	if middleware.GetSession(r) == nil {
+		http.Error(w, "Not available for access token requests", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// Logout 退出登录: 撤销当前会话并删除 cookie
+func (h *AdminHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleViewer) {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireSession(w, r) {
+		return
+	}
+
+	token, _ := middleware.SessionToken(r)
+	if err := middleware.InvalidateToken(token); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to logout: %v", err), http.StatusInternalServerError)
+		return
+	}
+	middleware.ClearSessionCookie(w, r)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Logged out successfully",
+	})
+}
+
+// LogoutAll 退出所有设备: 撤销当前用户的全部会话
+func (h *AdminHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleViewer) {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireSession(w, r) {
+		return
+	}
+
+	session := middleware.GetSession(r)
+	count, err := service.RevokeSubjectSessions(session.Source, session.Subject)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to logout: %v", err), http.StatusInternalServerError)
+		return
+	}
+	middleware.ClearSessionCookie(w, r)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Revoked %d sessions", count),
+		"data": map[string]interface{}{
+			"revoked": count,
+		},
+	})
+}
+
+// ListAccessTokens 列出当前管理员的个人访问令牌 (不含明文), admin 可通过 all=true 查看全部
+func (h *AdminHandler) ListAccessTokens(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleViewer) {
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user := middleware.GetUserInfo(r)
+	source, subject := user.Source, user.ID
+	if r.URL.Query().Get("all") == "true" {
+		if !requireRole(w, r, middleware.RoleAdmin) {
+			return
+		}
+		source, subject = "", ""
+	}
+
+	tokens, err := service.ListAccessTokens(source, subject)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to query access tokens: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    tokens,
+	})
+}
+
+// CreateAccessToken 为当前管理员创建个人访问令牌, 明文只在响应中返回一次
+// 令牌不能再创建令牌, 必须通过会话登录创建
+func (h *AdminHandler) CreateAccessToken(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleViewer) {
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireSession(w, r) {
+		return
+	}
+
+	var request struct {
+		Name          string     `json:"name"`
+		Role          string     `json:"role"`
+		EndpointIDs   []uint     `json:"endpoint_ids"`
+		ExpiresAt     *time.Time `json:"expires_at"`
+		ExpiresInDays *int       `json:"expires_in_days"` // 与 expires_at 二选一, 都不填时使用默认有效期
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+	if request.ExpiresInDays != nil && *request.ExpiresInDays <= 0 {
+		http.Error(w, "expires_in_days must be positive, access tokens cannot be non-expiring", http.StatusBadRequest)
+		return
+	}
+
+	token := model.PersonalAccessToken{
+		Name:        request.Name,
+		Role:        request.Role,
+		EndpointIDs: request.EndpointIDs,
+		ExpiresAt:   request.ExpiresAt,
+	}
+	if token.ExpiresAt == nil && request.ExpiresInDays != nil {
+		expiresAt := time.Now().AddDate(0, 0, *request.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+
+	raw, err := service.CreateAccessToken(middleware.GetUserInfo(r), middleware.GetAccess(r), &token)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create access token: %v", err), http.StatusBadRequest)
+		return
+	}
+	service.RecordAudit(r.Context(), service.AuditActionCreate, "access_token", token.ID, nil, &token)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    token,
+		"token":   raw,
+	})
+}
+
+// HandleAccessTokenByID 撤销个人访问令牌 (本人的令牌, admin 可撤销任意令牌)
+func (h *AdminHandler) HandleAccessTokenByID(w http.ResponseWriter, r *http.Request) {
+	if !requireRole(w, r, middleware.RoleViewer) {
+		return
+	}
+
+	// 路径格式: /api/admin/access-tokens/{id}
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) < 5 {
+		http.Error(w, "Invalid access token ID", http.StatusBadRequest)
+		return
+	}
+
+	tokenID, err := strconv.Atoi(parts[4])
+	if err != nil {
+		http.Error(w, "Invalid access token ID", http.StatusBadRequest)
+		return
+	}
+
+	var existing model.PersonalAccessToken
+	if err := database.DB.First(&existing, tokenID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			http.Error(w, "Access token not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to get access token: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	user := middleware.GetUserInfo(r)
+	if existing.Source != user.Source || existing.Subject != user.ID {
+		if !requireRole(w, r, middleware.RoleAdmin) {
+			return
+		}
+	}
+
+	switch r.Method {
+	case http.MethodDelete:
+		before := existing
+		if err := service.RevokeAccessToken(&existing); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to revoke access token: %v", err), http.StatusInternalServerError)
+			return
+		}
+		service.RecordAudit(r.Context(), "revoke", "access_token", existing.ID, &before, &existing)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Access token revoked successfully",
+		})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
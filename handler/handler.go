@@ -3,6 +3,7 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -41,80 +42,101 @@ func NewHandlers(statsManager *stats.StatsManager) *Handlers {
 }
 
 func (h *Handlers) HandleAPIRequest(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
-	defer cancel()
+	start := time.Now()
+	realIP := middleware.GetRealIP(r)
 
-	// 创建一个响应通道，用于传递结果
-	type result struct {
-		url string
-		err error
-	}
-	resultChan := make(chan result, 1)
-
-	go func() {
-		start := time.Now()
-		realIP := middleware.GetRealIP(r)
-
-		path := strings.TrimPrefix(r.URL.Path, "/")
+	path := strings.TrimPrefix(r.URL.Path, "/")
 
-		// 初始化端点服务
-		if h.endpointService == nil {
-			h.endpointService = service.GetEndpointService()
-		}
+	// 初始化端点服务
+	if h.endpointService == nil {
+		h.endpointService = service.GetEndpointService()
+	}
 
-		// 使用新的端点服务
-		randomURL, err := h.endpointService.GetRandomURL(path)
-		if err != nil {
-			monitoring.LogRequest(monitoring.RequestLog{
-				Time:       time.Now().UnixMilli(),
-				Path:       r.URL.Path,
-				Method:     r.Method,
-				StatusCode: http.StatusNotFound,
-				Latency:    float64(time.Since(start).Microseconds()) / 1000,
-				IP:         realIP,
-				Referer:    r.Referer(),
-			})
-			resultChan <- result{err: fmt.Errorf("endpoint not found: %v", err)}
+	// 直接在请求 context 上执行: 客户端断开或超过端点超时后, 上游请求会被一并取消
+	randomURL, err := h.endpointService.GetRandomURL(r.Context(), path)
+	if err != nil {
+		statusCode := http.StatusNotFound
+		message := fmt.Sprintf("endpoint not found: %v", err)
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			statusCode = http.StatusGatewayTimeout
+			message = "Request timeout"
+		case errors.Is(err, service.ErrColdLoadInProgress):
+			// 数据源正在冷启动加载, 提示客户端稍后重试
+			statusCode = http.StatusServiceUnavailable
+			message = err.Error()
+			w.Header().Set("Retry-After", "5")
+		case errors.Is(err, context.Canceled):
+			// 客户端已断开, 无需再写响应
 			return
 		}
 
-		// 成功获取到URL
-		h.Stats.IncrementCalls(path)
-
-		duration := time.Since(start)
 		monitoring.LogRequest(monitoring.RequestLog{
 			Time:       time.Now().UnixMilli(),
 			Path:       r.URL.Path,
 			Method:     r.Method,
-			StatusCode: http.StatusFound,
-			Latency:    float64(duration.Microseconds()) / 1000,
+			StatusCode: statusCode,
+			Latency:    float64(time.Since(start).Microseconds()) / 1000,
 			IP:         realIP,
 			Referer:    r.Referer(),
 		})
+		http.Error(w, message, statusCode)
+		return
+	}
 
-		log.Printf(" %-12s | %-15s | %-6s | %-20s | %-20s | %-50s",
-			duration,
-			realIP,
-			r.Method,
-			r.URL.Path,
-			r.Referer(),
-			randomURL,
-		)
-
-		resultChan <- result{url: randomURL}
-	}()
-
-	// 等待结果或超时
-	select {
-	case res := <-resultChan:
-		if res.err != nil {
-			http.Error(w, res.err.Error(), http.StatusNotFound)
-			return
-		}
-		http.Redirect(w, r, res.url, http.StatusFound)
-	case <-ctx.Done():
-		http.Error(w, "Request timeout", http.StatusGatewayTimeout)
+	// 成功获取到URL
+	h.Stats.IncrementCalls(path)
+
+	jsonMode := wantsJSON(r)
+	statusCode := http.StatusFound
+	if jsonMode {
+		statusCode = http.StatusOK
+	}
+
+	duration := time.Since(start)
+	monitoring.LogRequest(monitoring.RequestLog{
+		Time:       time.Now().UnixMilli(),
+		Path:       r.URL.Path,
+		Method:     r.Method,
+		StatusCode: statusCode,
+		Latency:    float64(duration.Microseconds()) / 1000,
+		IP:         realIP,
+		Referer:    r.Referer(),
+	})
+
+	log.Printf(" %-12s | %-15s | %-6s | %-20s | %-20s | %-50s",
+		duration,
+		realIP,
+		r.Method,
+		r.URL.Path,
+		r.Referer(),
+		randomURL,
+	)
+
+	// JSON 模式: 返回随机 URL 而不是跳转, 便于跨域脚本直接读取
+	if jsonMode {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"data": map[string]string{
+				"url": randomURL,
+			},
+		})
+		return
+	}
+
+	http.Redirect(w, r, randomURL, http.StatusFound)
+}
+
+// wantsJSON 判断随机端点请求是否要求 JSON 响应: ?format=json 或 Accept 首选 application/json
+func wantsJSON(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return strings.EqualFold(format, "json")
 	}
+	accept := strings.TrimSpace(strings.Split(r.Header.Get("Accept"), ",")[0])
+	mediaType := strings.TrimSpace(strings.Split(accept, ";")[0])
+	return strings.EqualFold(mediaType, "application/json")
 }
 
 func (h *Handlers) HandleStats(w http.ResponseWriter, r *http.Request) {
@@ -14,9 +14,10 @@ func InitData() error {
 	log.Println("开始初始化应用数据...")
 	start := time.Now()
 
-	// 1. 初始化域名统计服务
+	// 1. 初始化域名统计服务与管理员名单
 	_ = service.GetDomainStatsService()
 	log.Println("✓ 域名统计服务已初始化")
+	_ = service.GetAdminUserService()
 
 	// 2. 初始化端点服务（这会启动预加载器）
 	endpointService := service.GetEndpointService()
@@ -206,6 +207,29 @@ func preloadConfigs() {
 		"rate_limit_window",
 		"cors_enabled",
 		"cors_origins",
+		"cors_methods",
+		"cors_headers",
+		"cors_max_age",
+		"api_request_timeout",
+		"cold_load_policy",
+		"url_replace_global_order",
+		"link_signing_secret",
+		"admin_session_ttl_hours",
+		"endpoint_revision_limit",
+		"access_token_default_days",
+		"access_token_max_days",
+		"bot_policy_default_mode",
+
+		// 滥用检测配置
+		"abuse_detection_enabled",
+		"abuse_rpm_threshold",
+		"abuse_daily_threshold",
+		"abuse_share_percent",
+		"abuse_share_min_requests",
+		"abuse_new_domain_rpm",
+		"abuse_new_domain_hours",
+		"abuse_block_minutes",
+		"abuse_exempt_domains",
 
 		// 兰空图床配置
 		"lankong_max_retries",
@@ -257,7 +281,8 @@ func GetInitStatus() map[string]interface{} {
 			"items":   len(cacheStats),
 			"details": cacheStats,
 		},
-		"config_cache": configCacheStats,
+		"config_cache":      configCacheStats,
+		"data_source_loads": endpointService.GetDataSourceLoadStats(),
 		"endpoints": map[string]interface{}{
 			"total":  len(endpoints),
 			"active": activeEndpoints,
@@ -27,6 +27,7 @@ type App struct {
 	Stats         *stats.StatsManager
 	adminHandler  *handler.AdminHandler
 	staticHandler *handler.StaticHandler
+	pidFile       *os.File
 }
 
 func NewApp() *App {
@@ -63,7 +64,16 @@ func (a *App) Initialize() error {
 	a.Stats = stats.NewStatsManager(statsFile)
 
 	// 初始化端点服务
-	service.GetEndpointService()
+	endpointService := service.GetEndpointService()
+
+	// 同步声明式配置文件, 文件有错误时拒绝启动
+	if service.ConfigFileEnabled() {
+		plan, err := endpointService.ReconcileConfigFile()
+		if err != nil {
+			return fmt.Errorf("failed to reconcile config file: %w", err)
+		}
+		logConfigFileSummary(plan)
+	}
 
 	// 预加载所有数据到内存
 	log.Println("开始预加载应用数据...")
@@ -120,10 +130,47 @@ func (a *App) Run() error {
 		}
 	}()
 
+	// 写入 PID 文件, 命令行修改数据库后据此发送 SIGHUP
+	pidFile, err := lockServerPIDFile()
+	if err != nil {
+		log.Printf("写入 PID 文件失败, 命令行修改后需手动重启服务: %v", err)
+	} else {
+		a.pidFile = pidFile
+	}
+
+	// 收到 SIGHUP 时重新同步声明式配置文件并重新加载内存缓存
+	go a.reloadOnSIGHUP()
+
 	// 优雅关闭
 	return a.gracefulShutdown()
 }
 
+// reloadOnSIGHUP 收到 SIGHUP 后重新同步声明式配置文件 (失败时保留当前配置继续运行),
+// 再从数据库重新加载内存缓存, 使命令行等其他进程的修改生效
+func (a *App) reloadOnSIGHUP() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		if service.ConfigFileEnabled() {
+			log.Println("收到 SIGHUP, 重新同步配置文件...")
+			plan, err := service.GetEndpointService().ReconcileConfigFile()
+			if err != nil {
+				log.Printf("同步配置文件失败: %v", err)
+			} else {
+				logConfigFileSummary(plan)
+			}
+		}
+		log.Println("收到 SIGHUP, 重新加载内存缓存...")
+		service.GetEndpointService().ReloadCaches()
+	}
+}
+
+// logConfigFileSummary 输出配置文件同步结果
+func logConfigFileSummary(plan *service.ImportPlan) {
+	log.Printf("配置文件已同步: 新增 %d, 更新 %d, 删除 %d, 未变化 %d",
+		plan.Summary.Create, plan.Summary.Update, plan.Summary.Delete, plan.Summary.Unchanged)
+}
+
 func (a *App) gracefulShutdown() error {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -145,11 +192,20 @@ func (a *App) gracefulShutdown() error {
 		return err
 	}
 
+	// 释放 PID 文件锁
+	if a.pidFile != nil {
+		a.pidFile.Close()
+	}
+
 	log.Println("Server shutdown completed")
 	return nil
 }
 
 func main() {
+	if runCLI(os.Args[1:]) {
+		return
+	}
+
 	app := NewApp()
 	if err := app.Initialize(); err != nil {
 		log.Fatal(err)
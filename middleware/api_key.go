@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"random-api-go/model"
+	"random-api-go/service"
+)
+
+// APIKeyContextKey 用于在 context 中存储已验证的 API Key
+const APIKeyContextKey contextKey = "api_key"
+
+// APIKeyMiddleware 校验随机端点请求携带的 API Key
+// Key 从 X-API-Key 头或 api_key 查询参数读取; 仅作用于随机端点路径 (见 isRandomEndpointPath)
+// 携带有效 Key 的请求跳过浏览器限制, 使用 Key 的专属限流; 携带无效 Key 直接拒绝, 不会降级为匿名访问
+// 端点策略开启 require_api_key 时, 未携带 Key 返回 401
+func APIKeyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if !isRandomEndpointPath(path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		raw := r.Header.Get("X-API-Key")
+		if raw == "" {
+			raw = r.URL.Query().Get("api_key")
+		}
+		if raw == "" {
+			if service.GetEndpointPolicyService().RequiresAPIKey(path) {
+				http.Error(w, "API key required", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key, err := service.GetAPIKeyService().Authenticate(raw, path)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrAPIKeyEndpointDenied):
+				http.Error(w, err.Error(), http.StatusForbidden)
+			case errors.Is(err, service.ErrAPIKeyQuotaExhausted):
+				http.Error(w, err.Error(), http.StatusTooManyRequests)
+			default:
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+			}
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), APIKeyContextKey, key)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// GetAPIKey 从 request context 中获取已验证的 API Key, 未携带时返回 nil
+func GetAPIKey(r *http.Request) *model.APIKey {
+	if key, ok := r.Context().Value(APIKeyContextKey).(*model.APIKey); ok {
+		return key
+	}
+	return nil
+}
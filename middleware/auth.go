@@ -1,141 +1,311 @@
 package middleware
 
 import (
-	"encoding/json"
-	"fmt"
+	"context"
+	"crypto/subtle"
 	"net/http"
+	"net/url"
 	"strings"
-	"sync"
 	"time"
+
+	"random-api-go/config"
+	"random-api-go/model"
+	"random-api-go/service"
 )
 
-// UserInfo OAuth用户信息结构
-type UserInfo struct {
-	ID       int    `json:"id"`
-	Username string `json:"username"`
-	Nickname string `json:"nickname"`
-	Email    string `json:"email"`
-	Avatar   string `json:"avatar"`
+// UserInfoContextKey 用于在 context 中存储已登录的管理员
+const UserInfoContextKey contextKey = "admin_user"
+
+// SessionContextKey 用于在 context 中存储当前管理会话
+const SessionContextKey contextKey = "admin_session"
+
+// AccessContextKey 用于在 context 中存储当前管理员的角色与端点范围
+const AccessContextKey contextKey = "admin_access"
+
+// AccessTokenContextKey 用于在 context 中存储本次请求使用的个人访问令牌
+const AccessTokenContextKey contextKey = "admin_access_token"
+
+// 管理后台角色
+const (
+	RoleViewer = service.RoleViewer
+	RoleEditor = service.RoleEditor
+	RoleAdmin  = service.RoleAdmin
+)
+
+// AdminSessionCookie 管理会话 cookie 名称 (HttpOnly, 前端脚本无法读取)
+const AdminSessionCookie = "ras_session"
+
+// adminSessionCookiePath cookie 只在管理接口上发送
+const adminSessionCookiePath = "/api/admin"
+
+// OAuthStateCookie 发起 OAuth 登录的浏览器保存的 state, 回调时必须与参数一致,
+// 防止把他人发起登录得到的回调地址交给管理员打开, 让管理员登录到他人的账号 (login CSRF)
+const OAuthStateCookie = "ras_oauth_state"
+
+// oauthStateCookiePath state cookie 只在登录与回调接口上发送
+const oauthStateCookiePath = "/api/admin/oauth"
+
+// UserInfo OAuth用户信息结构 (按提供方的声明映射后的结果)
+type UserInfo = service.OAuthUserInfo
+
+// GetUserInfo 从 request context 中获取当前管理员 (仅 RequireAuth 保护的路由可用)
+func GetUserInfo(r *http.Request) *UserInfo {
+	if user, ok := r.Context().Value(UserInfoContextKey).(*UserInfo); ok {
+		return user
+	}
+	return nil
 }
 
-// TokenCache token缓存项
-type TokenCache struct {
-	UserInfo  *UserInfo
-	ExpiresAt time.Time
+// GetSession 从 request context 中获取当前管理会话 (仅 RequireAuth 保护的路由可用)
+func GetSession(r *http.Request) *model.AdminSession {
+	if session, ok := r.Context().Value(SessionContextKey).(*model.AdminSession); ok {
+		return session
+	}
+	return nil
 }
 
-// AuthMiddleware 认证中间件
-type AuthMiddleware struct {
-	tokenCache sync.Map // map[string]*TokenCache
-	cacheTTL   time.Duration
+// GetAccess 从 request context 中获取当前管理员的角色与端点范围 (仅 RequireAuth 保护的路由可用)
+func GetAccess(r *http.Request) *service.AdminAccess {
+	if access, ok := r.Context().Value(AccessContextKey).(*service.AdminAccess); ok {
+		return access
+	}
+	return nil
+}
+
+// GetAccessToken 从 request context 中获取本次请求使用的个人访问令牌, 会话登录的请求返回 nil
+func GetAccessToken(r *http.Request) *model.PersonalAccessToken {
+	if token, ok := r.Context().Value(AccessTokenContextKey).(*model.PersonalAccessToken); ok {
+		return token
+	}
+	return nil
+}
+
+// SessionToken 获取请求携带的会话令牌, 优先 Authorization header, 其次 cookie
+func SessionToken(r *http.Request) (token string, fromCookie bool) {
+	if authHeader := r.Header.Get("Authorization"); authHeader != "" {
+		return strings.TrimPrefix(authHeader, "Bearer "), false
+	}
+	if cookie, err := r.Cookie(AdminSessionCookie); err == nil && cookie.Value != "" {
+		return cookie.Value, true
+	}
+	return "", false
+}
+
+// SetSessionCookie 登录成功后写入会话 cookie
+func SetSessionCookie(w http.ResponseWriter, r *http.Request, token string, expiresAt time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     AdminSessionCookie,
+		Value:    token,
+		Path:     adminSessionCookiePath,
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   secureCookie(r),
+		// Lax: 从登录提供方跳转回来时也能带上, 跨站的 POST 等请求不会携带
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// ClearSessionCookie 登出时删除会话 cookie
+func ClearSessionCookie(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     AdminSessionCookie,
+		Value:    "",
+		Path:     adminSessionCookiePath,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   secureCookie(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// SetOAuthStateCookie 发起 OAuth 登录时写入 state cookie, 有效期与 state 相同
+func SetOAuthStateCookie(w http.ResponseWriter, r *http.Request, state string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     OAuthStateCookie,
+		Value:    state,
+		Path:     oauthStateCookiePath,
+		MaxAge:   int(service.OAuthStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   secureCookie(r),
+		// Lax: 从登录提供方跳转回来的 GET 请求会携带
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// OAuthStateMatches 判断回调的 state 是否与发起登录的浏览器保存的一致
+func OAuthStateMatches(r *http.Request, state string) bool {
+	cookie, err := r.Cookie(OAuthStateCookie)
+	if err != nil || cookie.Value == "" || state == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) == 1
+}
+
+// ClearOAuthStateCookie 回调处理后删除 state cookie
+func ClearOAuthStateCookie(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     OAuthStateCookie,
+		Value:    "",
+		Path:     oauthStateCookiePath,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   secureCookie(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// InvalidateToken 撤销会话令牌 (用于登出)
+func InvalidateToken(token string) error {
+	if token == "" {
+		return nil
+	}
+	return service.RevokeAdminSession(token)
 }
 
+// secureCookie HTTPS 访问 (直连或 BASE_URL 为 https) 时设置 Secure
+func secureCookie(r *http.Request) bool {
+	return r.TLS != nil || strings.HasPrefix(strings.ToLower(config.Get().App.BaseURL), "https://")
+}
+
+// AuthMiddleware 认证中间件
+type AuthMiddleware struct{}
+
 // NewAuthMiddleware 创建新的认证中间件
 func NewAuthMiddleware() *AuthMiddleware {
-	return &AuthMiddleware{
-		cacheTTL: 30 * time.Minute, // token缓存30分钟
-	}
+	return &AuthMiddleware{}
 }
 
-// RequireAuth 认证中间件，验证 OAuth 令牌
+// RequireAuth 认证中间件，验证本服务签发的管理会话 (Bearer 令牌或 HttpOnly cookie) 或个人访问令牌 (仅 Bearer)
+// OAuth 登录用户还需通过管理员名单检查 (名单变更立即生效), 用户信息与会话写入 request context
 func (am *AuthMiddleware) RequireAuth(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// 从 Authorization header 获取令牌
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Authorization header required", http.StatusUnauthorized)
-			return
-		}
-
 		// 检查 Bearer 前缀
-		if !strings.HasPrefix(authHeader, "Bearer ") {
+		if authHeader := r.Header.Get("Authorization"); authHeader != "" && !strings.HasPrefix(authHeader, "Bearer ") {
 			http.Error(w, "Invalid authorization header format", http.StatusUnauthorized)
 			return
 		}
 
-		token := strings.TrimPrefix(authHeader, "Bearer ")
+		token, fromCookie := SessionToken(r)
 		if token == "" {
-			http.Error(w, "Token required", http.StatusUnauthorized)
+			http.Error(w, "Authorization required", http.StatusUnauthorized)
 			return
 		}
 
-		// 先检查缓存
-		if cached, ok := am.tokenCache.Load(token); ok {
-			tokenCache := cached.(*TokenCache)
-			// 检查缓存是否过期
-			if time.Now().Before(tokenCache.ExpiresAt) {
-				// 缓存有效，直接通过
-				next(w, r)
-				return
-			} else {
-				// 缓存过期，删除
-				am.tokenCache.Delete(token)
-			}
+		// cookie 由浏览器自动携带, 修改类请求必须来自本站页面
+		if fromCookie && !isSafeMethod(r.Method) && !isSameOrigin(r) {
+			http.Error(w, "Cross-site request rejected", http.StatusForbidden)
+			return
 		}
 
-		// 缓存未命中或已过期，验证令牌
-		userInfo, err := am.getUserInfo(token)
+		if strings.HasPrefix(token, service.AccessTokenPrefix) {
+			am.serveAccessToken(w, r, token, fromCookie, next)
+			return
+		}
+
+		userInfo, session, err := service.ValidateAdminSession(token)
 		if err != nil {
+			if fromCookie {
+				ClearSessionCookie(w, r)
+			}
 			http.Error(w, "Invalid token", http.StatusUnauthorized)
 			return
 		}
 
-		// 将结果缓存
-		am.tokenCache.Store(token, &TokenCache{
-			UserInfo:  userInfo,
-			ExpiresAt: time.Now().Add(am.cacheTTL),
-		})
-
-		// 验证成功，继续处理请求
-		next(w, r)
+		am.serveAuthorized(w, r.WithContext(context.WithValue(r.Context(), SessionContextKey, session)), userInfo, next)
 	}
 }
 
-// getUserInfo 通过访问令牌获取用户信息
-func (am *AuthMiddleware) getUserInfo(accessToken string) (*UserInfo, error) {
-	req, err := http.NewRequest("GET", "https://connect.czl.net/api/oauth2/userinfo", nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+// serveAccessToken 个人访问令牌认证: 权限为令牌角色与创建者当前权限的交集
+func (am *AuthMiddleware) serveAccessToken(w http.ResponseWriter, r *http.Request, token string, fromCookie bool, next http.HandlerFunc) {
+	if fromCookie {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
 	}
-
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-
-	client := &http.Client{
-		Timeout: 10 * time.Second, // 添加超时时间
-	}
-	resp, err := client.Do(req)
+	userInfo, access, accessToken, err := service.ValidateAccessToken(token, GetRealIP(r))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user info: %w", err)
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
 	}
-	defer resp.Body.Close()
+	ctx := context.WithValue(r.Context(), AccessTokenContextKey, accessToken)
+	am.serveWithAccess(w, r.WithContext(ctx), userInfo, access, next)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get user info, status: %d", resp.StatusCode)
+// serveAuthorized 解析角色 (OAuth 用户按管理员名单, 本地账号按账号设置), 通过后携带用户信息继续处理请求
+// 每个请求都重新解析, 名单或角色变更立即生效
+func (am *AuthMiddleware) serveAuthorized(w http.ResponseWriter, r *http.Request, userInfo *UserInfo, next http.HandlerFunc) {
+	access := service.ResolveAdminAccess(userInfo)
+	if access == nil {
+		if userInfo.Source != service.SessionSourceLocal && service.GetAdminUserService().IsOpen() {
+			http.Error(w, service.ErrAdminAllowlistEmpty.Error(), http.StatusForbidden)
+			return
+		}
+		http.Error(w, "Admin access denied", http.StatusForbidden)
+		return
 	}
+	am.serveWithAccess(w, r, userInfo, access, next)
+}
 
-	var userInfo UserInfo
-	if err := json.NewDecoder(resp.Body).Decode(&userInfo); err != nil {
-		return nil, fmt.Errorf("failed to decode user info: %w", err)
-	}
+// serveWithAccess 将用户信息、权限与审计操作者写入 request context 后继续处理请求
+func (am *AuthMiddleware) serveWithAccess(w http.ResponseWriter, r *http.Request, userInfo *UserInfo, access *service.AdminAccess, next http.HandlerFunc) {
+	ctx := context.WithValue(r.Context(), UserInfoContextKey, userInfo)
+	ctx = context.WithValue(ctx, AccessContextKey, access)
+	// 之后通过 r.Context() 发起的变更记录到此管理员名下
+	ctx = service.WithAuditActor(ctx, service.AuditActor{Name: userInfo.Username, ID: userInfo.ID, IP: GetRealIP(r)})
+	next(w, r.WithContext(ctx))
+}
 
-	return &userInfo, nil
+// RequireRole 认证后要求至少具备指定角色
+func (am *AuthMiddleware) RequireRole(role string, next http.HandlerFunc) http.HandlerFunc {
+	return am.RequireRoleFor(role, role, next)
+}
+
+// RequireRoleFor 认证后按请求方法要求角色: 读取类请求需要 readRole, 修改类请求需要 writeRole
+func (am *AuthMiddleware) RequireRoleFor(readRole, writeRole string, next http.HandlerFunc) http.HandlerFunc {
+	return am.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+		role := writeRole
+		if isSafeMethod(r.Method) {
+			role = readRole
+		}
+		if !GetAccess(r).HasRole(role) {
+			http.Error(w, "Insufficient role", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	})
 }
 
-// InvalidateToken 使token缓存失效（用于登出等场景）
-func (am *AuthMiddleware) InvalidateToken(token string) {
-	am.tokenCache.Delete(token)
+// isSafeMethod 不修改数据的请求方法
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
 }
 
-// GetCacheStats 获取缓存统计信息（用于监控）
-func (am *AuthMiddleware) GetCacheStats() map[string]interface{} {
-	count := 0
-	am.tokenCache.Range(func(key, value interface{}) bool {
-		count++
+// isSameOrigin 根据 Sec-Fetch-Site / Origin / Referer 判断请求是否来自本站
+// 都没有时 (非浏览器客户端) 放行, 浏览器跨站请求至少会带其中之一
+func isSameOrigin(r *http.Request) bool {
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" {
+		return site == "same-origin" || site == "none"
+	}
+
+	source := r.Header.Get("Origin")
+	if source == "" {
+		source = r.Header.Get("Referer")
+	}
+	if source == "" {
 		return true
-	})
+	}
+	u, err := url.Parse(source)
+	if err != nil || u.Host == "" {
+		return false
+	}
 
-	return map[string]interface{}{
-		"cached_tokens": count,
-		"cache_ttl":     am.cacheTTL.String(),
+	hosts := []string{r.Host, r.Header.Get("X-Forwarded-Host")}
+	if base, err := url.Parse(config.Get().App.BaseURL); err == nil {
+		hosts = append(hosts, base.Host)
+	}
+	for _, host := range hosts {
+		if host != "" && strings.EqualFold(u.Host, host) {
+			return true
+		}
 	}
+	return false
 }
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+
+	"random-api-go/service"
+)
+
+// BotPolicyMiddleware 随机端点机器人策略中间件
+// 对随机端点(动态路径)按端点的机器人策略过滤请求 (off / browser_only / block_bots, UA 放行与拦截列表, 链接预览爬虫)
+// 其他路径(API、管理后台、静态资源等)不受此限制; 被拦截的请求计入机器人拦截统计
+func BotPolicyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+
+		// 白名单路径 (API接口、静态资源、管理后台) 与根路径 (前端首页): 不检查机器人策略,直接放行
+		if !isRandomEndpointPath(path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// 携带有效 API Key 或签名链接的请求已获授权,不做机器人限制 (邮件客户端的图片代理通常不是浏览器)
+		if GetAPIKey(r) != nil || IsSignedRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// 其他所有路径都被视为随机端点,按端点策略判定
+		// 这些是动态配置的端点路径,如 /img, /video, /wallpaper 等
+		if ok, reason := service.GetEndpointPolicyService().CheckBot(path, r.UserAgent()); !ok {
+			service.RecordBotBlock(path, reason)
+			message := "Bot access denied"
+			if reason == service.BotBlockNotBrowser {
+				message = "仅限浏览器访问此端点"
+			}
+			http.Error(w, message, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
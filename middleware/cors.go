@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"random-api-go/service"
+)
+
+// CORSMiddleware 跨域中间件
+// 公开 API (/api/...) 使用全局 cors_* 配置; 随机端点可在端点策略中覆盖 (来源、方法、请求头、预检缓存)
+// 管理后台 API 与前端页面不开放跨域; 预检请求在此直接应答, 不进入后续的鉴权与限流
+func CORSMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cfg := resolveCORS(r.URL.Path)
+		if cfg == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		allowOrigin := cfg.AllowOrigin(origin)
+
+		// 预检请求
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+
+			// 来源、方法或请求头不允许时不返回 CORS 头, 由浏览器拒绝
+			if allowOrigin != "" &&
+				containsFold(cfg.Methods, r.Header.Get("Access-Control-Request-Method")) &&
+				headersAllowed(cfg.Headers, r.Header.Get("Access-Control-Request-Headers")) {
+				h.Set("Access-Control-Allow-Origin", allowOrigin)
+				h.Set("Access-Control-Allow-Methods", strings.Join(cfg.Methods, ", "))
+				if len(cfg.Headers) > 0 {
+					h.Set("Access-Control-Allow-Headers", strings.Join(cfg.Headers, ", "))
+				}
+				if cfg.MaxAge > 0 {
+					h.Set("Access-Control-Max-Age", strconv.Itoa(cfg.MaxAge))
+				}
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if allowOrigin != "" {
+			h.Set("Access-Control-Allow-Origin", allowOrigin)
+			h.Set("Access-Control-Expose-Headers", service.CORSExposeHeaders)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// resolveCORS 按路径取 CORS 配置, 返回 nil 表示该路径不处理跨域
+func resolveCORS(path string) *service.CORSConfig {
+	// 管理后台 API 只供同源前端使用
+	if strings.HasPrefix(path, "/api/admin") {
+		return nil
+	}
+	if strings.HasPrefix(path, "/api/") {
+		return service.GetEndpointPolicyService().ResolveCORS(path, false)
+	}
+
+	// 前端页面与静态资源不处理, 其他路径视为随机端点
+	if !isRandomEndpointPath(path) {
+		return nil
+	}
+	return service.GetEndpointPolicyService().ResolveCORS(path, true)
+}
+
+// headersAllowed 判断预检请求声明的请求头是否都在允许列表中
+func headersAllowed(allowed []string, requested string) bool {
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header != "" && !containsFold(allowed, header) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsFold 忽略大小写判断列表是否包含某值, 列表中的 * 匹配任意值
+func containsFold(items []string, value string) bool {
+	for _, item := range items {
+		if item == "*" || strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,9 +2,15 @@ package middleware
 
 import (
 	"context"
+	"log"
+	"net"
 	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
 
-	"github.com/woodchen-ink/go-web-utils/iputil"
+	"random-api-go/config"
+	"random-api-go/utils"
 )
 
 // contextKey 用于在 context 中存储真实 IP
@@ -15,13 +21,33 @@ const (
 	RealIPKey contextKey = "real_ip"
 )
 
+var (
+	trustedProxies     *utils.CIDRTrie[struct{}]
+	trustedProxiesOnce sync.Once
+)
+
+// getTrustedProxies 按配置构建可信代理前缀树 (首次使用时构建一次)
+func getTrustedProxies() *utils.CIDRTrie[struct{}] {
+	trustedProxiesOnce.Do(func() {
+		trie := utils.NewCIDRTrie[struct{}]()
+		for _, item := range config.Get().Security.TrustedProxies {
+			prefix, err := utils.ParsePrefix(item)
+			if err != nil {
+				log.Printf("忽略无效的可信代理配置 %q: %v", item, err)
+				continue
+			}
+			trie.Insert(prefix, struct{}{})
+		}
+		trustedProxies = trie
+	})
+	return trustedProxies
+}
+
 // RealIPMiddleware 中间件用于检测用户真实 IP
-// 使用 go-web-utils 的 iputil 包来获取真实 IP
-// 支持从多个 header 中提取 IP,包括 X-Forwarded-For, X-Real-IP 等
+// 只有直连地址属于可信代理 (TRUSTED_PROXIES) 时才采信转发头, 否则直接使用连接地址, 防止客户端伪造 IP 绕过限流
 func RealIPMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// 使用 iputil.GetClientIP 获取真实 IP
-		realIP := iputil.GetClientIP(r)
+		realIP := resolveClientIP(r)
 
 		// 将真实 IP 存储到 context 中,方便后续处理器使用
 		ctx := context.WithValue(r.Context(), RealIPKey, realIP)
@@ -36,6 +62,93 @@ func GetRealIP(r *http.Request) string {
 	if ip, ok := r.Context().Value(RealIPKey).(string); ok {
 		return ip
 	}
-	// 如果 context 中没有,直接使用 iputil 获取
-	return iputil.GetClientIP(r)
+	// 如果 context 中没有,按相同规则重新解析
+	return resolveClientIP(r)
+}
+
+// resolveClientIP 解析客户端 IP
+// 1. 直连地址不可信 → 直连地址
+// 2. 配置了 CLIENT_IP_HEADER 且该头有效 → 该头
+// 3. X-Forwarded-For 从右向左跳过可信代理, 取第一个不可信地址
+// 4. X-Real-IP
+func resolveClientIP(r *http.Request) string {
+	peer := remoteAddr(r)
+	trusted := getTrustedProxies()
+	if !trusted.Contains(peer) {
+		return addrString(peer, r.RemoteAddr)
+	}
+
+	if header := config.Get().Security.ClientIPHeader; header != "" {
+		if addr, ok := parseIP(r.Header.Get(header)); ok {
+			return addr.String()
+		}
+	}
+
+	if hops := forwardedHops(r); len(hops) > 0 {
+		client := peer
+		for i := len(hops) - 1; i >= 0; i-- {
+			addr, ok := parseIP(hops[i])
+			if !ok {
+				// 链路中出现无法解析的值, 停在最后一个可信跳之后的地址
+				break
+			}
+			client = addr
+			if !trusted.Contains(addr) {
+				break
+			}
+		}
+		return client.String()
+	}
+
+	if addr, ok := parseIP(r.Header.Get("X-Real-IP")); ok {
+		return addr.String()
+	}
+	return addrString(peer, r.RemoteAddr)
+}
+
+// forwardedHops 合并所有 X-Forwarded-For 头中的地址 (保持从客户端到代理的顺序)
+func forwardedHops(r *http.Request) []string {
+	var hops []string
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(value, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	return hops
+}
+
+// remoteAddr 解析连接的对端地址
+func remoteAddr(r *http.Request) netip.Addr {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, _ := parseIP(host)
+	return addr
+}
+
+// parseIP 解析 IP, 兼容带端口与方括号的写法
+func parseIP(s string) (netip.Addr, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return netip.Addr{}, false
+	}
+	if addrPort, err := netip.ParseAddrPort(s); err == nil {
+		return addrPort.Addr().Unmap().WithZone(""), true
+	}
+	addr, err := netip.ParseAddr(strings.Trim(s, "[]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap().WithZone(""), true
+}
+
+// addrString 地址无效时回退为原始 RemoteAddr
+func addrString(addr netip.Addr, fallback string) string {
+	if addr.IsValid() {
+		return addr.String()
+	}
+	return fallback
 }
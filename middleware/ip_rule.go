@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http"
+
+	"random-api-go/service"
+)
+
+// IPRuleMiddleware 按 IP/CIDR 规则拦截随机端点请求
+// 仅作用于随机端点路径 (见 isRandomEndpointPath), 管理后台不受影响, 避免误封自己
+// 端点规则优先于全局规则, 被拒绝时直接返回 403, 不进入统计与限流
+func IPRuleMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if !isRandomEndpointPath(path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if ok, _ := service.GetIPRuleService().Check(path, GetRealIP(r)); !ok {
+			http.Error(w, "IP address is not allowed", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
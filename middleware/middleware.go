@@ -1,6 +1,32 @@
 package middleware
 
-import "net/http"
+import (
+	"net/http"
+	"strings"
+)
+
+// nonEndpointPrefixes 不属于随机端点的路径前缀: API接口、Next.js静态资源、静态文件、网站图标与管理后台前端页面
+var nonEndpointPrefixes = []string{
+	"/api/",
+	"/_next/",
+	"/static/",
+	"/favicon.ico",
+	"/admin",
+}
+
+// isRandomEndpointPath 判断路径是否为随机端点 (动态配置的端点路径, 如 /img)
+// 根路径 (前端首页) 与 nonEndpointPrefixes 下的路径不是随机端点, 随机端点相关的中间件对其直接放行
+func isRandomEndpointPath(path string) bool {
+	if path == "/" {
+		return false
+	}
+	for _, prefix := range nonEndpointPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return false
+		}
+	}
+	return true
+}
 
 // Chain 用于组合多个中间件
 func Chain(middlewares ...func(http.Handler) http.Handler) func(http.Handler) http.Handler {
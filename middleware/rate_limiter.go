@@ -1,85 +1,138 @@
 package middleware
 
 import (
+	"math"
 	"net/http"
+	"net/netip"
+	"strconv"
 	"sync"
 	"time"
 
+	"random-api-go/service"
+
 	"golang.org/x/time/rate"
 )
 
-// 限流配置：每个 IP 每秒允许 10 个请求，突发容量 20
-// 可根据实际需求调整：
-// - 严格防护: rate.Limit(5), 10
-// - 中等防护: rate.Limit(10), 20
-// - 宽松防护: rate.Limit(20), 40
-const (
-	rateLimitPerSecond = 20
-	rateLimitBurst     = 40
-)
+// limiterEvictInterval 闲置限流器的检查间隔
+const limiterEvictInterval = time.Minute
 
-// IPRateLimiter 基于 IP 的限流器
-type IPRateLimiter struct {
-	limiters map[string]*rate.Limiter
-	mu       sync.RWMutex
-	rate     rate.Limit
-	burst    int
+// limiterEntry 单个限流桶
+type limiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+	idleTTL  time.Duration // 闲置超过该时长后桶已回满, 淘汰不影响限流结果
 }
 
-// NewIPRateLimiter 创建新的 IP 限流器
-func NewIPRateLimiter(r rate.Limit, b int) *IPRateLimiter {
-	limiter := &IPRateLimiter{
-		limiters: make(map[string]*rate.Limiter),
-		rate:     r,
-		burst:    b,
+// RateLimiterStore 按 key 保存限流桶, 闲置的桶逐个淘汰
+type RateLimiterStore struct {
+	limiters map[string]*limiterEntry
+	mu       sync.Mutex
+}
+
+// NewRateLimiterStore 创建限流桶存储, 并启动闲置淘汰
+func NewRateLimiterStore() *RateLimiterStore {
+	store := &RateLimiterStore{
+		limiters: make(map[string]*limiterEntry),
 	}
 
-	// 启动定期清理过期限流器的 goroutine
-	go limiter.cleanupExpiredLimiters()
+	// 启动定期淘汰闲置限流器的 goroutine
+	go store.evictIdleLimiters()
 
-	return limiter
+	return store
 }
 
-// GetLimiter 获取指定 IP 的限流器
-func (i *IPRateLimiter) GetLimiter(ip string) *rate.Limiter {
-	i.mu.Lock()
-	defer i.mu.Unlock()
+// GetLimiter 获取 key 对应的限流器, 不存在时按规则创建
+func (s *RateLimiterStore) GetLimiter(key string, rule *service.RateLimitRule, now time.Time) *rate.Limiter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	limiter, exists := i.limiters[ip]
+	entry, exists := s.limiters[key]
 	if !exists {
-		limiter = rate.NewLimiter(i.rate, i.burst)
-		i.limiters[ip] = limiter
+		limit := rule.Limit()
+		entry = &limiterEntry{
+			limiter: rate.NewLimiter(limit, rule.Burst),
+			idleTTL: time.Duration(float64(rule.Burst)/float64(limit)*float64(time.Second)) + limiterEvictInterval,
+		}
+		s.limiters[key] = entry
 	}
+	entry.lastSeen = now
 
-	return limiter
+	return entry.limiter
 }
 
-// cleanupExpiredLimiters 定期清理长时间未使用的限流器，防止内存泄漏
-func (i *IPRateLimiter) cleanupExpiredLimiters() {
-	ticker := time.NewTicker(5 * time.Minute)
+// Len 返回当前限流桶数量
+func (s *RateLimiterStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.limiters)
+}
+
+// evictIdleLimiters 定期淘汰闲置的限流器, 防止内存泄漏
+// 只淘汰已回满的桶, 不会重置活跃客户端的限流状态
+func (s *RateLimiterStore) evictIdleLimiters() {
+	ticker := time.NewTicker(limiterEvictInterval)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		i.mu.Lock()
-		// 清空所有限流器，下次请求时会重新创建
-		// 这样可以释放长时间不活跃的 IP 占用的内存
-		i.limiters = make(map[string]*rate.Limiter)
-		i.mu.Unlock()
+	for now := range ticker.C {
+		s.mu.Lock()
+		for key, entry := range s.limiters {
+			if now.Sub(entry.lastSeen) > entry.idleTTL {
+				delete(s.limiters, key)
+			}
+		}
+		s.mu.Unlock()
 	}
 }
 
-var ipLimiter = NewIPRateLimiter(rateLimitPerSecond, rateLimitBurst)
+var limiterStore = NewRateLimiterStore()
 
+// RateLimiter 按限流策略限制请求
+// 策略来自 service.RateLimitService (端点 > referer > 全局 > 配置表默认值), 默认按客户端 IP 分桶, IPv6 按前缀聚合
+// 携带 API Key 的请求改用 Key 的专属限流, Key 没有专属限流时沿用上述策略, 显式 unlimited 的 Key 不限流
+// 响应带 X-RateLimit-Limit / X-RateLimit-Remaining / X-RateLimit-Reset, 被限流时额外返回 Retry-After
 func RateLimiter(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// 从 context 中获取真实 IP（由 RealIPMiddleware 提供）
-		// 使用 middleware.GetRealIP 复用 RealIPMiddleware 的结果
-		ip := GetRealIP(r)
+		// 携带 API Key 的请求使用 Key 的专属限流, 替代按 IP 的策略
+		var rule *service.RateLimitRule
+		apiKey := GetAPIKey(r)
+		if apiKey != nil {
+			rule = service.RateLimitRuleFor(apiKey)
+		}
+		if rule == nil && (apiKey == nil || !apiKey.Unlimited) {
+			refererDomain := ""
+			if r.Referer() != "" {
+				refererDomain = service.GetDomainStatsService().ExtractDomain(r.Referer())
+			}
+			rule = service.GetRateLimitService().Resolve(r.URL.Path, refererDomain)
+		}
+		if rule == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
 
-		// 获取该 IP 的限流器
-		limiter := ipLimiter.GetLimiter(ip)
+		key := rule.Key
+		if !rule.Shared {
+			// 从 context 中获取真实 IP（由 RealIPMiddleware 提供）
+			key += "|" + clientBucketKey(GetRealIP(r), rule.IPv6Prefix)
+		}
 
-		if !limiter.Allow() {
+		now := time.Now()
+		limiter := limiterStore.GetLimiter(key, rule, now)
+		allowed := limiter.AllowN(now, 1)
+		tokens := limiter.TokensAt(now)
+		limit := float64(limiter.Limit())
+
+		h := w.Header()
+		h.Set("X-RateLimit-Limit", strconv.Itoa(rule.Requests))
+		h.Set("X-RateLimit-Remaining", strconv.Itoa(int(math.Max(0, math.Floor(tokens)))))
+		h.Set("X-RateLimit-Reset", strconv.Itoa(int(math.Ceil((float64(rule.Burst)-tokens)/limit))))
+
+		if !allowed {
+			retryAfter := int(math.Ceil((1 - tokens) / limit))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			h.Set("Retry-After", strconv.Itoa(retryAfter))
 			http.Error(w, "Too many requests", http.StatusTooManyRequests)
 			return
 		}
@@ -87,3 +140,16 @@ func RateLimiter(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// clientBucketKey 计算客户端的限流 key: IPv4 按单个地址, IPv6 按前缀聚合 (同一 /64 通常属于同一用户)
+func clientBucketKey(ip string, ipv6Prefix int) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil || addr.Is4() || ipv6Prefix >= 128 {
+		return ip
+	}
+	prefix, err := addr.Prefix(ipv6Prefix)
+	if err != nil {
+		return ip
+	}
+	return prefix.String()
+}
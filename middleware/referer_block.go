@@ -2,42 +2,43 @@ package middleware
 
 import (
 	"net/http"
-	"strings"
 
 	"random-api-go/service"
 )
 
-// RefererBlockMiddleware 拦截来自被禁用域名的随机端点请求
-// 仅作用于"非白名单路径" (与 RandomEndpointBrowserOnlyMiddleware 的判定一致)
-// 命中黑名单 → 直接返回 403, 不进入统计中间件
+// RefererBlockMiddleware 拦截来自被禁用域名的随机端点请求, 并执行端点的 referer 白名单
+// 仅作用于随机端点路径 (见 isRandomEndpointPath)
+// 命中黑名单 (精确/后缀/可注册域名/正则, 可限定路径) → 直接返回 403, 不进入统计中间件
+// 不在端点白名单内 → 跳转到端点配置的回退图片, 未配置则返回 403 (签名链接跳过白名单)
 func RefererBlockMiddleware(next http.Handler) http.Handler {
-	whitelistPrefixes := []string{
-		"/api/",
-		"/_next/",
-		"/static/",
-		"/favicon.ico",
-		"/admin",
-	}
-
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
-		if path == "/" {
+		if !isRandomEndpointPath(path) {
 			next.ServeHTTP(w, r)
 			return
 		}
-		for _, p := range whitelistPrefixes {
-			if strings.HasPrefix(path, p) || path == p {
-				next.ServeHTTP(w, r)
-				return
-			}
-		}
 
 		svc := service.GetDomainStatsService()
 		domain := svc.ExtractDomain(r.Referer())
-		if svc.IsBlocked(domain) {
+		if svc.CheckBlocked(domain, path) {
 			http.Error(w, "Referer host has been blocked", http.StatusForbidden)
 			return
 		}
+
+		// 签名链接已在签名中约束 referer, 不再校验端点白名单
+		if IsSignedRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if ok, fallback := service.GetEndpointPolicyService().CheckReferer(path, r.Referer(), r.Host); !ok {
+			if fallback != "" {
+				http.Redirect(w, r, fallback, http.StatusFound)
+				return
+			}
+			http.Error(w, "Referer host is not allowed", http.StatusForbidden)
+			return
+		}
 		next.ServeHTTP(w, r)
 	})
 }
@@ -15,6 +15,8 @@ type APIEndpoint struct {
 	IsActive       bool           `json:"is_active" gorm:"default:true"`
 	ShowOnHomepage bool           `json:"show_on_homepage" gorm:"default:true"`
 	SortOrder      int            `json:"sort_order" gorm:"default:0;index"` // 排序字段，数值越小越靠前
+	RequestTimeout int            `json:"request_timeout" gorm:"default:0"`  // 单次请求超时(秒)，0 表示使用全局默认值
+	Managed        bool           `json:"managed" gorm:"default:false"`      // 由声明式配置文件管理
 	CreatedAt      time.Time      `json:"created_at"`
 	UpdatedAt      time.Time      `json:"updated_at"`
 	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
@@ -33,6 +35,7 @@ type DataSource struct {
 	Config     string         `json:"config" gorm:"not null"`
 	IsActive   bool           `json:"is_active" gorm:"default:true"`
 	LastSync   *time.Time     `json:"last_sync,omitempty"`
+	Managed    bool           `json:"managed" gorm:"default:false"` // 由声明式配置文件管理
 	CreatedAt  time.Time      `json:"created_at"`
 	UpdatedAt  time.Time      `json:"updated_at"`
 	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
@@ -42,27 +45,87 @@ type DataSource struct {
 }
 
 // URLReplaceRule URL替换规则模型
+// 按 Priority 升序依次执行，同优先级按 ID 升序
 type URLReplaceRule struct {
-	ID         uint           `json:"id" gorm:"primaryKey"`
-	EndpointID *uint          `json:"endpoint_id" gorm:"index"` // 可以为空，表示全局规则
-	Name       string         `json:"name" gorm:"not null"`
-	FromURL    string         `json:"from_url" gorm:"not null"`
-	ToURL      string         `json:"to_url" gorm:"not null"`
-	IsActive   bool           `json:"is_active" gorm:"default:true"`
-	CreatedAt  time.Time      `json:"created_at"`
-	UpdatedAt  time.Time      `json:"updated_at"`
-	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	EndpointID  *uint          `json:"endpoint_id" gorm:"index"` // 可以为空，表示全局规则
+	Name        string         `json:"name" gorm:"not null"`
+	Mode        string         `json:"mode" gorm:"default:'literal'"` // 匹配模式: literal / regex / prefix / host
+	FromURL     string         `json:"from_url" gorm:"not null"`      // literal: 子串; regex: 正则; prefix: 前缀; host: 主机名
+	ToURL       string         `json:"to_url" gorm:"not null"`        // regex 模式支持 $1 等捕获组引用
+	Priority    int            `json:"priority" gorm:"default:0;index"`
+	StopOnMatch bool           `json:"stop_on_match" gorm:"default:false"` // 命中后不再执行后续规则
+	IsActive    bool           `json:"is_active" gorm:"default:true"`
+	Managed     bool           `json:"managed" gorm:"default:false"` // 由声明式配置文件管理
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// 关联
+	Endpoint *APIEndpoint `json:"endpoint,omitempty" gorm:"foreignKey:EndpointID"`
+}
+
+// URLSigner CDN URL签名配置，在URL替换之后对最终跳转地址签名
+// 端点配置优先于全局配置，每个URL最多签名一次
+type URLSigner struct {
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	EndpointID  *uint          `json:"endpoint_id" gorm:"index"` // 可以为空，表示全局签名配置
+	Name        string         `json:"name" gorm:"not null"`
+	Algorithm   string         `json:"algorithm" gorm:"not null"` // type_a / type_b / type_c / hmac_query
+	Secret      string         `json:"secret" gorm:"not null"`
+	TTL         int            `json:"ttl"`          // 签名有效期(秒)，只用于 hmac_query (写入 expires，未填写时为 3600)，其他算法必须为 0
+	ParamName   string         `json:"param_name"`   // 签名参数名，type_a 默认 auth_key，hmac_query 默认 token
+	HostPattern string         `json:"host_pattern"` // 仅对匹配的主机签名（支持 *.example.com），空表示全部
+	IsActive    bool           `json:"is_active" gorm:"default:true"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
 
 	// 关联
 	Endpoint *APIEndpoint `json:"endpoint,omitempty" gorm:"foreignKey:EndpointID"`
 }
 
+// EndpointPolicy 端点访问策略（每个端点至多一条，没有记录时使用 service.DefaultEndpointPolicy）
+type EndpointPolicy struct {
+	ID         uint `json:"id" gorm:"primaryKey"`
+	EndpointID uint `json:"endpoint_id" gorm:"uniqueIndex;not null"`
+
+	// Referer 白名单（防盗链）
+	RefererAllowlistEnabled bool     `json:"referer_allowlist_enabled"`
+	RefererAllowlist        []string `json:"referer_allowlist" gorm:"serializer:json"` // 支持 example.com、*.example.com、.example.com
+	AllowEmptyReferer       bool     `json:"allow_empty_referer"`                      // 无 Referer 的请求是否放行
+	RefererFallbackURL      string   `json:"referer_fallback_url"`                     // 校验失败时跳转的图片地址，空则返回 403
+
+	// API Key
+	RequireAPIKey bool `json:"require_api_key"` // 仅允许携带有效 API Key 的请求
+
+	// 签名链接
+	RequireSignature bool `json:"require_signature"` // 仅允许携带有效签名 (或有效 API Key) 的请求
+
+	// 机器人策略
+	BotMode          string   `json:"bot_mode"`                            // off / browser_only / block_bots，空表示使用配置 bot_policy_default_mode
+	BotAllowUA       []string `json:"bot_allow_ua" gorm:"serializer:json"` // 放行的 UA 关键字（不区分大小写）
+	BotDenyUA        []string `json:"bot_deny_ua" gorm:"serializer:json"`  // 拦截的 UA 关键字，优先于其他规则
+	BlockPreviewBots bool     `json:"block_preview_bots"`                  // 拦截链接预览爬虫（默认放行 Discord、Telegram、Slack、Twitter 等）
+
+	// CORS（覆盖全局 cors_* 配置）
+	CORSMode    string   `json:"cors_mode"`                           // enabled / disabled，空表示使用全局 cors_enabled
+	CORSOrigins []string `json:"cors_origins" gorm:"serializer:json"` // 为空时使用全局 cors_origins
+	CORSMethods []string `json:"cors_methods" gorm:"serializer:json"` // 为空时使用全局 cors_methods
+	CORSHeaders []string `json:"cors_headers" gorm:"serializer:json"` // 为空时使用全局 cors_headers
+	CORSMaxAge  int      `json:"cors_max_age"`                        // 预检缓存秒数，0 表示使用全局 cors_max_age
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
 // Config 通用配置表
 type Config struct {
 	ID        uint      `json:"id" gorm:"primaryKey"`
 	Key       string    `json:"key" gorm:"uniqueIndex;not null"` // 配置键，如 "homepage_content"
 	Value     string    `json:"value" gorm:"type:text"`          // 配置值
 	Type      string    `json:"type" gorm:"default:'string'"`    // 配置类型：string, json, number, boolean
+	Managed   bool      `json:"managed" gorm:"default:false"`    // 由声明式配置文件管理
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -143,16 +206,96 @@ type DomainStats struct {
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
-// BlockedDomain 被禁用的来源域名(命中后拒绝访问随机端点)
+// BlockedDomain 被禁用的来源域名规则(命中后拒绝访问随机端点)
+// 同一 (domain, match_type, path) 只允许一条
 type BlockedDomain struct {
 	ID        uint           `json:"id" gorm:"primaryKey"`
-	Domain    string         `json:"domain" gorm:"uniqueIndex;not null"`
+	Domain    string         `json:"domain" gorm:"uniqueIndex:idx_blocked_domain_rule;not null"`                     // 匹配值: 域名 / 后缀 / 可注册域名 / 正则
+	MatchType string         `json:"match_type" gorm:"uniqueIndex:idx_blocked_domain_rule;not null;default:'exact'"` // exact / suffix / etld1 / regex
+	Path      string         `json:"path" gorm:"uniqueIndex:idx_blocked_domain_rule;not null;default:''"`            // 仅作用于该端点路径, 空表示全部
 	Reason    string         `json:"reason"`
+	Source    string         `json:"source" gorm:"not null;default:'manual'"` // manual: 手动添加; auto: 滥用检测自动添加
+	Managed   bool           `json:"managed" gorm:"default:false"`            // 由声明式配置文件管理
+	ExpiresAt *time.Time     `json:"expires_at" gorm:"index"`                 // 到期后自动解除, 为空表示永久
+	HitCount  uint64         `json:"hit_count" gorm:"default:0"`              // 累计拦截次数
+	LastHitAt *time.Time     `json:"last_hit_at"`                             // 最后一次拦截时间
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
+// AbuseEvent 滥用检测事件 (自动禁用记录)
+type AbuseEvent struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	Domain       string    `json:"domain" gorm:"index;not null"`
+	Rule         string    `json:"rule" gorm:"not null"` // rpm / daily / share / new_domain_spike
+	Value        float64   `json:"value"`                // 触发时的实际值
+	Threshold    float64   `json:"threshold"`            // 规则阈值
+	Reason       string    `json:"reason"`
+	BlockedUntil time.Time `json:"blocked_until"`
+	CreatedAt    time.Time `json:"created_at" gorm:"index"`
+}
+
+// IPRule IP/CIDR 访问规则 (作用于随机端点)
+// 端点规则优先于全局规则; 同一范围内按最长前缀匹配, 存在 allow 规则时未命中的地址一律拒绝
+type IPRule struct {
+	ID         uint           `json:"id" gorm:"primaryKey"`
+	EndpointID *uint          `json:"endpoint_id" gorm:"index"` // 可以为空，表示全局规则
+	CIDR       string         `json:"cidr" gorm:"not null"`     // 单个 IP 或 CIDR, 保存时规范化
+	Action     string         `json:"action" gorm:"not null"`   // allow / deny
+	Reason     string         `json:"reason"`
+	IsActive   bool           `json:"is_active" gorm:"default:true"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// 关联
+	Endpoint *APIEndpoint `json:"endpoint,omitempty" gorm:"foreignKey:EndpointID"`
+}
+
+// RateLimitPolicy 限流策略
+// 生效顺序: 端点策略 > referer 策略 > 全局策略 > 配置表 rate_limit_* 默认值, 每个请求只使用一条策略
+type RateLimitPolicy struct {
+	ID             uint           `json:"id" gorm:"primaryKey"`
+	Name           string         `json:"name" gorm:"not null"`
+	Scope          string         `json:"scope" gorm:"index;not null"` // global / endpoint / referer
+	EndpointID     *uint          `json:"endpoint_id" gorm:"index"`    // scope=endpoint 时必填
+	RefererPattern string         `json:"referer_pattern"`             // scope=referer 时必填, 支持 *.example.com
+	Requests       int            `json:"requests"`                    // 窗口内允许的请求数, 0 表示不限流
+	WindowSeconds  int            `json:"window_seconds"`              // 窗口长度(秒)
+	Burst          int            `json:"burst"`                       // 突发容量, 0 表示等于 requests
+	IPv6Prefix     int            `json:"ipv6_prefix"`                 // IPv6 按前缀聚合计数, 0 表示 /64, 128 表示不聚合
+	Shared         bool           `json:"shared"`                      // true: 命中该策略的所有请求共用一个桶; false: 按客户端 IP 分桶
+	IsActive       bool           `json:"is_active" gorm:"default:true"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// 关联
+	Endpoint *APIEndpoint `json:"endpoint,omitempty" gorm:"foreignKey:EndpointID"`
+}
+
+// APIKey 访问随机端点的 API Key (只保存哈希, 明文仅在创建时返回一次)
+type APIKey struct {
+	ID                 uint           `json:"id" gorm:"primaryKey"`
+	Name               string         `json:"name" gorm:"not null"`
+	Owner              string         `json:"owner"`
+	KeyHash            string         `json:"-" gorm:"uniqueIndex;not null"`               // sha256(明文) 十六进制
+	Prefix             string         `json:"prefix" gorm:"not null"`                      // 明文前缀, 用于在列表中辨认
+	AllowedEndpointIDs []uint         `json:"allowed_endpoint_ids" gorm:"serializer:json"` // 为空表示全部端点
+	RateRequests       int            `json:"rate_requests"`                               // 专属限流: 窗口内请求数, 0 表示沿用按 IP 的限流策略
+	RateWindowSeconds  int            `json:"rate_window_seconds"`                         // 专属限流窗口(秒)
+	Burst              int            `json:"burst"`                                       // 突发容量, 0 表示等于 rate_requests
+	Unlimited          bool           `json:"unlimited" gorm:"default:false"`              // 显式关闭限流 (不受专属限流与按 IP 策略限制)
+	DailyQuota         int            `json:"daily_quota"`                                 // 每日请求配额, 0 表示不限
+	ExpiresAt          *time.Time     `json:"expires_at"`                                  // 为空表示永不过期
+	Revoked            bool           `json:"revoked"`
+	LastUsedAt         *time.Time     `json:"last_used_at"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
 // DailyDomainStats 每日域名访问统计模型
 // 按 (date, domain, path) 三维统计当日访问次数
 type DailyDomainStats struct {
@@ -166,6 +309,127 @@ type DailyDomainStats struct {
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
+// DailyAPIKeyUsage 每日 API Key 用量统计
+// 按 (date, api_key_id, path) 三维统计当日请求次数
+type DailyAPIKeyUsage struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	APIKeyID  uint      `json:"api_key_id" gorm:"uniqueIndex:idx_date_key_path;index;not null"`
+	Path      string    `json:"path" gorm:"uniqueIndex:idx_date_key_path;not null;default:''"`
+	Date      time.Time `json:"date" gorm:"uniqueIndex:idx_date_key_path;index;not null"`
+	Count     uint64    `json:"count" gorm:"default:0"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DailyBotBlockStats 每日机器人拦截统计
+// 按 (date, path, reason) 三维统计当日拦截次数
+type DailyBotBlockStats struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Date      time.Time `json:"date" gorm:"uniqueIndex:idx_date_path_reason;index;not null"`
+	Path      string    `json:"path" gorm:"uniqueIndex:idx_date_path_reason;not null;default:''"`
+	Reason    string    `json:"reason" gorm:"uniqueIndex:idx_date_path_reason;not null"` // deny_ua / not_browser / bot / preview_bot
+	Count     uint64    `json:"count" gorm:"default:0"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AdminUser 管理后台允许名单
+// Identifier 形如 id:123 / username:alice / email:a@example.com
+type AdminUser struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Identifier  string    `json:"identifier" gorm:"uniqueIndex;not null"`
+	Role        string    `json:"role" gorm:"default:'admin'"` // viewer / editor / admin
+	EndpointIDs string    `json:"endpoint_ids"`                // 逗号分隔的端点 ID, 限定 editor 可管理的端点, 为空表示全部
+	Note        string    `json:"note"`
+	CreatedBy   string    `json:"created_by"` // 添加者用户名
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// LocalAdmin 本地管理员账号 (不依赖 OAuth 提供方, 断网或提供方故障时仍可登录)
+type LocalAdmin struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	Username     string     `json:"username" gorm:"uniqueIndex;not null"`
+	PasswordHash string     `json:"-" gorm:"not null"` // bcrypt
+	TOTPSecret   string     `json:"-"`                 // base32, 开启二次验证前为待确认的密钥
+	TOTPEnabled  bool       `json:"totp_enabled" gorm:"default:false"`
+	TOTPLastStep int64      `json:"-"`                           // 最近一次使用的 TOTP 时间步, 防止验证码重放
+	Role         string     `json:"role" gorm:"default:'admin'"` // viewer / editor / admin
+	EndpointIDs  string     `json:"endpoint_ids"`                // 逗号分隔的端点 ID, 为空表示全部
+	Disabled     bool       `json:"disabled" gorm:"default:false"`
+	LastLoginAt  *time.Time `json:"last_login_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// AdminSession 服务端签发的管理后台会话, 令牌只保存哈希
+type AdminSession struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	TokenHash    string    `json:"-" gorm:"uniqueIndex;not null"`
+	Source       string    `json:"source" gorm:"not null"` // oauth / local
+	LocalAdminID *uint     `json:"local_admin_id" gorm:"index"`
+	Subject      string    `json:"subject" gorm:"index"` // OAuth 用户 ID 或 local:{id}
+	Username     string    `json:"username"`
+	Nickname     string    `json:"nickname"`
+	Email        string    `json:"email"`
+	Avatar       string    `json:"avatar"`
+	IP           string    `json:"ip"`
+	UserAgent    string    `json:"user_agent"`
+	ExpiresAt    time.Time `json:"expires_at" gorm:"index"`
+	LastSeenAt   time.Time `json:"last_seen_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// PersonalAccessToken 个人访问令牌, 供脚本与 CI 调用管理接口 (数据库只保存哈希)
+// 令牌的权限不超过创建者当前的角色与端点范围
+type PersonalAccessToken struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	Name        string     `json:"name" gorm:"not null"`
+	TokenHash   string     `json:"-" gorm:"uniqueIndex;not null"` // sha256(明文) 十六进制
+	Prefix      string     `json:"prefix" gorm:"not null"`        // 明文前缀, 用于在列表中辨认
+	Source      string     `json:"source" gorm:"not null"`        // 创建者来源: oauth / local
+	Subject     string     `json:"subject" gorm:"index;not null"` // 创建者 OAuth 用户 ID 或 local:{id}
+	Username    string     `json:"username"`
+	Email       string     `json:"email"`
+	Role        string     `json:"role" gorm:"not null"`                // 令牌角色: viewer / editor / admin
+	EndpointIDs []uint     `json:"endpoint_ids" gorm:"serializer:json"` // 为空表示沿用创建者的端点范围
+	ExpiresAt   *time.Time `json:"expires_at"`                          // 创建时必填 (默认有效期), 为空的旧令牌视为无效
+	RevokedAt   *time.Time `json:"revoked_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	LastUsedIP  string     `json:"last_used_ip"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// AuditEvent 管理操作审计记录 (谁在何时修改了什么)
+type AuditEvent struct {
+	ID         uint                   `json:"id" gorm:"primaryKey"`
+	Actor      string                 `json:"actor" gorm:"index"`                                 // 操作者用户名, 非管理后台发起的变更为 system
+	ActorID    string                 `json:"actor_id"`                                           // OAuth 用户 ID 或 local:{id}
+	Action     string                 `json:"action" gorm:"index;not null"`                       // create / update / delete / ...
+	TargetType string                 `json:"target_type" gorm:"index:idx_audit_target;not null"` // endpoint / data_source / ...
+	TargetID   string                 `json:"target_id" gorm:"index:idx_audit_target"`
+	Changes    map[string]AuditChange `json:"changes" gorm:"serializer:json"` // 变化的字段, 敏感值已脱敏
+	IP         string                 `json:"ip"`
+	CreatedAt  time.Time              `json:"created_at" gorm:"index"`
+}
+
+// EndpointRevision 端点配置版本, 保存端点及其数据源、URL 替换规则的完整快照
+type EndpointRevision struct {
+	ID         uint         `json:"id" gorm:"primaryKey"`
+	EndpointID uint         `json:"endpoint_id" gorm:"uniqueIndex:idx_endpoint_revision;not null"`
+	Revision   int          `json:"revision" gorm:"uniqueIndex:idx_endpoint_revision;not null"` // 端点内递增的版本号
+	Action     string       `json:"action"`                                                     // 产生此版本的操作, 如 update / data_source.update / restore
+	Actor      string       `json:"actor"`
+	Snapshot   *APIEndpoint `json:"snapshot,omitempty" gorm:"serializer:json"` // 列表接口不返回
+	CreatedAt  time.Time    `json:"created_at" gorm:"index"`
+}
+
+// AuditChange 单个字段的变更前后值 (新建时只有 after, 删除时只有 before)
+type AuditChange struct {
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
 // DomainStatsResult 域名聚合结果(汇总到 domain 维度)
 type DomainStatsResult struct {
 	Domain    string `json:"domain"`
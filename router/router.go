@@ -40,13 +40,14 @@ type StaticHandler interface {
 type AdminHandler interface {
 	// OAuth相关
 	GetOAuthConfig(w http.ResponseWriter, r *http.Request)
-	VerifyOAuthToken(w http.ResponseWriter, r *http.Request)
 	HandleOAuthCallback(w http.ResponseWriter, r *http.Request)
+	HandleOAuthLogin(w http.ResponseWriter, r *http.Request)
 
 	// 端点管理
 	HandleEndpoints(w http.ResponseWriter, r *http.Request)
 	HandleEndpointByID(w http.ResponseWriter, r *http.Request)
 	HandleEndpointDataSources(w http.ResponseWriter, r *http.Request)
+	HandleEndpointPolicy(w http.ResponseWriter, r *http.Request)
 	UpdateEndpointSortOrder(w http.ResponseWriter, r *http.Request)
 
 	// 数据源管理
@@ -58,6 +59,13 @@ type AdminHandler interface {
 	ListURLReplaceRules(w http.ResponseWriter, r *http.Request)
 	CreateURLReplaceRule(w http.ResponseWriter, r *http.Request)
 	HandleURLReplaceRuleByID(w http.ResponseWriter, r *http.Request)
+	TestURLReplaceRules(w http.ResponseWriter, r *http.Request)
+	ListURLSigners(w http.ResponseWriter, r *http.Request)
+	CreateURLSigner(w http.ResponseWriter, r *http.Request)
+	HandleURLSignerByID(w http.ResponseWriter, r *http.Request)
+
+	// 端点配置版本
+	HandleEndpointRevisions(w http.ResponseWriter, r *http.Request)
 
 	// 首页配置
 	GetHomePageConfig(w http.ResponseWriter, r *http.Request)
@@ -74,6 +82,64 @@ type AdminHandler interface {
 	GetDomainTrend(w http.ResponseWriter, r *http.Request)
 	UpdateDomainBlockStatus(w http.ResponseWriter, r *http.Request)
 	ListBlockedDomains(w http.ResponseWriter, r *http.Request)
+	CreateBlockedDomain(w http.ResponseWriter, r *http.Request)
+	DeleteBlockedDomain(w http.ResponseWriter, r *http.Request)
+	ListAbuseEvents(w http.ResponseWriter, r *http.Request)
+	GetBotBlockStats(w http.ResponseWriter, r *http.Request)
+
+	// IP规则
+	ListIPRules(w http.ResponseWriter, r *http.Request)
+	CreateIPRule(w http.ResponseWriter, r *http.Request)
+	HandleIPRuleByID(w http.ResponseWriter, r *http.Request)
+
+	// 限流策略
+	ListRateLimitPolicies(w http.ResponseWriter, r *http.Request)
+	CreateRateLimitPolicy(w http.ResponseWriter, r *http.Request)
+	HandleRateLimitPolicyByID(w http.ResponseWriter, r *http.Request)
+
+	// API Key
+	ListAPIKeys(w http.ResponseWriter, r *http.Request)
+	CreateAPIKey(w http.ResponseWriter, r *http.Request)
+	HandleAPIKeyByID(w http.ResponseWriter, r *http.Request)
+
+	// 签名链接
+	CreateSignedLink(w http.ResponseWriter, r *http.Request)
+
+	// 管理员名单
+	ListAdminUsers(w http.ResponseWriter, r *http.Request)
+	CreateAdminUser(w http.ResponseWriter, r *http.Request)
+	HandleAdminUserByID(w http.ResponseWriter, r *http.Request)
+
+	// 本地管理员账号
+	LocalAdminLogin(w http.ResponseWriter, r *http.Request)
+	ListLocalAdmins(w http.ResponseWriter, r *http.Request)
+	CreateLocalAdmin(w http.ResponseWriter, r *http.Request)
+	HandleLocalAdminByID(w http.ResponseWriter, r *http.Request)
+	SetupLocalTOTP(w http.ResponseWriter, r *http.Request)
+	EnableLocalTOTP(w http.ResponseWriter, r *http.Request)
+	DisableLocalTOTP(w http.ResponseWriter, r *http.Request)
+	ChangeLocalPassword(w http.ResponseWriter, r *http.Request)
+
+	// 会话
+	GetCurrentAdmin(w http.ResponseWriter, r *http.Request)
+	Logout(w http.ResponseWriter, r *http.Request)
+	LogoutAll(w http.ResponseWriter, r *http.Request)
+
+	// 个人访问令牌
+	ListAccessTokens(w http.ResponseWriter, r *http.Request)
+	CreateAccessToken(w http.ResponseWriter, r *http.Request)
+	HandleAccessTokenByID(w http.ResponseWriter, r *http.Request)
+
+	// 审计记录
+	ListAuditEvents(w http.ResponseWriter, r *http.Request)
+
+	// 配置导出导入
+	ExportConfig(w http.ResponseWriter, r *http.Request)
+	ImportConfig(w http.ResponseWriter, r *http.Request)
+
+	// 声明式配置文件
+	GetConfigFileStatus(w http.ResponseWriter, r *http.Request)
+	ReconcileConfigFile(w http.ResponseWriter, r *http.Request)
 }
 
 func New() *Router {
@@ -81,11 +147,15 @@ func New() *Router {
 		mux:            http.NewServeMux(),
 		authMiddleware: middleware.NewAuthMiddleware(),
 		middlewares: []func(http.Handler) http.Handler{
-			middleware.RealIPMiddleware,                    // 最先执行,获取真实 IP
-			middleware.RandomEndpointBrowserOnlyMiddleware, // 随机端点仅限浏览器访问
-			middleware.RefererBlockMiddleware,              // referer 黑名单拦截 (拦截后不进入指标统计)
-			middleware.MetricsMiddleware,                   // 记录指标 + 域名统计
-			middleware.RateLimiter,                         // 最后执行, 限流
+			middleware.RealIPMiddleware,       // 最先执行,获取真实 IP (仅采信可信代理的转发头)
+			middleware.IPRuleMiddleware,       // IP/CIDR 黑白名单拦截
+			middleware.CORSMiddleware,         // 跨域响应头, 预检请求在此应答
+			middleware.APIKeyMiddleware,       // 校验 API Key (有效 Key 跳过机器人策略)
+			middleware.SignedLinkMiddleware,   // 校验签名链接
+			middleware.BotPolicyMiddleware,    // 随机端点机器人策略 (默认仅限浏览器)
+			middleware.RefererBlockMiddleware, // referer 黑名单拦截 (拦截后不进入指标统计)
+			middleware.MetricsMiddleware,      // 记录指标 + 域名统计
+			middleware.RateLimiter,            // 最后执行, 按限流策略限流
 		},
 	}
 }
@@ -116,32 +186,59 @@ func (r *Router) SetupAllRoutes(handler Handler, adminHandler AdminHandler, stat
 
 // setupAdminRoutes 设置管理后台路由（私有方法）
 func (r *Router) setupAdminRoutes(adminHandler AdminHandler) {
-	// OAuth令牌验证API（保留，以防需要）- 不需要认证
-	r.HandleFunc("/api/admin/oauth-verify", adminHandler.VerifyOAuthToken)
 	// OAuth回调处理（使用API前缀以便区分前后端）- 不需要认证
 	r.HandleFunc("/api/admin/oauth/callback", adminHandler.HandleOAuthCallback)
+	// 发起OAuth登录（生成 state / PKCE 后跳转到提供方）- 不需要认证
+	r.HandleFunc("/api/admin/oauth/login", adminHandler.HandleOAuthLogin)
+	// 本地管理员账号密码登录 - 不需要认证 (失败次数过多会被限流)
+	r.HandleFunc("/api/admin/local/login", adminHandler.LocalAdminLogin)
+
+	// 会话路由 - 需要认证
+	r.HandleFunc("/api/admin/me", r.authMiddleware.RequireAuth(adminHandler.GetCurrentAdmin))
+	r.HandleFunc("/api/admin/logout", r.authMiddleware.RequireAuth(adminHandler.Logout))
+	r.HandleFunc("/api/admin/logout-all", r.authMiddleware.RequireAuth(adminHandler.LogoutAll))
+
+	// 以下路由按角色限制: viewer 只能查看统计, editor 管理指定端点的数据源, admin 拥有全部权限
+	// 处理器内会再次检查角色与端点范围
+	viewer := func(h http.HandlerFunc) http.HandlerFunc {
+		return r.authMiddleware.RequireRole(middleware.RoleViewer, h)
+	}
+	editor := func(h http.HandlerFunc) http.HandlerFunc {
+		return r.authMiddleware.RequireRole(middleware.RoleEditor, h)
+	}
+	admin := func(h http.HandlerFunc) http.HandlerFunc {
+		return r.authMiddleware.RequireRole(middleware.RoleAdmin, h)
+	}
 
-	// 管理后台API路由 - 需要认证
-	r.HandleFunc("/api/admin/endpoints", r.authMiddleware.RequireAuth(adminHandler.HandleEndpoints))
+	// 管理后台API路由 - 查看需要 editor, 创建需要 admin
+	r.HandleFunc("/api/admin/endpoints", r.authMiddleware.RequireRoleFor(middleware.RoleEditor, middleware.RoleAdmin, adminHandler.HandleEndpoints))
 
-	// 端点排序路由 - 需要认证
-	r.HandleFunc("/api/admin/endpoints/sort-order", r.authMiddleware.RequireAuth(adminHandler.UpdateEndpointSortOrder))
+	// 端点排序路由 - 需要 admin
+	r.HandleFunc("/api/admin/endpoints/sort-order", admin(adminHandler.UpdateEndpointSortOrder))
 
-	// 数据源路由 - 需要认证
-	r.HandleFunc("/api/admin/data-sources", r.authMiddleware.RequireAuth(adminHandler.CreateDataSource))
+	// 数据源路由 - 需要 editor
+	r.HandleFunc("/api/admin/data-sources", editor(adminHandler.CreateDataSource))
 
-	// 端点相关路由 - 需要认证
-	r.HandleFunc("/api/admin/endpoints/", r.authMiddleware.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+	// 端点相关路由 - 数据源需要 editor, 访问策略需要 admin, 端点与版本查看需要 editor、修改 (恢复) 需要 admin
+	endpointDataSources := editor(adminHandler.HandleEndpointDataSources)
+	endpointPolicy := admin(adminHandler.HandleEndpointPolicy)
+	endpointRevisions := r.authMiddleware.RequireRoleFor(middleware.RoleEditor, middleware.RoleAdmin, adminHandler.HandleEndpointRevisions)
+	endpointByID := r.authMiddleware.RequireRoleFor(middleware.RoleEditor, middleware.RoleAdmin, adminHandler.HandleEndpointByID)
+	r.HandleFunc("/api/admin/endpoints/", func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
 		if strings.Contains(path, "/data-sources") {
-			adminHandler.HandleEndpointDataSources(w, r)
+			endpointDataSources(w, r)
+		} else if strings.HasSuffix(path, "/policy") {
+			endpointPolicy(w, r)
+		} else if strings.Contains(path, "/revisions") {
+			endpointRevisions(w, r)
 		} else {
-			adminHandler.HandleEndpointByID(w, r)
+			endpointByID(w, r)
 		}
-	}))
+	})
 
-	// 数据源操作路由 - 需要认证
-	r.HandleFunc("/api/admin/data-sources/", r.authMiddleware.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+	// 数据源操作路由 - 需要 editor
+	r.HandleFunc("/api/admin/data-sources/", editor(func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
 		if strings.Contains(path, "/sync") {
 			adminHandler.SyncDataSource(w, r)
@@ -150,8 +247,8 @@ func (r *Router) setupAdminRoutes(adminHandler AdminHandler) {
 		}
 	}))
 
-	// URL替换规则路由 - 需要认证
-	r.HandleFunc("/api/admin/url-replace-rules", r.authMiddleware.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+	// URL替换规则路由 - 需要 admin (规则测试只读, editor 可用)
+	r.HandleFunc("/api/admin/url-replace-rules", admin(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodGet {
 			adminHandler.ListURLReplaceRules(w, r)
 		} else if r.Method == http.MethodPost {
@@ -160,10 +257,103 @@ func (r *Router) setupAdminRoutes(adminHandler AdminHandler) {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
 	}))
-	r.HandleFunc("/api/admin/url-replace-rules/", r.authMiddleware.RequireAuth(adminHandler.HandleURLReplaceRuleByID))
+	r.HandleFunc("/api/admin/url-replace-rules/", admin(adminHandler.HandleURLReplaceRuleByID))
+	r.HandleFunc("/api/admin/url-replace-rules/test", editor(adminHandler.TestURLReplaceRules))
+
+	// CDN URL签名路由 - 需要 admin
+	r.HandleFunc("/api/admin/url-signers", admin(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			adminHandler.ListURLSigners(w, r)
+		} else if r.Method == http.MethodPost {
+			adminHandler.CreateURLSigner(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	r.HandleFunc("/api/admin/url-signers/", admin(adminHandler.HandleURLSignerByID))
+
+	// IP规则路由 - 需要 admin
+	r.HandleFunc("/api/admin/ip-rules", admin(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			adminHandler.ListIPRules(w, r)
+		} else if r.Method == http.MethodPost {
+			adminHandler.CreateIPRule(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	r.HandleFunc("/api/admin/ip-rules/", admin(adminHandler.HandleIPRuleByID))
+
+	// 限流策略路由 - 需要 admin
+	r.HandleFunc("/api/admin/rate-limits", admin(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			adminHandler.ListRateLimitPolicies(w, r)
+		} else if r.Method == http.MethodPost {
+			adminHandler.CreateRateLimitPolicy(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	r.HandleFunc("/api/admin/rate-limits/", admin(adminHandler.HandleRateLimitPolicyByID))
+
+	// API Key路由 - 需要 admin
+	r.HandleFunc("/api/admin/api-keys", admin(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			adminHandler.ListAPIKeys(w, r)
+		} else if r.Method == http.MethodPost {
+			adminHandler.CreateAPIKey(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	r.HandleFunc("/api/admin/api-keys/", admin(adminHandler.HandleAPIKeyByID))
+
+	// 签名链接路由 - 需要 admin
+	r.HandleFunc("/api/admin/signed-links", admin(adminHandler.CreateSignedLink))
+
+	// 管理员名单路由 - 需要 admin
+	r.HandleFunc("/api/admin/users", admin(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			adminHandler.ListAdminUsers(w, r)
+		} else if r.Method == http.MethodPost {
+			adminHandler.CreateAdminUser(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	r.HandleFunc("/api/admin/users/", admin(adminHandler.HandleAdminUserByID))
+
+	// 本地管理员账号路由 - 需要 admin
+	r.HandleFunc("/api/admin/local-admins", admin(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			adminHandler.ListLocalAdmins(w, r)
+		} else if r.Method == http.MethodPost {
+			adminHandler.CreateLocalAdmin(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	r.HandleFunc("/api/admin/local-admins/", admin(adminHandler.HandleLocalAdminByID))
+	// 当前本地账号的二次验证与密码 - 任意角色
+	r.HandleFunc("/api/admin/local/totp/setup", viewer(adminHandler.SetupLocalTOTP))
+	r.HandleFunc("/api/admin/local/totp/enable", viewer(adminHandler.EnableLocalTOTP))
+	r.HandleFunc("/api/admin/local/totp/disable", viewer(adminHandler.DisableLocalTOTP))
+	r.HandleFunc("/api/admin/local/password", viewer(adminHandler.ChangeLocalPassword))
+
+	// 个人访问令牌路由 - 任意角色管理自己的令牌, admin 可查看与撤销全部令牌
+	r.HandleFunc("/api/admin/access-tokens", viewer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			adminHandler.ListAccessTokens(w, r)
+		} else if r.Method == http.MethodPost {
+			adminHandler.CreateAccessToken(w, r)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	r.HandleFunc("/api/admin/access-tokens/", viewer(adminHandler.HandleAccessTokenByID))
 
-	// 首页配置路由 - 需要认证
-	r.HandleFunc("/api/admin/home-config", r.authMiddleware.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+	// 首页配置路由 - 需要 admin
+	r.HandleFunc("/api/admin/home-config", admin(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodGet {
 			adminHandler.GetHomePageConfig(w, r)
 		} else {
@@ -171,9 +361,9 @@ func (r *Router) setupAdminRoutes(adminHandler AdminHandler) {
 		}
 	}))
 
-	// 通用配置管理路由 - 需要认证
-	r.HandleFunc("/api/admin/configs", r.authMiddleware.RequireAuth(adminHandler.ListConfigs))
-	r.HandleFunc("/api/admin/configs/", r.authMiddleware.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+	// 通用配置管理路由 - 需要 admin
+	r.HandleFunc("/api/admin/configs", admin(adminHandler.ListConfigs))
+	r.HandleFunc("/api/admin/configs/", admin(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodDelete {
 			adminHandler.DeleteConfigByKey(w, r)
 		} else {
@@ -181,12 +371,32 @@ func (r *Router) setupAdminRoutes(adminHandler AdminHandler) {
 		}
 	}))
 
-	// 域名统计路由 - 需要认证
-	r.HandleFunc("/api/admin/domain-stats", r.authMiddleware.RequireAuth(adminHandler.GetDomainStats))
-	r.HandleFunc("/api/admin/domain-stats/paths", r.authMiddleware.RequireAuth(adminHandler.GetDomainPathStats))
-	r.HandleFunc("/api/admin/domain-stats/trend", r.authMiddleware.RequireAuth(adminHandler.GetDomainTrend))
-	r.HandleFunc("/api/admin/domain-stats/block", r.authMiddleware.RequireAuth(adminHandler.UpdateDomainBlockStatus))
-	r.HandleFunc("/api/admin/blocked-domains", r.authMiddleware.RequireAuth(adminHandler.ListBlockedDomains))
+	// 域名统计路由 - 查看需要 viewer, 拦截需要 admin
+	r.HandleFunc("/api/admin/domain-stats", viewer(adminHandler.GetDomainStats))
+	r.HandleFunc("/api/admin/domain-stats/paths", viewer(adminHandler.GetDomainPathStats))
+	r.HandleFunc("/api/admin/domain-stats/trend", viewer(adminHandler.GetDomainTrend))
+	r.HandleFunc("/api/admin/domain-stats/block", admin(adminHandler.UpdateDomainBlockStatus))
+	r.HandleFunc("/api/admin/blocked-domains", r.authMiddleware.RequireRoleFor(middleware.RoleViewer, middleware.RoleAdmin, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			adminHandler.CreateBlockedDomain(w, r)
+		} else {
+			adminHandler.ListBlockedDomains(w, r)
+		}
+	}))
+	r.HandleFunc("/api/admin/blocked-domains/", admin(adminHandler.DeleteBlockedDomain))
+	r.HandleFunc("/api/admin/abuse-events", viewer(adminHandler.ListAbuseEvents))
+	r.HandleFunc("/api/admin/bot-stats", viewer(adminHandler.GetBotBlockStats))
+
+	// 审计记录路由 - 需要 admin
+	r.HandleFunc("/api/admin/audit", admin(adminHandler.ListAuditEvents))
+
+	// 配置导出导入路由 - 需要 admin
+	r.HandleFunc("/api/admin/export", admin(adminHandler.ExportConfig))
+	r.HandleFunc("/api/admin/import", admin(adminHandler.ImportConfig))
+
+	// 声明式配置文件路由 - 需要 admin
+	r.HandleFunc("/api/admin/config-file", admin(adminHandler.GetConfigFileStatus))
+	r.HandleFunc("/api/admin/config-file/reconcile", admin(adminHandler.ReconcileConfigFile))
 }
 
 func (r *Router) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
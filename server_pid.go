@@ -0,0 +1,12 @@
+package main
+
+import (
+	"path/filepath"
+
+	"random-api-go/config"
+)
+
+// serverPIDFile 服务运行时在数据目录写入的 PID 文件, 命令行修改数据库后据此通知服务
+func serverPIDFile() string {
+	return filepath.Join(config.Get().Storage.DataDir, "server.pid")
+}
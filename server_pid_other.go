@@ -0,0 +1,18 @@
+//go:build !unix
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// lockServerPIDFile 当前平台不支持文件锁与 SIGHUP, 不写入 PID 文件
+func lockServerPIDFile() (*os.File, error) {
+	return nil, nil
+}
+
+// notifyRunningServer 当前平台无法通知运行中的服务, 只输出提示
+func notifyRunningServer() {
+	fmt.Fprintln(os.Stderr, "warning: 当前平台无法自动通知运行中的服务, 服务运行中时需发送 SIGHUP 或重启才能读取本次修改")
+}
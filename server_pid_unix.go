@@ -0,0 +1,85 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// lockServerPIDFile 写入当前进程 PID 并在服务运行期间持有文件锁
+// 进程退出后锁自动释放, 命令行据此区分服务是否仍在运行, 不会把信号发给复用了旧 PID 的进程
+func lockServerPIDFile() (*os.File, error) {
+	f, err := os.OpenFile(serverPIDFile(), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		return nil, errors.New("another server is using this data directory")
+	}
+	if err := f.Truncate(0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// notifyRunningServer 命令行修改数据库后向运行中的服务发送 SIGHUP, 使其重新加载内存缓存
+// 服务未运行时无需处理; 无法确认或通知时输出提示, 由用户手动发送 SIGHUP 或重启服务
+func notifyRunningServer() {
+	pid, running, err := runningServerPID()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: 无法确认服务是否在运行 (%v), 服务运行中时需发送 SIGHUP 或重启才能读取本次修改\n", err)
+		return
+	}
+	if !running {
+		return
+	}
+	process, err := os.FindProcess(pid)
+	if err == nil {
+		err = process.Signal(syscall.SIGHUP)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: 通知服务 (PID %d) 重新加载失败: %v, 需发送 SIGHUP 或重启才能读取本次修改\n", pid, err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "已通知服务 (PID %d) 重新加载缓存\n", pid)
+}
+
+// runningServerPID 读取持有 PID 文件锁的服务进程, 文件锁空闲表示服务未运行
+func runningServerPID() (int, bool, error) {
+	f, err := os.Open(serverPIDFile())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, false, errors.New("PID file not found")
+		}
+		return 0, false, err
+	}
+	defer f.Close()
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_SH|syscall.LOCK_NB); err == nil {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		return 0, false, nil
+	} else if !errors.Is(err, syscall.EWOULDBLOCK) {
+		return 0, false, err
+	}
+
+	data, err := os.ReadFile(serverPIDFile())
+	if err != nil {
+		return 0, false, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, false, fmt.Errorf("invalid PID file content %q", strings.TrimSpace(string(data)))
+	}
+	return pid, true, nil
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"random-api-go/database"
+	"random-api-go/model"
+)
+
+// 滥用检测规则名称
+const (
+	AbuseRuleRPM            = "rpm"              // 单个来源域名每分钟请求数
+	AbuseRuleDaily          = "daily"            // 单个来源域名当日请求数
+	AbuseRuleShare          = "share"            // 单个来源域名占最近一分钟总流量的百分比
+	AbuseRuleNewDomainSpike = "new_domain_spike" // 新出现的来源域名突发流量
+)
+
+// abuseConfig 滥用检测阈值 (来自配置表, 阈值 <= 0 表示关闭该规则)
+type abuseConfig struct {
+	rpm              int
+	daily            int
+	sharePercent     int
+	shareMinRequests int
+	newDomainRPM     int
+	newDomainWindow  time.Duration
+	blockDuration    time.Duration
+	exempt           []string
+}
+
+// loadAbuseConfig 读取滥用检测配置; 未开启时返回 nil
+func loadAbuseConfig() *abuseConfig {
+	if database.GetConfig("abuse_detection_enabled", "false") != "true" {
+		return nil
+	}
+
+	var exempt []string
+	for _, p := range strings.Split(database.GetConfig("abuse_exempt_domains", ""), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			exempt = append(exempt, p)
+		}
+	}
+
+	return &abuseConfig{
+		rpm:              getIntConfig("abuse_rpm_threshold", 600),
+		daily:            getIntConfig("abuse_daily_threshold", 50000),
+		sharePercent:     getIntConfig("abuse_share_percent", 50),
+		shareMinRequests: getIntConfig("abuse_share_min_requests", 300),
+		newDomainRPM:     getIntConfig("abuse_new_domain_rpm", 120),
+		newDomainWindow:  time.Duration(getIntConfig("abuse_new_domain_hours", 24)) * time.Hour,
+		blockDuration:    time.Duration(getIntConfig("abuse_block_minutes", 60)) * time.Minute,
+		exempt:           exempt,
+	}
+}
+
+// abuseViolation 一次规则命中
+type abuseViolation struct {
+	rule      string
+	value     float64
+	threshold float64
+}
+
+// detectAbuse 根据本次 flush 的批次评估滥用规则, 命中后自动临时禁用来源域名并记录事件
+func (s *DomainStatsService) detectAbuse(batch map[domainPathKey]*DomainStatsBatch, today time.Time) {
+	cfg := loadAbuseConfig()
+	if cfg == nil {
+		return
+	}
+
+	perDomain := make(map[string]uint64)
+	var total uint64
+	for key, b := range batch {
+		perDomain[key.Domain] += b.Count
+		total += b.Count
+	}
+
+	// 过滤占位域名、豁免域名与已被禁用的域名
+	candidates := make([]string, 0, len(perDomain))
+	for domain := range perDomain {
+		if domain == "direct" || domain == "unknown" || s.IsBlocked(domain) || matchAnyHostPattern(cfg.exempt, domain) {
+			continue
+		}
+		candidates = append(candidates, domain)
+	}
+	if len(candidates) == 0 {
+		return
+	}
+	sort.Strings(candidates)
+
+	dailyCounts := map[string]uint64{}
+	if cfg.daily > 0 {
+		dailyCounts = s.dailyCountsFor(candidates, today)
+	}
+	firstSeen := map[string]time.Time{}
+	if cfg.newDomainRPM > 0 && cfg.newDomainWindow > 0 {
+		firstSeen = s.firstSeenFor(candidates)
+	}
+
+	now := time.Now()
+	for _, domain := range candidates {
+		count := perDomain[domain]
+		var v *abuseViolation
+
+		switch {
+		case cfg.rpm > 0 && count > uint64(cfg.rpm):
+			v = &abuseViolation{rule: AbuseRuleRPM, value: float64(count), threshold: float64(cfg.rpm)}
+		case cfg.daily > 0 && dailyCounts[domain] > uint64(cfg.daily):
+			v = &abuseViolation{rule: AbuseRuleDaily, value: float64(dailyCounts[domain]), threshold: float64(cfg.daily)}
+		case cfg.sharePercent > 0 && total >= uint64(cfg.shareMinRequests) &&
+			float64(count)*100/float64(total) > float64(cfg.sharePercent):
+			v = &abuseViolation{rule: AbuseRuleShare, value: float64(count) * 100 / float64(total), threshold: float64(cfg.sharePercent)}
+		case cfg.newDomainRPM > 0 && count > uint64(cfg.newDomainRPM) &&
+			!firstSeen[domain].IsZero() && now.Sub(firstSeen[domain]) < cfg.newDomainWindow:
+			v = &abuseViolation{rule: AbuseRuleNewDomainSpike, value: float64(count), threshold: float64(cfg.newDomainRPM)}
+		}
+
+		if v != nil {
+			s.autoBlock(domain, v, now.Add(cfg.blockDuration))
+		}
+	}
+}
+
+// autoBlock 自动禁用来源域名并记录事件
+func (s *DomainStatsService) autoBlock(domain string, v *abuseViolation, until time.Time) {
+	reason := fmt.Sprintf("auto: %s %.0f > %.0f", v.rule, v.value, v.threshold)
+
+	rule := &model.BlockedDomain{
+		Domain:    domain,
+		MatchType: BlockMatchExact,
+		Source:    BlockSourceAuto,
+		Reason:    reason,
+		ExpiresAt: &until,
+	}
+	if err := s.upsertBlockRule(context.Background(), rule); err != nil {
+		log.Printf("自动禁用域名 %s 失败: %v", domain, err)
+		return
+	}
+
+	event := model.AbuseEvent{
+		Domain:       domain,
+		Rule:         v.rule,
+		Value:        v.value,
+		Threshold:    v.threshold,
+		Reason:       reason,
+		BlockedUntil: until,
+	}
+	if err := database.DB.Create(&event).Error; err != nil {
+		log.Printf("记录滥用事件失败: %v", err)
+	}
+	log.Printf("检测到滥用, 已自动禁用域名 %s 至 %s (%s)", domain, until.Format("2006-01-02 15:04:05"), reason)
+}
+
+// dailyCountsFor 查询候选域名今日累计请求数 (本批次已 flush)
+func (s *DomainStatsService) dailyCountsFor(domains []string, today time.Time) map[string]uint64 {
+	var rows []struct {
+		Domain string
+		Count  uint64
+	}
+	if err := database.DB.Model(&model.DailyDomainStats{}).
+		Select("domain, SUM(count) as count").
+		Where("date = ? AND domain IN ?", today, domains).
+		Group("domain").
+		Scan(&rows).Error; err != nil {
+		log.Printf("查询域名当日请求数失败: %v", err)
+		return map[string]uint64{}
+	}
+	out := make(map[string]uint64, len(rows))
+	for _, r := range rows {
+		out[r.Domain] = r.Count
+	}
+	return out
+}
+
+// firstSeenFor 查询候选域名首次出现时间
+func (s *DomainStatsService) firstSeenFor(domains []string) map[string]time.Time {
+	var rows []model.DomainStats
+	if err := database.DB.Select("domain", "created_at").
+		Where("domain IN ?", domains).
+		Find(&rows).Error; err != nil {
+		log.Printf("查询域名首次出现时间失败: %v", err)
+		return map[string]time.Time{}
+	}
+	out := make(map[string]time.Time, len(domains))
+	for _, r := range rows {
+		if first, ok := out[r.Domain]; !ok || r.CreatedAt.Before(first) {
+			out[r.Domain] = r.CreatedAt
+		}
+	}
+	return out
+}
+
+// ListAbuseEvents 查询滥用事件 (按时间倒序)
+func (s *DomainStatsService) ListAbuseEvents(domain string, limit int) ([]model.AbuseEvent, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	query := database.DB.Order("created_at DESC").Limit(limit)
+	if domain != "" {
+		query = query.Where("domain = ?", strings.ToLower(domain))
+	}
+	var events []model.AbuseEvent
+	err := query.Find(&events).Error
+	return events, err
+}
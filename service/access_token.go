@@ -0,0 +1,204 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"random-api-go/database"
+	"random-api-go/model"
+
+	"gorm.io/gorm"
+)
+
+// AccessTokenPrefix 个人访问令牌前缀, 认证中间件据此与会话令牌区分
+const AccessTokenPrefix = "rat_"
+
+// accessTokenTouchInterval 最近使用时间的最小更新间隔, 避免每个请求都写库
+const accessTokenTouchInterval = time.Minute
+
+// 令牌有效期 (天) 的默认值, 可通过配置 access_token_default_days / access_token_max_days 修改
+const (
+	defaultAccessTokenDays    = 30
+	defaultAccessTokenMaxDays = 365
+)
+
+// ErrAccessTokenInvalid 令牌不存在、已过期、已撤销或创建者已失去管理权限
+var ErrAccessTokenInvalid = errors.New("invalid or expired access token")
+
+// CreateAccessToken 为当前管理员签发个人访问令牌, 返回明文令牌 (只在此时可见)
+// 角色为空时沿用创建者的角色; 角色与端点范围都不能超过创建者当前的权限
+// 未指定过期时间时使用默认有效期, 过期时间不能晚于最长有效期 (令牌不会永不过期)
+func CreateAccessToken(owner *OAuthUserInfo, ownerAccess *AdminAccess, token *model.PersonalAccessToken) (string, error) {
+	token.Name = strings.TrimSpace(token.Name)
+	if token.Name == "" {
+		return "", fmt.Errorf("name is required")
+	}
+
+	role := ownerAccess.Role
+	if strings.TrimSpace(token.Role) != "" {
+		var err error
+		if role, err = normalizeRole(token.Role); err != nil {
+			return "", err
+		}
+	}
+	if !ownerAccess.HasRole(role) {
+		return "", fmt.Errorf("role %s exceeds your own role %s", role, ownerAccess.Role)
+	}
+	if role == RoleAdmin && len(token.EndpointIDs) > 0 {
+		return "", fmt.Errorf("endpoint_ids only apply to viewer and editor tokens")
+	}
+	for _, id := range token.EndpointIDs {
+		if id == 0 || !ownerAccess.CanAccessEndpoint(id) {
+			return "", fmt.Errorf("no access to endpoint %d", id)
+		}
+	}
+
+	now := time.Now()
+	maxDays := getIntConfig("access_token_max_days", defaultAccessTokenMaxDays)
+	if maxDays <= 0 {
+		maxDays = defaultAccessTokenMaxDays
+	}
+	if token.ExpiresAt == nil {
+		days := getIntConfig("access_token_default_days", defaultAccessTokenDays)
+		if days <= 0 || days > maxDays {
+			days = min(defaultAccessTokenDays, maxDays)
+		}
+		expiresAt := now.AddDate(0, 0, days)
+		token.ExpiresAt = &expiresAt
+	}
+	if !token.ExpiresAt.After(now) {
+		return "", fmt.Errorf("expires_at must be in the future")
+	}
+	if token.ExpiresAt.After(now.AddDate(0, 0, maxDays)) {
+		return "", fmt.Errorf("expires_at must be within %d days", maxDays)
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	raw := AccessTokenPrefix + hex.EncodeToString(buf)
+
+	token.ID = 0
+	token.TokenHash = HashAPIKey(raw)
+	token.Prefix = raw[:len(AccessTokenPrefix)+8]
+	token.Source = owner.Source
+	token.Subject = owner.ID
+	token.Username = owner.Username
+	token.Email = owner.Email
+	token.Role = role
+	token.RevokedAt = nil
+	token.LastUsedAt = nil
+	token.LastUsedIP = ""
+	if err := database.DB.Create(token).Error; err != nil {
+		return "", fmt.Errorf("failed to create access token: %w", err)
+	}
+	return raw, nil
+}
+
+// ListAccessTokens 列出个人访问令牌 (不含明文), subject 为空时列出全部
+func ListAccessTokens(source, subject string) ([]model.PersonalAccessToken, error) {
+	query := database.DB.Order("id DESC")
+	if subject != "" {
+		query = query.Where("source = ? AND subject = ?", source, subject)
+	}
+	var tokens []model.PersonalAccessToken
+	if err := query.Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// RevokeAccessToken 撤销令牌, 记录保留以便查看最近使用情况
+func RevokeAccessToken(token *model.PersonalAccessToken) error {
+	if token.RevokedAt != nil {
+		return nil
+	}
+	now := time.Now()
+	if err := database.DB.Model(token).UpdateColumn("revoked_at", now).Error; err != nil {
+		return err
+	}
+	token.RevokedAt = &now
+	return nil
+}
+
+// ValidateAccessToken 校验个人访问令牌, 返回创建者与令牌的实际权限 (令牌角色与创建者当前权限取交集)
+// 创建者被移出管理员名单、禁用或删除后其令牌立即失效
+func ValidateAccessToken(raw, ip string) (*OAuthUserInfo, *AdminAccess, *model.PersonalAccessToken, error) {
+	if !strings.HasPrefix(raw, AccessTokenPrefix) {
+		return nil, nil, nil, ErrAccessTokenInvalid
+	}
+
+	var token model.PersonalAccessToken
+	if err := database.DB.Where("token_hash = ?", HashAPIKey(raw)).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, nil, ErrAccessTokenInvalid
+		}
+		return nil, nil, nil, err
+	}
+
+	// 没有过期时间的令牌 (有效期限制之前创建) 同样视为无效
+	now := time.Now()
+	if token.RevokedAt != nil || token.ExpiresAt == nil || !token.ExpiresAt.After(now) {
+		return nil, nil, nil, ErrAccessTokenInvalid
+	}
+
+	owner := &OAuthUserInfo{
+		ID:       token.Subject,
+		Username: token.Username,
+		Email:    token.Email,
+		Source:   token.Source,
+	}
+	ownerAccess := ResolveAdminAccess(owner)
+	if ownerAccess == nil {
+		return nil, nil, nil, ErrAccessTokenInvalid
+	}
+	access := accessTokenScope(&token, ownerAccess)
+	if access == nil {
+		return nil, nil, nil, ErrAccessTokenInvalid
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > accessTokenTouchInterval || token.LastUsedIP != ip {
+		database.DB.Model(&token).UpdateColumns(map[string]interface{}{"last_used_at": now, "last_used_ip": ip})
+		token.LastUsedAt = &now
+		token.LastUsedIP = ip
+	}
+	return owner, access, &token, nil
+}
+
+// accessTokenScope 计算令牌的实际权限: 角色取两者较低者, 端点范围取交集; 交集为空时返回 nil
+func accessTokenScope(token *model.PersonalAccessToken, owner *AdminAccess) *AdminAccess {
+	role := token.Role
+	if !owner.HasRole(role) {
+		role = owner.Role
+	}
+	if role == RoleAdmin {
+		return &AdminAccess{Role: role}
+	}
+
+	ownerIDs := owner.EndpointIDs
+	if owner.Role == RoleAdmin {
+		ownerIDs = nil
+	}
+	switch {
+	case len(token.EndpointIDs) == 0:
+		return &AdminAccess{Role: role, EndpointIDs: ownerIDs}
+	case len(ownerIDs) == 0:
+		return &AdminAccess{Role: role, EndpointIDs: token.EndpointIDs}
+	}
+
+	var ids []uint
+	for _, id := range token.EndpointIDs {
+		if owner.CanAccessEndpoint(id) {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return &AdminAccess{Role: role, EndpointIDs: ids}
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"random-api-go/database"
+	"random-api-go/model"
+)
+
+// 管理后台角色, 权限依次递增
+// viewer: 只能查看统计; editor: 管理指定端点的数据源; admin: 全部权限 (拦截、配置、用户等)
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+var roleLevels = map[string]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+}
+
+// AdminAccess 当前管理员的角色与可管理的端点范围
+type AdminAccess struct {
+	Role        string `json:"role"`
+	EndpointIDs []uint `json:"endpoint_ids,omitempty"` // 为空表示不限端点
+}
+
+// HasRole 是否具备指定角色 (高角色包含低角色的权限)
+func (a *AdminAccess) HasRole(role string) bool {
+	return a != nil && roleLevels[a.Role] >= roleLevels[role] && roleLevels[role] > 0
+}
+
+// CanAccessEndpoint 是否可以管理指定端点; admin 不受端点范围限制
+func (a *AdminAccess) CanAccessEndpoint(endpointID uint) bool {
+	if a == nil {
+		return false
+	}
+	if a.Role == RoleAdmin || len(a.EndpointIDs) == 0 {
+		return true
+	}
+	for _, id := range a.EndpointIDs {
+		if id == endpointID {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeRole 校验角色, 为空时默认 admin (兼容角色功能之前添加的管理员)
+func normalizeRole(role string) (string, error) {
+	role = strings.ToLower(strings.TrimSpace(role))
+	if role == "" {
+		return RoleAdmin, nil
+	}
+	if _, ok := roleLevels[role]; !ok {
+		return "", fmt.Errorf("invalid role %q, must be viewer, editor or admin", role)
+	}
+	return role, nil
+}
+
+// ParseEndpointIDs 解析逗号分隔的端点 ID 列表
+func ParseEndpointIDs(value string) ([]uint, error) {
+	var ids []uint
+	for _, item := range splitList(value) {
+		id, err := strconv.ParseUint(item, 10, 32)
+		if err != nil || id == 0 {
+			return nil, fmt.Errorf("invalid endpoint id %q", item)
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
+
+// normalizeEndpointIDs 校验端点范围并规范化为排序去重后的逗号分隔形式
+func normalizeEndpointIDs(value string) (string, error) {
+	ids, err := ParseEndpointIDs(value)
+	if err != nil {
+		return "", err
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	parts := make([]string, 0, len(ids))
+	for i, id := range ids {
+		if i > 0 && id == ids[i-1] {
+			continue
+		}
+		parts = append(parts, strconv.FormatUint(uint64(id), 10))
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// newAdminAccess 由已规范化的角色与端点范围构建权限
+func newAdminAccess(role, endpointIDs string) AdminAccess {
+	if role == "" {
+		role = RoleAdmin
+	}
+	ids, _ := ParseEndpointIDs(endpointIDs)
+	return AdminAccess{Role: role, EndpointIDs: ids}
+}
+
+// ResolveAdminAccess 获取登录用户的角色与端点范围, 返回 nil 表示无权访问管理后台
+// OAuth 用户按管理员名单解析; 本地账号使用账号上的角色
+func ResolveAdminAccess(user *OAuthUserInfo) *AdminAccess {
+	if id, ok := LocalAdminID(user); ok {
+		var admin model.LocalAdmin
+		if err := database.DB.First(&admin, id).Error; err != nil || admin.Disabled {
+			return nil
+		}
+		access := newAdminAccess(admin.Role, admin.EndpointIDs)
+		return &access
+	}
+	return GetAdminUserService().Access(user.Identity())
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"random-api-go/database"
+	"random-api-go/model"
+
+	"gorm.io/gorm"
+)
+
+// 会话来源
+const (
+	SessionSourceOAuth = "oauth"
+	SessionSourceLocal = "local"
+)
+
+// AdminSessionPrefix 会话令牌前缀, 便于与 API Key 等其他令牌区分
+const AdminSessionPrefix = "ras_"
+
+// adminSessionTouchInterval 最近访问时间的最小更新间隔, 避免每个请求都写库
+const adminSessionTouchInterval = time.Minute
+
+// ErrAdminSessionInvalid 会话不存在、已过期或已撤销
+var ErrAdminSessionInvalid = errors.New("invalid or expired session")
+
+// adminSessionTTL 会话有效期, 配置项 admin_session_ttl_hours (默认 7 天)
+func adminSessionTTL() time.Duration {
+	hours := getIntConfig("admin_session_ttl_hours", 168)
+	if hours <= 0 {
+		hours = 168
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// CreateAdminSession 为已通过认证的用户签发会话, 返回原始令牌 (只在此时可见)
+func CreateAdminSession(user *OAuthUserInfo, localAdminID *uint, ip, userAgent string) (string, *model.AdminSession, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, err
+	}
+	token := AdminSessionPrefix + hex.EncodeToString(buf)
+
+	now := time.Now()
+	session := &model.AdminSession{
+		TokenHash:    HashAPIKey(token),
+		Source:       user.Source,
+		LocalAdminID: localAdminID,
+		Subject:      user.ID,
+		Username:     user.Username,
+		Nickname:     user.Nickname,
+		Email:        user.Email,
+		Avatar:       user.Avatar,
+		IP:           ip,
+		UserAgent:    truncateString(userAgent, 255),
+		ExpiresAt:    now.Add(adminSessionTTL()),
+		LastSeenAt:   now,
+	}
+	if err := database.DB.Create(session).Error; err != nil {
+		return "", nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	// 顺带清理过期会话
+	if err := database.DB.Where("expires_at < ?", now).Delete(&model.AdminSession{}).Error; err != nil {
+		log.Printf("Failed to cleanup expired admin sessions: %v", err)
+	}
+	return token, session, nil
+}
+
+// ValidateAdminSession 校验会话令牌, 返回会话对应的用户
+// 本地账号被禁用或删除后其会话立即失效
+func ValidateAdminSession(token string) (*OAuthUserInfo, *model.AdminSession, error) {
+	if !strings.HasPrefix(token, AdminSessionPrefix) {
+		return nil, nil, ErrAdminSessionInvalid
+	}
+
+	var session model.AdminSession
+	if err := database.DB.Where("token_hash = ?", HashAPIKey(token)).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrAdminSessionInvalid
+		}
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	if now.After(session.ExpiresAt) {
+		database.DB.Delete(&session)
+		return nil, nil, ErrAdminSessionInvalid
+	}
+
+	if session.Source == SessionSourceLocal {
+		var admin model.LocalAdmin
+		if session.LocalAdminID == nil || database.DB.First(&admin, *session.LocalAdminID).Error != nil || admin.Disabled {
+			return nil, nil, ErrAdminSessionInvalid
+		}
+	}
+
+	if now.Sub(session.LastSeenAt) > adminSessionTouchInterval {
+		database.DB.Model(&session).UpdateColumn("last_seen_at", now)
+	}
+
+	return &OAuthUserInfo{
+		ID:       session.Subject,
+		Username: session.Username,
+		Nickname: session.Nickname,
+		Email:    session.Email,
+		Avatar:   session.Avatar,
+		Source:   session.Source,
+	}, &session, nil
+}
+
+// RevokeAdminSession 撤销单个会话
+func RevokeAdminSession(token string) error {
+	return database.DB.Where("token_hash = ?", HashAPIKey(token)).Delete(&model.AdminSession{}).Error
+}
+
+// RevokeSubjectSessions 撤销同一用户的全部会话 ("退出所有设备"), 返回撤销数量
+func RevokeSubjectSessions(source, subject string) (int64, error) {
+	result := database.DB.Where("source = ? AND subject = ?", source, subject).Delete(&model.AdminSession{})
+	return result.RowsAffected, result.Error
+}
+
+// RevokeLocalAdminSessions 撤销本地账号的全部会话 (改密码、禁用、删除时调用)
+func RevokeLocalAdminSessions(localAdminID uint) error {
+	return database.DB.Where("local_admin_id = ?", localAdminID).Delete(&model.AdminSession{}).Error
+}
+
+// localAdminSubject 本地账号在会话与管理员身份中的 ID
+func localAdminSubject(id uint) string {
+	return SessionSourceLocal + ":" + strconv.FormatUint(uint64(id), 10)
+}
+
+// LocalAdminID 从会话用户中取出本地账号 ID, 非本地会话返回 false
+func LocalAdminID(user *OAuthUserInfo) (uint, bool) {
+	if user == nil || user.Source != SessionSourceLocal {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(strings.TrimPrefix(user.ID, SessionSourceLocal+":"), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// truncateString 按字节截断字符串
+func truncateString(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,352 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"random-api-go/config"
+	"random-api-go/database"
+	"random-api-go/model"
+)
+
+// 管理员标识类型
+const (
+	AdminMatchID       = "id"
+	AdminMatchUsername = "username"
+	AdminMatchEmail    = "email"
+)
+
+// ErrAdminLockout 操作会让当前管理员失去访问权限或 admin 角色
+var ErrAdminLockout = errors.New("this change would remove your own admin access")
+
+// ErrAdminAllowlistEmpty 名单为空时拒绝 OAuth 登录
+var ErrAdminAllowlistEmpty = errors.New("admin allowlist is empty, OAuth admin login is disabled: set ADMIN_OWNER / ADMIN_ALLOWED_USERS or add users with a local admin account")
+
+// AdminIdentity 已通过 OAuth 验证的登录用户
+type AdminIdentity struct {
+	ID       string
+	Username string
+	Email    string
+	Local    bool // 本地管理员账号, 不受名单限制
+}
+
+// adminMatcher 解析后的名单项
+type adminMatcher struct {
+	kind  string
+	value string
+}
+
+// adminEntry 数据库名单项及其角色
+type adminEntry struct {
+	adminMatcher
+	access AdminAccess
+}
+
+// AdminUserService 管理后台允许名单
+// 名单来源: ADMIN_OWNER + ADMIN_ALLOWED_USERS 环境变量 + 数据库 admin_users 表, 三者合并
+// 全部为空时拒绝所有 OAuth 用户 (只能使用本地管理员账号), 启动时输出警告
+// 环境变量中的用户均为 admin 角色, 数据库名单项可指定角色与端点范围
+type AdminUserService struct {
+	mu    sync.RWMutex
+	owner *adminMatcher
+	env   []adminMatcher
+	db    []adminEntry
+}
+
+var (
+	adminUserService *AdminUserService
+	adminUserOnce    sync.Once
+)
+
+// GetAdminUserService 获取管理员名单服务实例
+func GetAdminUserService() *AdminUserService {
+	adminUserOnce.Do(func() {
+		adminUserService = &AdminUserService{}
+		adminUserService.loadEnv()
+		if err := adminUserService.Reload(); err != nil {
+			log.Printf("Failed to load admin users: %v", err)
+		}
+		if adminUserService.IsOpen() {
+			log.Printf("警告: 未配置 ADMIN_OWNER / ADMIN_ALLOWED_USERS 且管理员名单为空, OAuth 登录管理后台已禁用, 只能使用本地管理员账号")
+		}
+	})
+	return adminUserService
+}
+
+// parseAdminMatcher 解析名单项
+// 支持 id:123 / username:alice / email:a@example.com; 不带前缀时含 @ 视为邮箱, 纯数字视为 ID, 其余视为用户名
+func parseAdminMatcher(identifier string) (adminMatcher, error) {
+	identifier = strings.TrimSpace(identifier)
+	if identifier == "" {
+		return adminMatcher{}, fmt.Errorf("identifier is required")
+	}
+
+	kind, value := "", identifier
+	if k, v, ok := strings.Cut(identifier, ":"); ok {
+		switch strings.ToLower(k) {
+		case AdminMatchID, AdminMatchUsername, AdminMatchEmail:
+			kind, value = strings.ToLower(k), strings.TrimSpace(v)
+		}
+	}
+	if kind == "" {
+		switch {
+		case strings.Contains(value, "@"):
+			kind = AdminMatchEmail
+		case isDigits(value):
+			kind = AdminMatchID
+		default:
+			kind = AdminMatchUsername
+		}
+	}
+	if value == "" {
+		return adminMatcher{}, fmt.Errorf("identifier value is required")
+	}
+	if kind != AdminMatchID {
+		// 用户名与邮箱不区分大小写
+		value = strings.ToLower(value)
+	}
+	return adminMatcher{kind: kind, value: value}, nil
+}
+
+// isDigits 判断字符串是否全为数字
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// matches 判断登录用户是否命中名单项
+func (m adminMatcher) matches(user AdminIdentity) bool {
+	switch m.kind {
+	case AdminMatchID:
+		return user.ID != "" && user.ID == m.value
+	case AdminMatchUsername:
+		return user.Username != "" && strings.EqualFold(user.Username, m.value)
+	case AdminMatchEmail:
+		return user.Email != "" && strings.EqualFold(user.Email, m.value)
+	}
+	return false
+}
+
+// loadEnv 解析环境变量中的 owner 与允许名单
+func (s *AdminUserService) loadEnv() {
+	cfg := config.Get()
+	if cfg.Admin.Owner != "" {
+		if m, err := parseAdminMatcher(cfg.Admin.Owner); err != nil {
+			log.Printf("忽略无效的 ADMIN_OWNER: %v", err)
+		} else {
+			s.owner = &m
+		}
+	}
+	for _, item := range cfg.Admin.AllowedUsers {
+		m, err := parseAdminMatcher(item)
+		if err != nil {
+			log.Printf("忽略无效的 ADMIN_ALLOWED_USERS 项 %q: %v", item, err)
+			continue
+		}
+		s.env = append(s.env, m)
+	}
+}
+
+// Reload 从数据库重新加载管理员名单
+func (s *AdminUserService) Reload() error {
+	var users []model.AdminUser
+	if err := database.DB.Order("id ASC").Find(&users).Error; err != nil {
+		return err
+	}
+
+	entries := make([]adminEntry, 0, len(users))
+	for _, u := range users {
+		m, err := parseAdminMatcher(u.Identifier)
+		if err != nil {
+			log.Printf("忽略无效的管理员名单项 %d: %v", u.ID, err)
+			continue
+		}
+		entries = append(entries, adminEntry{adminMatcher: m, access: newAdminAccess(u.Role, u.EndpointIDs)})
+	}
+
+	s.mu.Lock()
+	s.db = entries
+	s.mu.Unlock()
+	return nil
+}
+
+// IsOpen 名单为空 (此时拒绝所有 OAuth 用户)
+func (s *AdminUserService) IsOpen() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.owner == nil && len(s.env) == 0 && len(s.db) == 0
+}
+
+// IsAllowed 判断登录用户是否在管理员名单中
+func (s *AdminUserService) IsAllowed(user AdminIdentity) bool {
+	return s.Access(user) != nil
+}
+
+// Access 获取登录用户的角色与端点范围, 不在名单中时返回 nil
+func (s *AdminUserService) Access(user AdminIdentity) *AdminAccess {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.accessWith(user, s.db)
+}
+
+// accessWith 用给定的数据库名单判断 (调用方持有读锁)
+// 命中多个名单项时取最高角色; 名单为空时不允许任何人
+func (s *AdminUserService) accessWith(user AdminIdentity, db []adminEntry) *AdminAccess {
+	if s.owner != nil && s.owner.matches(user) {
+		return &AdminAccess{Role: RoleAdmin}
+	}
+	for _, m := range s.env {
+		if m.matches(user) {
+			return &AdminAccess{Role: RoleAdmin}
+		}
+	}
+	var best *AdminAccess
+	for _, e := range db {
+		if e.matches(user) && (best == nil || roleLevels[e.access.Role] > roleLevels[best.Role]) {
+			access := e.access
+			best = &access
+		}
+	}
+	return best
+}
+
+// EnvIdentifiers 返回环境变量配置的 owner 与允许名单 (规范形式, 只读)
+func (s *AdminUserService) EnvIdentifiers() (string, []string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	owner := ""
+	if s.owner != nil {
+		owner = s.owner.kind + ":" + s.owner.value
+	}
+	env := make([]string, 0, len(s.env))
+	for _, m := range s.env {
+		env = append(env, m.kind+":"+m.value)
+	}
+	return owner, env
+}
+
+// ListUsers 列出数据库中的管理员名单
+func (s *AdminUserService) ListUsers() ([]model.AdminUser, error) {
+	var users []model.AdminUser
+	if err := database.DB.Order("id ASC").Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to list admin users: %w", err)
+	}
+	return users, nil
+}
+
+// ValidateAdminUser 校验名单项并规范化为 kind:value 形式, 角色为空时默认 admin
+func ValidateAdminUser(user *model.AdminUser) error {
+	m, err := parseAdminMatcher(user.Identifier)
+	if err != nil {
+		return err
+	}
+	role, err := normalizeRole(user.Role)
+	if err != nil {
+		return err
+	}
+	endpointIDs, err := normalizeEndpointIDs(user.EndpointIDs)
+	if err != nil {
+		return err
+	}
+	user.Identifier = m.kind + ":" + m.value
+	user.Role = role
+	user.EndpointIDs = endpointIDs
+	user.Note = strings.TrimSpace(user.Note)
+	return nil
+}
+
+// CreateUser 添加管理员; 名单原本为空时, 新增项必须包含操作者本人, 否则操作者会被锁在外面
+func (s *AdminUserService) CreateUser(user *model.AdminUser, actor AdminIdentity) error {
+	if err := ValidateAdminUser(user); err != nil {
+		return err
+	}
+	m, _ := parseAdminMatcher(user.Identifier)
+	entry := adminEntry{adminMatcher: m, access: newAdminAccess(user.Role, user.EndpointIDs)}
+
+	if err := s.checkLockout(actor, func(db []adminEntry) []adminEntry {
+		return append(db, entry)
+	}); err != nil {
+		return err
+	}
+	if err := database.DB.Create(user).Error; err != nil {
+		return fmt.Errorf("failed to create admin user: %w", err)
+	}
+	return s.Reload()
+}
+
+// UpdateUser 更新管理员名单项
+func (s *AdminUserService) UpdateUser(user *model.AdminUser, actor AdminIdentity) error {
+	var existing model.AdminUser
+	if err := database.DB.First(&existing, user.ID).Error; err != nil {
+		return err
+	}
+	if err := ValidateAdminUser(user); err != nil {
+		return err
+	}
+	m, _ := parseAdminMatcher(user.Identifier)
+	entry := adminEntry{adminMatcher: m, access: newAdminAccess(user.Role, user.EndpointIDs)}
+
+	old, _ := parseAdminMatcher(existing.Identifier)
+	if err := s.checkLockout(actor, func(db []adminEntry) []adminEntry {
+		return replaceEntry(db, old, &entry)
+	}); err != nil {
+		return err
+	}
+	if err := database.DB.Save(user).Error; err != nil {
+		return fmt.Errorf("failed to update admin user: %w", err)
+	}
+	return s.Reload()
+}
+
+// DeleteUser 删除管理员名单项
+func (s *AdminUserService) DeleteUser(id uint, actor AdminIdentity) error {
+	var existing model.AdminUser
+	if err := database.DB.First(&existing, id).Error; err != nil {
+		return err
+	}
+	old, _ := parseAdminMatcher(existing.Identifier)
+	if err := s.checkLockout(actor, func(db []adminEntry) []adminEntry {
+		return replaceEntry(db, old, nil)
+	}); err != nil {
+		return err
+	}
+	if err := database.DB.Delete(&model.AdminUser{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete admin user: %w", err)
+	}
+	return s.Reload()
+}
+
+// checkLockout 按修改后的名单判断操作者是否仍是 admin (本地账号不受名单影响)
+func (s *AdminUserService) checkLockout(actor AdminIdentity, change func([]adminEntry) []adminEntry) error {
+	if actor.Local {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	next := change(append([]adminEntry(nil), s.db...))
+	if !s.accessWith(actor, next).HasRole(RoleAdmin) {
+		return ErrAdminLockout
+	}
+	return nil
+}
+
+// replaceEntry 替换 (或删除, replacement 为 nil) 名单中的第一个相同项
+func replaceEntry(list []adminEntry, old adminMatcher, replacement *adminEntry) []adminEntry {
+	for i, m := range list {
+		if m.adminMatcher == old {
+			if replacement != nil {
+				list[i] = *replacement
+				return list
+			}
+			return append(list[:i], list[i+1:]...)
+		}
+	}
+	return list
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -26,7 +27,7 @@ func NewAPIFetcher() *APIFetcher {
 }
 
 // FetchURLs 从API接口获取URL列表
-func (af *APIFetcher) FetchURLs(config *model.APIConfig) ([]string, error) {
+func (af *APIFetcher) FetchURLs(ctx context.Context, config *model.APIConfig) ([]string, error) {
 	var allURLs []string
 
 	// 对于GET/POST接口，我们预获取多次以获得不同的URL
@@ -40,8 +41,12 @@ func (af *APIFetcher) FetchURLs(config *model.APIConfig) ([]string, error) {
 	urlSet := make(map[string]bool) // 用于去重
 
 	for i := 0; i < maxFetches; i++ {
-		urls, err := af.fetchSingleRequest(config)
+		urls, err := af.fetchSingleRequest(ctx, config)
 		if err != nil {
+			if ctx.Err() != nil {
+				log.Printf("API预获取被取消: %v", ctx.Err())
+				return allURLs, ctx.Err()
+			}
 			log.Printf("第 %d 次请求失败: %v", i+1, err)
 			continue
 		}
@@ -69,7 +74,11 @@ func (af *APIFetcher) FetchURLs(config *model.APIConfig) ([]string, error) {
 
 		// 添加小延迟避免请求过快
 		if i < maxFetches-1 {
-			time.Sleep(50 * time.Millisecond)
+			select {
+			case <-time.After(50 * time.Millisecond):
+			case <-ctx.Done():
+				return allURLs, ctx.Err()
+			}
 		}
 
 		// 每50次请求输出一次进度
@@ -83,13 +92,13 @@ func (af *APIFetcher) FetchURLs(config *model.APIConfig) ([]string, error) {
 }
 
 // FetchSingleURL 实时获取单个URL (用于GET/POST实时请求)
-func (af *APIFetcher) FetchSingleURL(config *model.APIConfig) ([]string, error) {
+func (af *APIFetcher) FetchSingleURL(ctx context.Context, config *model.APIConfig) ([]string, error) {
 	log.Printf("实时请求 %s 接口: %s", config.Method, config.URL)
-	return af.fetchSingleRequest(config)
+	return af.fetchSingleRequest(ctx, config)
 }
 
 // fetchSingleRequest 执行单次API请求
-func (af *APIFetcher) fetchSingleRequest(config *model.APIConfig) ([]string, error) {
+func (af *APIFetcher) fetchSingleRequest(ctx context.Context, config *model.APIConfig) ([]string, error) {
 	var req *http.Request
 	var err error
 
@@ -98,12 +107,12 @@ func (af *APIFetcher) fetchSingleRequest(config *model.APIConfig) ([]string, err
 		if config.Body != "" {
 			body = strings.NewReader(config.Body)
 		}
-		req, err = http.NewRequest("POST", config.URL, body)
+		req, err = http.NewRequestWithContext(ctx, "POST", config.URL, body)
 		if config.Body != "" {
 			req.Header.Set("Content-Type", "application/json")
 		}
 	} else {
-		req, err = http.NewRequest("GET", config.URL, nil)
+		req, err = http.NewRequestWithContext(ctx, "GET", config.URL, nil)
 	}
 
 	if err != nil {
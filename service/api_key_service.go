@@ -0,0 +1,387 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"random-api-go/database"
+	"random-api-go/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// apiKeyPrefix 明文 API Key 的固定前缀, 便于识别与扫描泄漏
+const apiKeyPrefix = "rak_"
+
+// API Key 校验失败的原因
+var (
+	ErrAPIKeyInvalid        = errors.New("invalid API key")
+	ErrAPIKeyExpired        = errors.New("API key has expired")
+	ErrAPIKeyEndpointDenied = errors.New("API key is not allowed for this endpoint")
+	ErrAPIKeyQuotaExhausted = errors.New("API key daily quota exhausted")
+)
+
+// apiKeyEntry 内存中的 API Key 及其允许访问的端点路径
+type apiKeyEntry struct {
+	key          *model.APIKey
+	allowedPaths map[string]bool // 为 nil 表示全部端点
+}
+
+// apiKeyUsageKey 用量统计维度
+type apiKeyUsageKey struct {
+	KeyID uint
+	Path  string
+}
+
+// APIKeyService API Key 服务
+// Key 按哈希缓存在内存中, 用量先在内存累加, 每分钟写入 daily_api_key_usages
+type APIKeyService struct {
+	mu     sync.RWMutex
+	byHash map[string]*apiKeyEntry
+
+	usageMu     sync.Mutex
+	pending     map[apiKeyUsageKey]uint64 // 尚未写入数据库的用量
+	lastUsed    map[uint]time.Time
+	usageDay    time.Time
+	todayCounts map[uint]uint64 // 当日已用次数 (数据库 + 内存), 用于配额判断
+}
+
+var (
+	apiKeyService *APIKeyService
+	apiKeyOnce    sync.Once
+)
+
+// GetAPIKeyService 获取 API Key 服务实例
+func GetAPIKeyService() *APIKeyService {
+	apiKeyOnce.Do(func() {
+		apiKeyService = &APIKeyService{
+			byHash:      make(map[string]*apiKeyEntry),
+			pending:     make(map[apiKeyUsageKey]uint64),
+			lastUsed:    make(map[uint]time.Time),
+			todayCounts: make(map[uint]uint64),
+		}
+		if err := apiKeyService.Reload(); err != nil {
+			log.Printf("Failed to load API keys: %v", err)
+		}
+		go apiKeyService.startPeriodicFlush()
+	})
+	return apiKeyService
+}
+
+// HashAPIKey 计算 API Key 的哈希 (数据库只保存哈希)
+func HashAPIKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateAPIKey 生成新的明文 API Key
+func generateAPIKey() (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + hex.EncodeToString(buf), nil
+}
+
+// ValidateAPIKey 校验 API Key 的可编辑字段
+func ValidateAPIKey(key *model.APIKey) error {
+	key.Name = strings.TrimSpace(key.Name)
+	key.Owner = strings.TrimSpace(key.Owner)
+	if key.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if key.RateRequests < 0 || key.Burst < 0 || key.DailyQuota < 0 {
+		return fmt.Errorf("rate_requests, burst and daily_quota must not be negative")
+	}
+	if key.RateRequests > 0 && key.RateWindowSeconds <= 0 {
+		return fmt.Errorf("rate_window_seconds must be positive")
+	}
+	return nil
+}
+
+// Reload 从数据库重新加载全部 API Key, 并解析允许访问的端点路径
+func (s *APIKeyService) Reload() error {
+	var keys []model.APIKey
+	if err := database.DB.Find(&keys).Error; err != nil {
+		return err
+	}
+
+	var endpoints []model.APIEndpoint
+	if err := database.DB.Select("id", "url").Find(&endpoints).Error; err != nil {
+		return err
+	}
+	urlByID := make(map[uint]string, len(endpoints))
+	for _, e := range endpoints {
+		urlByID[e.ID] = e.URL
+	}
+
+	byHash := make(map[string]*apiKeyEntry, len(keys))
+	for i := range keys {
+		entry := &apiKeyEntry{key: &keys[i]}
+		if len(keys[i].AllowedEndpointIDs) > 0 {
+			entry.allowedPaths = make(map[string]bool, len(keys[i].AllowedEndpointIDs))
+			for _, id := range keys[i].AllowedEndpointIDs {
+				if url, ok := urlByID[id]; ok {
+					entry.allowedPaths[url] = true
+				}
+			}
+		}
+		byHash[keys[i].KeyHash] = entry
+	}
+
+	s.mu.Lock()
+	s.byHash = byHash
+	s.mu.Unlock()
+	return nil
+}
+
+// Authenticate 校验 API Key 能否访问该路径, 通过后计入当日用量
+func (s *APIKeyService) Authenticate(raw, path string) (*model.APIKey, error) {
+	s.mu.RLock()
+	entry := s.byHash[HashAPIKey(strings.TrimSpace(raw))]
+	s.mu.RUnlock()
+
+	if entry == nil || entry.key.Revoked {
+		return nil, ErrAPIKeyInvalid
+	}
+	key := entry.key
+	now := time.Now()
+	if key.ExpiresAt != nil && !key.ExpiresAt.After(now) {
+		return nil, ErrAPIKeyExpired
+	}
+
+	path = strings.TrimPrefix(path, "/")
+	if entry.allowedPaths != nil && !entry.allowedPaths[path] {
+		return nil, ErrAPIKeyEndpointDenied
+	}
+
+	if !s.consume(key, path, now) {
+		return nil, ErrAPIKeyQuotaExhausted
+	}
+	return key, nil
+}
+
+// consume 检查每日配额并记录一次用量
+func (s *APIKeyService) consume(key *model.APIKey, path string, now time.Time) bool {
+	today := now.Truncate(24 * time.Hour)
+
+	s.usageMu.Lock()
+	defer s.usageMu.Unlock()
+
+	if !s.usageDay.Equal(today) {
+		s.usageDay = today
+		s.todayCounts = make(map[uint]uint64)
+	}
+	used, ok := s.todayCounts[key.ID]
+	if !ok {
+		used = s.loadTodayCount(key.ID, today)
+	}
+	if key.DailyQuota > 0 && used >= uint64(key.DailyQuota) {
+		s.todayCounts[key.ID] = used
+		return false
+	}
+
+	s.todayCounts[key.ID] = used + 1
+	s.pending[apiKeyUsageKey{KeyID: key.ID, Path: path}]++
+	s.lastUsed[key.ID] = now
+	return true
+}
+
+// loadTodayCount 查询 Key 当日已写入数据库的用量 (每个 Key 每天只查一次)
+func (s *APIKeyService) loadTodayCount(keyID uint, today time.Time) uint64 {
+	var count uint64
+	if err := database.DB.Model(&model.DailyAPIKeyUsage{}).
+		Select("COALESCE(SUM(count), 0)").
+		Where("api_key_id = ? AND date = ?", keyID, today).
+		Scan(&count).Error; err != nil {
+		log.Printf("查询 API Key %d 当日用量失败: %v", keyID, err)
+	}
+	return count
+}
+
+// RateLimitRuleFor 返回 API Key 的专属限流参数, nil 表示该 Key 没有专属限流
+// 携带 Key 的请求使用该规则替代按 IP 的限流; 没有专属限流时调用方应沿用按 IP 的策略, 只有 Unlimited 的 Key 不限流
+func RateLimitRuleFor(key *model.APIKey) *RateLimitRule {
+	if key.Unlimited || key.RateRequests <= 0 || key.RateWindowSeconds <= 0 {
+		return nil
+	}
+	rule := &RateLimitRule{
+		Key:      fmt.Sprintf("k%d:%d", key.ID, key.UpdatedAt.UnixNano()),
+		Requests: key.RateRequests,
+		Window:   time.Duration(key.RateWindowSeconds) * time.Second,
+		Burst:    key.Burst,
+		Shared:   true,
+	}
+	if rule.Burst <= 0 {
+		rule.Burst = rule.Requests
+	}
+	return rule
+}
+
+// startPeriodicFlush 每分钟把内存用量写入数据库, 每天清理过期用量
+func (s *APIKeyService) startPeriodicFlush() {
+	flushTicker := time.NewTicker(1 * time.Minute)
+	defer flushTicker.Stop()
+	cleanupTicker := time.NewTicker(24 * time.Hour)
+	defer cleanupTicker.Stop()
+
+	for {
+		select {
+		case <-flushTicker.C:
+			if err := s.flushUsage(); err != nil {
+				log.Printf("Failed to flush API key usage to database: %v", err)
+			}
+		case <-cleanupTicker.C:
+			if err := s.CleanupOldUsage(); err != nil {
+				log.Printf("Failed to cleanup old API key usage: %v", err)
+			}
+		}
+	}
+}
+
+// flushUsage 将内存中的用量批量 upsert 到数据库, 并更新最后使用时间
+func (s *APIKeyService) flushUsage() error {
+	s.usageMu.Lock()
+	current := s.pending
+	lastUsed := s.lastUsed
+	s.pending = make(map[apiKeyUsageKey]uint64)
+	s.lastUsed = make(map[uint]time.Time)
+	s.usageMu.Unlock()
+
+	if len(current) == 0 {
+		return nil
+	}
+
+	today := time.Now().Truncate(24 * time.Hour)
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		for key, count := range current {
+			usage := model.DailyAPIKeyUsage{
+				APIKeyID: key.KeyID,
+				Path:     key.Path,
+				Date:     today,
+				Count:    count,
+			}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "date"}, {Name: "api_key_id"}, {Name: "path"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"count":      gorm.Expr("count + ?", count),
+					"updated_at": time.Now(),
+				}),
+			}).Create(&usage).Error; err != nil {
+				return err
+			}
+		}
+		// UpdateColumn 不更新 updated_at, 避免限流桶随之重建
+		for id, at := range lastUsed {
+			if err := tx.Model(&model.APIKey{}).Where("id = ?", id).UpdateColumn("last_used_at", at).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListKeys 列出 API Key
+func (s *APIKeyService) ListKeys() ([]model.APIKey, error) {
+	var keys []model.APIKey
+	if err := database.DB.Order("id ASC").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	return keys, nil
+}
+
+// CreateKey 创建 API Key, 返回明文 (只在此时可见)
+func (s *APIKeyService) CreateKey(key *model.APIKey) (string, error) {
+	if err := ValidateAPIKey(key); err != nil {
+		return "", err
+	}
+	raw, err := generateAPIKey()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	key.ID = 0
+	key.KeyHash = HashAPIKey(raw)
+	key.Prefix = raw[:len(apiKeyPrefix)+8]
+	key.LastUsedAt = nil
+	if err := database.DB.Create(key).Error; err != nil {
+		return "", fmt.Errorf("failed to create API key: %w", err)
+	}
+	return raw, s.Reload()
+}
+
+// UpdateKey 更新 API Key 的名称、权限与限额 (哈希与前缀保持不变)
+func (s *APIKeyService) UpdateKey(key *model.APIKey) error {
+	if err := ValidateAPIKey(key); err != nil {
+		return err
+	}
+	var existing model.APIKey
+	if err := database.DB.First(&existing, key.ID).Error; err != nil {
+		return err
+	}
+	key.KeyHash = existing.KeyHash
+	key.Prefix = existing.Prefix
+	key.LastUsedAt = existing.LastUsedAt
+	key.CreatedAt = existing.CreatedAt
+	if err := database.DB.Save(key).Error; err != nil {
+		return fmt.Errorf("failed to update API key: %w", err)
+	}
+	return s.Reload()
+}
+
+// DeleteKey 删除 API Key (用量记录保留)
+func (s *APIKeyService) DeleteKey(id uint) error {
+	if err := database.DB.Delete(&model.APIKey{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete API key: %w", err)
+	}
+	return s.Reload()
+}
+
+// GetUsage 查询 API Key 最近 N 天的每日用量 (按日期、路径排序, 含尚未写入数据库的部分)
+func (s *APIKeyService) GetUsage(keyID uint, days int) ([]model.DailyAPIKeyUsage, error) {
+	if days <= 0 || days > 90 {
+		days = 30
+	}
+	since := time.Now().AddDate(0, 0, -(days - 1)).Truncate(24 * time.Hour)
+
+	var rows []model.DailyAPIKeyUsage
+	if err := database.DB.Where("api_key_id = ? AND date >= ?", keyID, since).
+		Order("date ASC, path ASC").
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	today := time.Now().Truncate(24 * time.Hour)
+	s.usageMu.Lock()
+	for key, count := range s.pending {
+		if key.KeyID != keyID {
+			continue
+		}
+		merged := false
+		for i := range rows {
+			if rows[i].Date.Equal(today) && rows[i].Path == key.Path {
+				rows[i].Count += count
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			rows = append(rows, model.DailyAPIKeyUsage{APIKeyID: keyID, Path: key.Path, Date: today, Count: count})
+		}
+	}
+	s.usageMu.Unlock()
+	return rows, nil
+}
+
+// CleanupOldUsage 清理 90 天前的用量记录
+func (s *APIKeyService) CleanupOldUsage() error {
+	cutoff := time.Now().AddDate(0, 0, -90).Truncate(24 * time.Hour)
+	return database.DB.Where("date < ?", cutoff).Delete(&model.DailyAPIKeyUsage{}).Error
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"time"
+
+	"random-api-go/database"
+	"random-api-go/model"
+)
+
+// 审计操作类型 (其他操作直接使用描述性的动词, 如 sync / reorder / reset_password)
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+)
+
+// auditMask 敏感字段脱敏后的占位值
+const auditMask = "******"
+
+// auditSensitiveKeys 字段名包含这些片段时视为敏感字段
+var auditSensitiveKeys = []string{"secret", "password", "token", "authorization", "cookie", "api_key", "apikey", "private", "credential"}
+
+// auditIgnoredKeys 不参与比较的字段 (主键、自动维护的时间戳与计数)
+var auditIgnoredKeys = map[string]bool{
+	"id":            true,
+	"created_at":    true,
+	"updated_at":    true,
+	"last_sync":     true,
+	"last_used_at":  true,
+	"last_login_at": true,
+	"last_seen_at":  true,
+	"last_hit_at":   true,
+	"hit_count":     true,
+}
+
+// AuditActor 审计记录中的操作者
+type AuditActor struct {
+	Name string // 用户名
+	ID   string // OAuth 用户 ID 或 local:{id}
+	IP   string
+}
+
+type auditActorKey struct{}
+
+// SystemAuditActor 非管理后台发起的变更 (启动任务、命令行等) 使用的操作者
+var SystemAuditActor = AuditActor{Name: "system"}
+
+// WithAuditActor 在 context 中记录操作者, 之后通过该 context 发起的变更都会记在此人名下
+func WithAuditActor(ctx context.Context, actor AuditActor) context.Context {
+	return context.WithValue(ctx, auditActorKey{}, actor)
+}
+
+// AuditActorFromContext 获取 context 中的操作者, 没有时为 system
+func AuditActorFromContext(ctx context.Context) AuditActor {
+	if ctx != nil {
+		if actor, ok := ctx.Value(auditActorKey{}).(AuditActor); ok {
+			return actor
+		}
+	}
+	return SystemAuditActor
+}
+
+// RecordAudit 记录一次管理操作; before/after 为变更前后的对象 (新建时 before 为 nil, 删除时 after 为 nil)
+// 只保存变化的字段, 敏感字段脱敏; 写入失败只记录日志, 不影响操作本身
+func RecordAudit(ctx context.Context, action, targetType string, targetID interface{}, before, after interface{}) {
+	changes := auditDiff(auditSnapshot(before), auditSnapshot(after))
+	if action == AuditActionUpdate && before != nil && after != nil && len(changes) == 0 {
+		return
+	}
+
+	actor := AuditActorFromContext(ctx)
+	event := model.AuditEvent{
+		Actor:      actor.Name,
+		ActorID:    actor.ID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   fmt.Sprint(targetID),
+		Changes:    changes,
+		IP:         actor.IP,
+	}
+	if err := database.Conn(ctx).Create(&event).Error; err != nil {
+		log.Printf("写入审计记录失败 (%s %s %s): %v", action, targetType, event.TargetID, err)
+	}
+}
+
+// AuditFilter 审计记录查询条件, 为空的条件不参与过滤
+type AuditFilter struct {
+	Actor      string
+	Action     string
+	TargetType string
+	TargetID   string
+	Since      time.Time
+	Until      time.Time
+	Page       int
+	PageSize   int
+}
+
+// ListAuditEvents 按条件分页查询审计记录 (新的在前), 返回当前页与总数
+func ListAuditEvents(filter AuditFilter) ([]model.AuditEvent, int64, error) {
+	query := database.DB.Model(&model.AuditEvent{})
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("created_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("created_at < ?", filter.Until)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count audit events: %w", err)
+	}
+
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.PageSize <= 0 {
+		filter.PageSize = 50
+	} else if filter.PageSize > 200 {
+		filter.PageSize = 200
+	}
+
+	var events []model.AuditEvent
+	if err := query.Order("id DESC").Offset((filter.Page - 1) * filter.PageSize).Limit(filter.PageSize).Find(&events).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list audit events: %w", err)
+	}
+	return events, total, nil
+}
+
+// auditSnapshot 将对象转换为字段表 (按 JSON 字段名), 内容为 JSON 的字符串字段 (如数据源配置) 展开后比较
+func auditSnapshot(v interface{}) map[string]interface{} {
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil()) {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil
+	}
+	for key, value := range fields {
+		if auditIgnoredKeys[key] {
+			delete(fields, key)
+			continue
+		}
+		if s, ok := value.(string); ok {
+			if trimmed := strings.TrimSpace(s); strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+				var nested interface{}
+				if json.Unmarshal([]byte(trimmed), &nested) == nil {
+					fields[key] = nested
+				}
+			}
+		}
+	}
+	return fields
+}
+
+// auditDiff 比较前后字段, 返回变化部分 (脱敏后)
+func auditDiff(before, after map[string]interface{}) map[string]model.AuditChange {
+	changes := make(map[string]model.AuditChange)
+	secretValue := isAuditSensitive(fmt.Sprint(after["key"])) || isAuditSensitive(fmt.Sprint(before["key"]))
+	for _, fields := range []map[string]interface{}{before, after} {
+		for key := range fields {
+			if _, done := changes[key]; done {
+				continue
+			}
+			oldValue, newValue := before[key], after[key]
+			if reflect.DeepEqual(oldValue, newValue) {
+				continue
+			}
+			// 配置表的值按配置键判断是否敏感
+			sensitive := secretValue && key == "value"
+			changes[key] = model.AuditChange{
+				Before: maskAuditValue(key, oldValue, sensitive),
+				After:  maskAuditValue(key, newValue, sensitive),
+			}
+		}
+	}
+	return changes
+}
+
+// maskAuditValue 递归脱敏, 字段名敏感时替换非空字符串值
+func maskAuditValue(key string, value interface{}, sensitive bool) interface{} {
+	sensitive = sensitive || isAuditSensitive(key)
+	switch v := value.(type) {
+	case map[string]interface{}:
+		masked := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			masked[k] = maskAuditValue(k, item, sensitive)
+		}
+		return masked
+	case []interface{}:
+		masked := make([]interface{}, len(v))
+		for i, item := range v {
+			masked[i] = maskAuditValue(key, item, sensitive)
+		}
+		return masked
+	case string:
+		if sensitive && v != "" {
+			return auditMask
+		}
+	}
+	return value
+}
+
+// isAuditSensitive 字段名是否敏感 (不区分大小写, - 视为 _)
+func isAuditSensitive(key string) bool {
+	key = strings.ReplaceAll(strings.ToLower(key), "-", "_")
+	for _, part := range auditSensitiveKeys {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,261 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"random-api-go/database"
+	"random-api-go/model"
+
+	"github.com/woodchen-ink/go-web-utils/uautil"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 机器人策略模式
+const (
+	BotModeOff         = "off"          // 不限制 (仍执行 UA 拦截列表)
+	BotModeBrowserOnly = "browser_only" // 仅允许浏览器
+	BotModeBlockBots   = "block_bots"   // 只拦截可识别的机器人与脚本, 未知 UA 放行
+)
+
+// 机器人拦截原因
+const (
+	BotBlockDenyUA     = "deny_ua"     // 命中端点 UA 拦截列表
+	BotBlockNotBrowser = "not_browser" // browser_only 模式下不是浏览器
+	BotBlockBot        = "bot"         // block_bots 模式下识别为机器人
+	BotBlockPreviewBot = "preview_bot" // 端点拦截了链接预览爬虫
+)
+
+// previewBotPatterns 常见链接预览爬虫 (聊天软件、社交平台、邮件图片代理)
+var previewBotPatterns = []string{
+	"discordbot",
+	"telegrambot",
+	"slackbot",
+	"slack-imgproxy",
+	"twitterbot",
+	"facebookexternalhit",
+	"facebot",
+	"linkedinbot",
+	"whatsapp",
+	"skypeuripreview",
+	"googleimageproxy",
+	"redditbot",
+	"embedly",
+	"mastodon",
+}
+
+// botBlockUnknownPath 不对应已配置端点的路径在拦截统计中使用的路径
+const botBlockUnknownPath = "(unknown)"
+
+// botBlockKey 拦截统计维度
+type botBlockKey struct {
+	Path   string
+	Reason string
+}
+
+// BotBlockStatsResult 拦截统计汇总
+type BotBlockStatsResult struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+	Count  uint64 `json:"count"`
+}
+
+var (
+	botBlockMu      sync.Mutex
+	botBlockPending = make(map[botBlockKey]uint64)
+	botBlockOnce    sync.Once
+)
+
+// normalizeBotPolicy 校验并规范化端点的机器人策略
+func normalizeBotPolicy(policy *model.EndpointPolicy) error {
+	policy.BotMode = strings.ToLower(strings.TrimSpace(policy.BotMode))
+	switch policy.BotMode {
+	case "", BotModeOff, BotModeBrowserOnly, BotModeBlockBots:
+	default:
+		return fmt.Errorf("unsupported bot mode: %s, supported modes: off, browser_only, block_bots", policy.BotMode)
+	}
+	policy.BotAllowUA = normalizePatterns(policy.BotAllowUA)
+	policy.BotDenyUA = normalizePatterns(policy.BotDenyUA)
+	return nil
+}
+
+// defaultBotMode 端点未单独配置时的机器人策略模式
+func defaultBotMode() string {
+	mode := database.GetConfig("bot_policy_default_mode", BotModeBrowserOnly)
+	switch mode {
+	case BotModeOff, BotModeBrowserOnly, BotModeBlockBots:
+		return mode
+	}
+	return BotModeBrowserOnly
+}
+
+// CheckBot 按端点机器人策略判断是否放行, 拒绝时返回原因
+// 顺序: UA 拦截列表 > UA 放行列表 > 链接预览爬虫 > 模式判定
+func (s *EndpointPolicyService) CheckBot(path, userAgent string) (bool, string) {
+	policy := s.lookup(path)
+	if policy == nil {
+		fallback := DefaultEndpointPolicy(0)
+		policy = &fallback
+	}
+
+	ua := strings.ToLower(userAgent)
+	if ua != "" {
+		if containsAny(ua, policy.BotDenyUA) {
+			return false, BotBlockDenyUA
+		}
+		if containsAny(ua, policy.BotAllowUA) {
+			return true, ""
+		}
+		if containsAny(ua, previewBotPatterns) {
+			if policy.BlockPreviewBots {
+				return false, BotBlockPreviewBot
+			}
+			return true, ""
+		}
+	}
+
+	mode := policy.BotMode
+	if mode == "" {
+		mode = defaultBotMode()
+	}
+	switch mode {
+	case BotModeOff:
+		return true, ""
+	case BotModeBlockBots:
+		if uautil.IsBotUserAgent(userAgent, false) {
+			return false, BotBlockBot
+		}
+		return true, ""
+	default:
+		if !uautil.IsBrowserUserAgent(userAgent) {
+			return false, BotBlockNotBrowser
+		}
+		return true, ""
+	}
+}
+
+// containsAny 判断 UA (已转小写) 是否包含任一关键字
+func containsAny(ua string, patterns []string) bool {
+	for _, p := range patterns {
+		if p != "" && strings.Contains(ua, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// RecordBotBlock 记录一次机器人拦截, 先在内存累加, 每分钟写入数据库
+// 不对应已配置端点的路径 (扫描器探测的随机地址) 统一计入 botBlockUnknownPath, 避免统计无限增长
+func RecordBotBlock(path, reason string) {
+	botBlockOnce.Do(func() {
+		go startBotBlockFlush()
+	})
+
+	if !GetEndpointPolicyService().IsEndpoint(path) {
+		path = botBlockUnknownPath
+	}
+
+	botBlockMu.Lock()
+	botBlockPending[botBlockKey{Path: path, Reason: reason}]++
+	botBlockMu.Unlock()
+}
+
+// startBotBlockFlush 定期写入拦截统计, 每天清理过期数据
+func startBotBlockFlush() {
+	flushTicker := time.NewTicker(1 * time.Minute)
+	defer flushTicker.Stop()
+	cleanupTicker := time.NewTicker(24 * time.Hour)
+	defer cleanupTicker.Stop()
+
+	for {
+		select {
+		case <-flushTicker.C:
+			if err := flushBotBlocks(); err != nil {
+				log.Printf("Failed to flush bot block stats to database: %v", err)
+			}
+		case <-cleanupTicker.C:
+			cutoff := time.Now().AddDate(0, 0, -90).Truncate(24 * time.Hour)
+			if err := database.DB.Where("date < ?", cutoff).Delete(&model.DailyBotBlockStats{}).Error; err != nil {
+				log.Printf("Failed to cleanup old bot block stats: %v", err)
+			}
+		}
+	}
+}
+
+// flushBotBlocks 将内存中的拦截计数批量 upsert 到数据库
+func flushBotBlocks() error {
+	botBlockMu.Lock()
+	current := botBlockPending
+	botBlockPending = make(map[botBlockKey]uint64)
+	botBlockMu.Unlock()
+
+	if len(current) == 0 {
+		return nil
+	}
+
+	today := time.Now().Truncate(24 * time.Hour)
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		for key, count := range current {
+			row := model.DailyBotBlockStats{
+				Date:   today,
+				Path:   key.Path,
+				Reason: key.Reason,
+				Count:  count,
+			}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "date"}, {Name: "path"}, {Name: "reason"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"count":      gorm.Expr("count + ?", count),
+					"updated_at": time.Now(),
+				}),
+			}).Create(&row).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetBotBlockStats 汇总最近 N 天的机器人拦截次数 (含尚未写入数据库的部分), 按次数倒序
+func GetBotBlockStats(days int) ([]BotBlockStatsResult, error) {
+	if days <= 0 || days > 90 {
+		days = 7
+	}
+	since := time.Now().AddDate(0, 0, -(days - 1)).Truncate(24 * time.Hour)
+
+	var rows []BotBlockStatsResult
+	if err := database.DB.Model(&model.DailyBotBlockStats{}).
+		Select("path, reason, SUM(count) as count").
+		Where("date >= ?", since).
+		Group("path, reason").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	index := make(map[botBlockKey]int, len(rows))
+	for i, r := range rows {
+		index[botBlockKey{Path: r.Path, Reason: r.Reason}] = i
+	}
+	botBlockMu.Lock()
+	for key, count := range botBlockPending {
+		if i, ok := index[key]; ok {
+			rows[i].Count += count
+		} else {
+			rows = append(rows, BotBlockStatsResult{Path: key.Path, Reason: key.Reason, Count: count})
+		}
+	}
+	botBlockMu.Unlock()
+
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Count != rows[j].Count {
+			return rows[i].Count > rows[j].Count
+		}
+		return rows[i].Path < rows[j].Path
+	})
+	return rows, nil
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"random-api-go/config"
+)
+
+// 管理后台修改受管对象 (由声明式配置文件创建或更新的对象) 时的处理方式
+const (
+	DriftModeReject = "reject" // 拒绝修改
+	DriftModeFlag   = "flag"   // 允许修改, 在配置文件状态中显示为漂移, 下次同步时恢复为文件内容
+)
+
+// ErrManagedObject 对象由声明式配置文件管理, 不能在管理后台修改
+var ErrManagedObject = errors.New("managed by the config file")
+
+// configFileActor 同步配置文件产生的变更在审计记录中的操作者
+var configFileActor = AuditActor{Name: "config-file"}
+
+// configFileEnvPattern 配置文件中的 ${NAME} 引用, 用于从环境变量读取密钥 (未设置的变量保持原样)
+var configFileEnvPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// ConfigFileStatus 声明式配置文件的同步状态
+type ConfigFileStatus struct {
+	Enabled          bool           `json:"enabled"`
+	Path             string         `json:"path"`
+	DriftMode        string         `json:"drift_mode"`
+	LastReconciledAt *time.Time     `json:"last_reconciled_at"`
+	LastError        string         `json:"last_error"`
+	LastSummary      *ImportSummary `json:"last_summary"`
+	Drift            *ImportPlan    `json:"drift"` // 数据库与文件的差异, 即下次同步将要执行的变更
+}
+
+var (
+	configFileMu     sync.Mutex
+	configFileResult ConfigFileStatus
+)
+
+// ConfigFileEnabled 是否配置了声明式配置文件
+func ConfigFileEnabled() bool {
+	return config.Get().Declarative.ConfigFile != ""
+}
+
+// configDriftMode 返回漂移处理方式, 未知取值按 reject 处理
+func configDriftMode() string {
+	if config.Get().Declarative.DriftMode == DriftModeFlag {
+		return DriftModeFlag
+	}
+	return DriftModeReject
+}
+
+// CheckManagedEdit 管理后台修改对象前调用; 受管对象在 reject 模式下返回 ErrManagedObject, flag 模式下只记录漂移日志
+// 未配置声明式配置文件时受管标记不起作用
+func CheckManagedEdit(kind, key string, managed bool) error {
+	if !managed || !ConfigFileEnabled() {
+		return nil
+	}
+	if configDriftMode() == DriftModeReject {
+		return fmt.Errorf("%s %s is %w %s, edit the file and reload instead", kind, key, ErrManagedObject, config.Get().Declarative.ConfigFile)
+	}
+	log.Printf("受管对象 %s %s 在管理后台被修改, 与配置文件产生漂移, 下次同步时恢复", kind, key)
+	return nil
+}
+
+// loadConfigFile 读取并解析声明式配置文件
+func loadConfigFile() (*ConfigDocument, error) {
+	data, err := os.ReadFile(config.Get().Declarative.ConfigFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	doc, err := ParseConfigDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	// 解析后再替换, 环境变量的值只会成为字符串值, 不会改变文档结构
+	expandConfigFileEnv(reflect.ValueOf(doc).Elem())
+	return doc, nil
+}
+
+// expandConfigFileEnv 递归替换文档中字符串值里的 ${NAME} (包括数据源 config 中的字符串)
+func expandConfigFileEnv(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(expandConfigFileEnvString(v.String()))
+		}
+	case reflect.Ptr:
+		if !v.IsNil() {
+			expandConfigFileEnv(v.Elem())
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if field := v.Field(i); field.CanSet() {
+				expandConfigFileEnv(field)
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			expandConfigFileEnv(v.Index(i))
+		}
+	case reflect.Map:
+		// 只有数据源 config 这类 map[string]interface{}; map 中的值不可寻址, 替换后整体写回
+		if v.Type().Elem().Kind() != reflect.Interface {
+			return
+		}
+		for _, key := range v.MapKeys() {
+			if value := v.MapIndex(key); !value.IsNil() {
+				v.SetMapIndex(key, reflect.ValueOf(expandConfigFileEnvValue(value.Interface())))
+			}
+		}
+	}
+}
+
+// expandConfigFileEnvValue 替换 JSON / YAML 解码出的任意值中的字符串
+func expandConfigFileEnvValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return expandConfigFileEnvString(v)
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = expandConfigFileEnvValue(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = expandConfigFileEnvValue(item)
+		}
+	}
+	return value
+}
+
+// expandConfigFileEnvString 将 ${NAME} 替换为同名环境变量, 未设置的变量保持原样
+func expandConfigFileEnvString(s string) string {
+	return configFileEnvPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		name := strings.TrimSuffix(strings.TrimPrefix(ref, "${"), "}")
+		if value, ok := os.LookupEnv(name); ok {
+			return value
+		}
+		return ref
+	})
+}
+
+// ReconcileConfigFile 将声明式配置文件同步到数据库: 文件中的对象新建或更新并标记为受管,
+// 文件中已没有的受管对象被删除, 未受管的对象不受影响; 文件有错误时不做任何修改
+func (s *EndpointService) ReconcileConfigFile() (*ImportPlan, error) {
+	configFileMu.Lock()
+	defer configFileMu.Unlock()
+
+	plan, err := s.reconcileConfigFile()
+	now := time.Now()
+	configFileResult.LastReconciledAt = &now
+	configFileResult.LastError = ""
+	configFileResult.LastSummary = nil
+	if err != nil {
+		configFileResult.LastError = err.Error()
+		return plan, err
+	}
+	configFileResult.LastSummary = &plan.Summary
+	return plan, nil
+}
+
+func (s *EndpointService) reconcileConfigFile() (*ImportPlan, error) {
+	doc, err := loadConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	ctx := WithAuditActor(context.Background(), configFileActor)
+	plan, err := s.importDocument(ctx, doc, importModeManaged, false)
+	if err != nil {
+		return plan, err
+	}
+	if len(plan.Errors) > 0 {
+		return plan, fmt.Errorf("config file is invalid: %s", strings.Join(plan.Errors, "; "))
+	}
+	for _, warning := range plan.Warnings {
+		log.Printf("配置文件: %s", warning)
+	}
+	return plan, nil
+}
+
+// GetConfigFileStatus 返回配置文件同步状态, 并计算当前数据库与文件的差异
+func (s *EndpointService) GetConfigFileStatus() ConfigFileStatus {
+	configFileMu.Lock()
+	status := configFileResult
+	configFileMu.Unlock()
+
+	status.Enabled = ConfigFileEnabled()
+	status.Path = config.Get().Declarative.ConfigFile
+	status.DriftMode = configDriftMode()
+	if !status.Enabled {
+		return status
+	}
+
+	doc, err := loadConfigFile()
+	if err != nil {
+		status.LastError = err.Error()
+		return status
+	}
+	plan, err := s.planImport(doc, importModeManaged)
+	if err != nil {
+		status.LastError = err.Error()
+		return status
+	}
+	plan.DryRun = true
+	status.Drift = plan
+	return status
+}
@@ -0,0 +1,1101 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"random-api-go/database"
+	"random-api-go/model"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigDocumentVersion 当前导出文档的格式版本
+const ConfigDocumentVersion = 1
+
+// 导入模式
+const (
+	ImportModeMerge   = "merge"   // 只新增与更新, 不删除文档中没有的配置
+	ImportModeReplace = "replace" // 以文档为准, 删除文档中没有的配置
+)
+
+// importModeManaged 同步声明式配置文件: 文档中的对象标记为受管, 只删除文档中没有的受管对象
+const importModeManaged = "managed"
+
+// importMu 同一时间只执行一次导入
+var importMu sync.Mutex
+
+// ConfigDocument 完整配置文档 (端点、数据源、替换规则、手动黑名单与系统配置)
+type ConfigDocument struct {
+	Version               int                    `json:"version" yaml:"version"`
+	ExportedAt            time.Time              `json:"exported_at" yaml:"exported_at"`
+	Secrets               bool                   `json:"secrets" yaml:"secrets"` // 是否包含敏感值, 为 false 时敏感值以 ****** 代替
+	Endpoints             []ExportEndpoint       `json:"endpoints" yaml:"endpoints"`
+	GlobalURLReplaceRules []ExportURLReplaceRule `json:"global_url_replace_rules" yaml:"global_url_replace_rules"`
+	BlockedDomains        []ExportBlockedDomain  `json:"blocked_domains" yaml:"blocked_domains"`
+	Configs               []ExportConfigEntry    `json:"configs" yaml:"configs"`
+}
+
+// ExportEndpoint 导出的端点, 按 URL 与目标环境中的端点对应
+type ExportEndpoint struct {
+	ID              uint                   `json:"id" yaml:"id"` // 导出环境中的 ID, 仅用于重映射 endpoint 类型数据源的 endpoint_ids
+	Name            string                 `json:"name" yaml:"name"`
+	URL             string                 `json:"url" yaml:"url"`
+	Description     string                 `json:"description" yaml:"description"`
+	IsActive        *bool                  `json:"is_active,omitempty" yaml:"is_active,omitempty"` // 省略时为 true
+	ShowOnHomepage  *bool                  `json:"show_on_homepage,omitempty" yaml:"show_on_homepage,omitempty"`
+	SortOrder       int                    `json:"sort_order" yaml:"sort_order"`
+	RequestTimeout  int                    `json:"request_timeout" yaml:"request_timeout"`
+	DataSources     []ExportDataSource     `json:"data_sources" yaml:"data_sources"`
+	URLReplaceRules []ExportURLReplaceRule `json:"url_replace_rules" yaml:"url_replace_rules"`
+}
+
+// ExportDataSource 导出的数据源, 在端点内按名称对应
+type ExportDataSource struct {
+	Name     string                 `json:"name" yaml:"name"`
+	Type     string                 `json:"type" yaml:"type"`
+	Config   map[string]interface{} `json:"config" yaml:"config"`
+	IsActive *bool                  `json:"is_active,omitempty" yaml:"is_active,omitempty"`
+}
+
+// ExportURLReplaceRule 导出的 URL 替换规则, 在所属端点 (或全局) 内按名称对应
+type ExportURLReplaceRule struct {
+	Name        string `json:"name" yaml:"name"`
+	Mode        string `json:"mode" yaml:"mode"`
+	FromURL     string `json:"from_url" yaml:"from_url"`
+	ToURL       string `json:"to_url" yaml:"to_url"`
+	Priority    int    `json:"priority" yaml:"priority"`
+	StopOnMatch bool   `json:"stop_on_match" yaml:"stop_on_match"`
+	IsActive    *bool  `json:"is_active,omitempty" yaml:"is_active,omitempty"`
+}
+
+// ExportBlockedDomain 导出的手动黑名单规则, 按 (domain, match_type, path) 对应
+type ExportBlockedDomain struct {
+	Domain    string     `json:"domain" yaml:"domain"`
+	MatchType string     `json:"match_type" yaml:"match_type"`
+	Path      string     `json:"path" yaml:"path"`
+	Reason    string     `json:"reason" yaml:"reason"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" yaml:"expires_at,omitempty"`
+}
+
+// ExportConfigEntry 导出的系统配置项, 按键对应
+type ExportConfigEntry struct {
+	Key   string `json:"key" yaml:"key"`
+	Value string `json:"value" yaml:"value"`
+	Type  string `json:"type" yaml:"type"`
+}
+
+// ImportChange 导入计划中的一项变更 (差异按审计记录的方式脱敏)
+type ImportChange struct {
+	Kind    string                       `json:"kind"`   // endpoint / data_source / url_replace_rule / blocked_domain / config
+	Key     string                       `json:"key"`    // 可读标识, 如 /pics、/pics#本地图片
+	Action  string                       `json:"action"` // create / update / delete
+	Changes map[string]model.AuditChange `json:"changes,omitempty"`
+
+	apply func(ctx context.Context) error
+}
+
+// ImportSummary 导入计划统计
+type ImportSummary struct {
+	Create    int `json:"create"`
+	Update    int `json:"update"`
+	Delete    int `json:"delete"`
+	Unchanged int `json:"unchanged"`
+}
+
+// ImportPlan 导入计划; 存在 Errors 时不会执行
+type ImportPlan struct {
+	Mode     string         `json:"mode"`
+	DryRun   bool           `json:"dry_run"`
+	Applied  bool           `json:"applied"`
+	Changes  []ImportChange `json:"changes"`
+	Warnings []string       `json:"warnings"`
+	Errors   []string       `json:"errors"`
+	Summary  ImportSummary  `json:"summary"`
+}
+
+// importState 执行导入时的 ID 对应关系, 新建的端点在执行时才有 ID
+type importState struct {
+	sourceURLs  map[uint]string // 文档中的端点 ID -> URL
+	endpointIDs map[string]uint // URL -> 当前环境的端点 ID
+}
+
+// resolve 将文档中的端点 ID 转换为当前环境的端点 ID; 计划阶段尚未创建的端点返回 0
+func (st *importState) resolve(sourceID uint) (uint, error) {
+	url, ok := st.sourceURLs[sourceID]
+	if !ok {
+		return 0, fmt.Errorf("endpoint id %d is not defined in the document", sourceID)
+	}
+	return st.endpointIDs[url], nil
+}
+
+// ParseConfigDocument 解析配置文档, 以 { 开头按 JSON 解析, 否则按 YAML 解析; 不允许未知字段
+func ParseConfigDocument(data []byte) (*ConfigDocument, error) {
+	var doc ConfigDocument
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		decoder := json.NewDecoder(bytes.NewReader(trimmed))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("invalid JSON document: %w", err)
+		}
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(trimmed))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("invalid YAML document: %w", err)
+		}
+	}
+	if doc.Version > ConfigDocumentVersion {
+		return nil, fmt.Errorf("unsupported document version %d, latest supported version is %d", doc.Version, ConfigDocumentVersion)
+	}
+	return &doc, nil
+}
+
+// ExportConfig 导出完整配置; includeSecrets 为 false 时敏感值以 ****** 代替 (判断方式与审计脱敏相同)
+func (s *EndpointService) ExportConfig(includeSecrets bool) (*ConfigDocument, error) {
+	doc := &ConfigDocument{
+		Version:               ConfigDocumentVersion,
+		ExportedAt:            time.Now(),
+		Secrets:               includeSecrets,
+		Endpoints:             []ExportEndpoint{},
+		GlobalURLReplaceRules: []ExportURLReplaceRule{},
+		BlockedDomains:        []ExportBlockedDomain{},
+		Configs:               []ExportConfigEntry{},
+	}
+
+	endpoints, err := s.ListEndpoints()
+	if err != nil {
+		return nil, err
+	}
+	for _, endpoint := range endpoints {
+		item := ExportEndpoint{
+			ID:              endpoint.ID,
+			Name:            endpoint.Name,
+			URL:             endpoint.URL,
+			Description:     endpoint.Description,
+			IsActive:        boolPtr(endpoint.IsActive),
+			ShowOnHomepage:  boolPtr(endpoint.ShowOnHomepage),
+			SortOrder:       endpoint.SortOrder,
+			RequestTimeout:  endpoint.RequestTimeout,
+			DataSources:     []ExportDataSource{},
+			URLReplaceRules: exportURLReplaceRules(endpoint.URLReplaceRules),
+		}
+		for _, ds := range endpoint.DataSources {
+			var cfg map[string]interface{}
+			if err := json.Unmarshal([]byte(ds.Config), &cfg); err != nil {
+				return nil, fmt.Errorf("failed to parse config of data source %s#%s: %w", endpoint.URL, ds.Name, err)
+			}
+			if !includeSecrets {
+				cfg, _ = maskAuditValue("config", cfg, false).(map[string]interface{})
+			}
+			item.DataSources = append(item.DataSources, ExportDataSource{
+				Name:     ds.Name,
+				Type:     ds.Type,
+				Config:   cfg,
+				IsActive: boolPtr(ds.IsActive),
+			})
+		}
+		doc.Endpoints = append(doc.Endpoints, item)
+	}
+
+	var globalRules []model.URLReplaceRule
+	if err := database.DB.Where("endpoint_id IS NULL").Find(&globalRules).Error; err != nil {
+		return nil, fmt.Errorf("failed to list global URL replace rules: %w", err)
+	}
+	doc.GlobalURLReplaceRules = exportURLReplaceRules(globalRules)
+
+	// 自动禁用的规则会到期解除, 不属于需要迁移的配置
+	var blocked []model.BlockedDomain
+	if err := database.DB.Where("source = ?", BlockSourceManual).Order("domain, match_type, path").Find(&blocked).Error; err != nil {
+		return nil, fmt.Errorf("failed to list blocked domains: %w", err)
+	}
+	for _, rule := range blocked {
+		doc.BlockedDomains = append(doc.BlockedDomains, ExportBlockedDomain{
+			Domain:    rule.Domain,
+			MatchType: rule.MatchType,
+			Path:      rule.Path,
+			Reason:    rule.Reason,
+			ExpiresAt: rule.ExpiresAt,
+		})
+	}
+
+	configs, err := database.ListConfigs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list configs: %w", err)
+	}
+	for _, cfg := range configs {
+		value := cfg.Value
+		if !includeSecrets {
+			value = MaskConfigValue(cfg.Key, value)
+		}
+		doc.Configs = append(doc.Configs, ExportConfigEntry{Key: cfg.Key, Value: value, Type: cfg.Type})
+	}
+
+	return doc, nil
+}
+
+// MaskConfigValue 敏感配置 (键名含 secret、token 等, 与审计记录相同) 的非空值替换为 ******
+func MaskConfigValue(key, value string) string {
+	if value != "" && isAuditSensitive(key) {
+		return auditMask
+	}
+	return value
+}
+
+// IsMaskedConfigValue 判断提交的值是否为敏感配置的脱敏占位符 (保存时应沿用原值)
+func IsMaskedConfigValue(key, value string) bool {
+	return value == auditMask && isAuditSensitive(key)
+}
+
+// exportURLReplaceRules 按执行顺序导出替换规则
+func exportURLReplaceRules(rules []model.URLReplaceRule) []ExportURLReplaceRule {
+	sorted := append([]model.URLReplaceRule(nil), rules...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Priority != sorted[j].Priority {
+			return sorted[i].Priority < sorted[j].Priority
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+	items := make([]ExportURLReplaceRule, 0, len(sorted))
+	for _, rule := range sorted {
+		items = append(items, ExportURLReplaceRule{
+			Name:        rule.Name,
+			Mode:        rule.Mode,
+			FromURL:     rule.FromURL,
+			ToURL:       rule.ToURL,
+			Priority:    rule.Priority,
+			StopOnMatch: rule.StopOnMatch,
+			IsActive:    boolPtr(rule.IsActive),
+		})
+	}
+	return items
+}
+
+// ImportConfig 按文档生成导入计划, dryRun 为 false 且计划没有错误时执行
+// 全部变更在一个事务中执行, 任一变更出错时整体回滚; 内存缓存在提交后才刷新
+func (s *EndpointService) ImportConfig(ctx context.Context, doc *ConfigDocument, mode string, dryRun bool) (*ImportPlan, error) {
+	mode, err := validateImportMode(mode)
+	if err != nil {
+		return nil, err
+	}
+	return s.importDocument(ctx, doc, mode, dryRun)
+}
+
+// importDocument 生成并执行导入计划
+func (s *EndpointService) importDocument(ctx context.Context, doc *ConfigDocument, mode string, dryRun bool) (*ImportPlan, error) {
+	importMu.Lock()
+	defer importMu.Unlock()
+
+	plan, err := s.planImport(doc, mode)
+	if err != nil {
+		return nil, err
+	}
+	plan.DryRun = dryRun
+	if dryRun || len(plan.Errors) > 0 {
+		return plan, nil
+	}
+
+	// 首次创建时会读取数据库的服务先初始化, 事务期间只能通过 database.Conn(ctx) 访问数据库 (见 database.Transaction)
+	GetDomainStatsService()
+	GetEndpointPolicyService()
+	GetIPRuleService()
+	GetRateLimitService()
+	GetAPIKeyService()
+
+	err = database.Transaction(ctx, func(ctx context.Context) error {
+		for i, change := range plan.Changes {
+			if err := change.apply(ctx); err != nil {
+				return fmt.Errorf("import failed at %s %s %s (change %d of %d), no changes were applied: %w", change.Action, change.Kind, change.Key, i+1, len(plan.Changes), err)
+			}
+		}
+		if len(plan.Changes) > 0 {
+			RecordAudit(ctx, "import", "configuration", mode, nil, plan.Summary)
+		}
+		return nil
+	})
+	if err != nil {
+		return plan, err
+	}
+	plan.Applied = true
+	return plan, nil
+}
+
+// PlanImport 比较文档与当前配置, 生成变更列表 (不修改任何数据)
+func (s *EndpointService) PlanImport(doc *ConfigDocument, mode string) (*ImportPlan, error) {
+	mode, err := validateImportMode(mode)
+	if err != nil {
+		return nil, err
+	}
+	return s.planImport(doc, mode)
+}
+
+// validateImportMode 校验管理后台可用的导入模式, 为空时为 merge
+func validateImportMode(mode string) (string, error) {
+	if mode == "" {
+		return ImportModeMerge, nil
+	}
+	if mode != ImportModeMerge && mode != ImportModeReplace {
+		return "", fmt.Errorf("unsupported import mode: %s, supported modes: merge, replace", mode)
+	}
+	return mode, nil
+}
+
+func (s *EndpointService) planImport(doc *ConfigDocument, mode string) (*ImportPlan, error) {
+	plan := &ImportPlan{Mode: mode, Changes: []ImportChange{}, Warnings: []string{}, Errors: []string{}}
+	p := &importPlanner{
+		service: s,
+		doc:     doc,
+		plan:    plan,
+		replace: mode == ImportModeReplace,
+		managed: mode == importModeManaged,
+		state:   &importState{sourceURLs: make(map[uint]string), endpointIDs: make(map[string]uint)},
+	}
+	if err := p.planEndpoints(); err != nil {
+		return nil, err
+	}
+	if err := p.planGlobalRules(); err != nil {
+		return nil, err
+	}
+	if err := p.planBlockedDomains(); err != nil {
+		return nil, err
+	}
+	if err := p.planConfigs(); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// importPlanner 生成导入计划时的上下文
+type importPlanner struct {
+	service *EndpointService
+	doc     *ConfigDocument
+	plan    *ImportPlan
+	replace bool // 删除文档中没有的对象
+	managed bool // 同步配置文件: 对象标记为受管, 只删除受管对象
+	state   *importState
+}
+
+// removes 文档中没有的对象是否删除
+func (p *importPlanner) removes(managed bool) bool {
+	return p.replace || (p.managed && managed)
+}
+
+// guard 管理后台导入不能修改受配置文件管理的对象 (drift 模式为 reject 时)
+func (p *importPlanner) guard(kind, key string, managed bool) {
+	if !p.managed && managed && ConfigFileEnabled() && configDriftMode() == DriftModeReject {
+		p.errorf("%s %s is %v", kind, key, ErrManagedObject)
+	}
+}
+
+func (p *importPlanner) errorf(format string, args ...interface{}) {
+	p.plan.Errors = append(p.plan.Errors, fmt.Sprintf(format, args...))
+}
+
+func (p *importPlanner) warnf(format string, args ...interface{}) {
+	p.plan.Warnings = append(p.plan.Warnings, fmt.Sprintf(format, args...))
+}
+
+// add 记录一项变更; before/after 为空表示新建/删除
+func (p *importPlanner) add(kind, key, action string, before, after interface{}, apply func(ctx context.Context) error) {
+	change := ImportChange{Kind: kind, Key: key, Action: action, apply: apply}
+	if action != AuditActionDelete {
+		change.Changes = auditDiff(auditSnapshot(before), auditSnapshot(after))
+		// 所属端点在执行时才能确定
+		delete(change.Changes, "endpoint_id")
+	}
+	switch action {
+	case AuditActionCreate:
+		p.plan.Summary.Create++
+	case AuditActionUpdate:
+		p.plan.Summary.Update++
+	case AuditActionDelete:
+		p.plan.Summary.Delete++
+	}
+	p.plan.Changes = append(p.plan.Changes, change)
+}
+
+// planEndpoints 端点按 URL 对应 (URL 变化时按名称对应); 先删除、再新建或更新端点, 最后处理各端点的数据源与规则
+func (p *importPlanner) planEndpoints() error {
+	existing, err := p.service.ListEndpoints()
+	if err != nil {
+		return err
+	}
+	byURL := make(map[string]*model.APIEndpoint, len(existing))
+	byName := make(map[string]*model.APIEndpoint, len(existing))
+	for _, endpoint := range existing {
+		byURL[endpoint.URL] = endpoint
+		byName[endpoint.Name] = endpoint
+		p.state.endpointIDs[endpoint.URL] = endpoint.ID
+	}
+
+	// 校验并确定每个文档端点对应的现有端点
+	valid := make([]bool, len(p.doc.Endpoints))
+	matches := make([]*model.APIEndpoint, len(p.doc.Endpoints))
+	matched := make(map[uint]string)
+	seenURLs := make(map[string]bool)
+	seenNames := make(map[string]bool)
+	for i := range p.doc.Endpoints {
+		item := &p.doc.Endpoints[i]
+		item.URL = strings.TrimSpace(item.URL)
+		item.Name = strings.TrimSpace(item.Name)
+		if item.Name == "" || item.URL == "" {
+			p.errorf("endpoints[%d]: name and url are required", i)
+			continue
+		}
+		if seenURLs[item.URL] || seenNames[item.Name] {
+			p.errorf("endpoints[%d]: duplicate endpoint %s (%s)", i, item.URL, item.Name)
+			continue
+		}
+		seenURLs[item.URL], seenNames[item.Name] = true, true
+		if item.ID != 0 {
+			if _, dup := p.state.sourceURLs[item.ID]; dup {
+				p.errorf("endpoints[%d]: duplicate endpoint id %d", i, item.ID)
+				continue
+			}
+			p.state.sourceURLs[item.ID] = item.URL
+		}
+
+		if current := byURL[item.URL]; current != nil {
+			matched[current.ID] = item.URL
+			matches[i] = current
+		}
+		valid[i] = true
+	}
+	// URL 没有对应的端点再按名称对应 (视为修改了 URL)
+	for i, item := range p.doc.Endpoints {
+		if !valid[i] || matches[i] != nil {
+			continue
+		}
+		if current := byName[item.Name]; current != nil {
+			if _, taken := matched[current.ID]; !taken {
+				matched[current.ID] = item.URL
+				matches[i] = current
+			}
+		}
+	}
+
+	// 名称被其他现有端点占用时, merge 模式下无法导入 (replace 模式下该端点会先被删除)
+	if !p.replace {
+		for i, item := range p.doc.Endpoints {
+			if !valid[i] {
+				continue
+			}
+			if other := byName[item.Name]; other != nil && other != matches[i] {
+				if _, renamed := matched[other.ID]; !renamed {
+					p.errorf("endpoint %s: name %q is already used by endpoint %s", item.URL, item.Name, other.URL)
+				}
+			}
+		}
+	}
+
+	for _, endpoint := range existing {
+		if _, keep := matched[endpoint.ID]; keep || !p.removes(endpoint.Managed) {
+			continue
+		}
+		p.guard("endpoint", endpoint.URL, endpoint.Managed)
+		id, url := endpoint.ID, endpoint.URL
+		p.add("endpoint", url, AuditActionDelete, endpoint, nil, func(ctx context.Context) error {
+			delete(p.state.endpointIDs, url)
+			return p.service.DeleteEndpoint(ctx, id)
+		})
+	}
+
+	// 先更新再新建, 让改名释放出的名称可以被新端点使用
+	for i := range p.doc.Endpoints {
+		if valid[i] && matches[i] != nil {
+			p.planEndpointUpdate(p.doc.Endpoints[i], matches[i])
+		}
+	}
+	for i := range p.doc.Endpoints {
+		if valid[i] && matches[i] == nil {
+			p.planEndpointCreate(p.doc.Endpoints[i])
+		}
+	}
+
+	for i := range p.doc.Endpoints {
+		if !valid[i] {
+			continue
+		}
+		item := p.doc.Endpoints[i]
+		var currentSources []model.DataSource
+		var currentRules []model.URLReplaceRule
+		if matches[i] != nil {
+			currentSources, currentRules = matches[i].DataSources, matches[i].URLReplaceRules
+		}
+		// 文档中端点的数据源与规则完全以文档为准 (配置文件同步时同样如此)
+		ownAll := p.replace || p.managed
+		p.planDataSources(item, currentSources, ownAll)
+		p.planRules(item.URL, item.URL, item.URLReplaceRules, currentRules, ownAll)
+	}
+	return nil
+}
+
+// purgeDeletedEndpoints 软删除的端点仍占用名称与 URL 的唯一索引, 使用前彻底清除
+func purgeDeletedEndpoints(ctx context.Context, url, name string) error {
+	if err := database.Conn(ctx).Unscoped().Where("deleted_at IS NOT NULL AND (url = ? OR name = ?)", url, name).
+		Delete(&model.APIEndpoint{}).Error; err != nil {
+		return fmt.Errorf("failed to purge deleted endpoint: %w", err)
+	}
+	return nil
+}
+
+// endpointFromImport 将文档端点转换为模型 (base 为现有端点的副本, 新建时为空)
+func endpointFromImport(item ExportEndpoint, base model.APIEndpoint) model.APIEndpoint {
+	base.DataSources, base.URLReplaceRules = nil, nil
+	base.Name = item.Name
+	base.URL = item.URL
+	base.Description = item.Description
+	base.IsActive = boolValue(item.IsActive, true)
+	base.ShowOnHomepage = boolValue(item.ShowOnHomepage, true)
+	base.SortOrder = item.SortOrder
+	base.RequestTimeout = item.RequestTimeout
+	return base
+}
+
+func (p *importPlanner) planEndpointCreate(item ExportEndpoint) {
+	desired := endpointFromImport(item, model.APIEndpoint{})
+	desired.Managed = p.managed
+	if desired.RequestTimeout < 0 {
+		p.errorf("endpoint %s: request_timeout must not be negative", item.URL)
+		return
+	}
+	p.add("endpoint", item.URL, AuditActionCreate, nil, &desired, func(ctx context.Context) error {
+		if err := purgeDeletedEndpoints(ctx, desired.URL, desired.Name); err != nil {
+			return err
+		}
+		endpoint := desired
+		if err := p.service.CreateEndpoint(ctx, &endpoint); err != nil {
+			return err
+		}
+		p.state.endpointIDs[endpoint.URL] = endpoint.ID
+		// 带默认值的布尔字段为 false 时 Create 不会写入, 需要再更新一次
+		if !desired.IsActive || !desired.ShowOnHomepage {
+			endpoint.IsActive, endpoint.ShowOnHomepage = desired.IsActive, desired.ShowOnHomepage
+			return p.service.UpdateEndpoint(ctx, &endpoint)
+		}
+		return nil
+	})
+}
+
+func (p *importPlanner) planEndpointUpdate(item ExportEndpoint, current *model.APIEndpoint) {
+	before := *current
+	before.DataSources, before.URLReplaceRules = nil, nil
+	desired := endpointFromImport(item, *current)
+	desired.Managed = p.managed || current.Managed
+	if desired.RequestTimeout < 0 {
+		p.errorf("endpoint %s: request_timeout must not be negative", item.URL)
+		return
+	}
+	if reflect.DeepEqual(auditSnapshot(&before), auditSnapshot(&desired)) {
+		p.plan.Summary.Unchanged++
+		return
+	}
+	p.guard("endpoint", item.URL, current.Managed)
+
+	oldURL := current.URL
+	p.add("endpoint", item.URL, AuditActionUpdate, &before, &desired, func(ctx context.Context) error {
+		if oldURL != desired.URL || before.Name != desired.Name {
+			if err := purgeDeletedEndpoints(ctx, desired.URL, desired.Name); err != nil {
+				return err
+			}
+		}
+		// UpdateEndpoint 不修改受管标记, 先单独写入, 使本次产生的端点版本包含该标记
+		if desired.Managed != before.Managed {
+			if err := database.Conn(ctx).Model(&model.APIEndpoint{}).Where("id = ?", desired.ID).Update("managed", desired.Managed).Error; err != nil {
+				return fmt.Errorf("failed to mark endpoint as managed: %w", err)
+			}
+		}
+		endpoint := desired
+		// UpdateEndpoint 中 0 表示沿用原值, 负数表示恢复全局默认超时
+		if endpoint.RequestTimeout == 0 {
+			endpoint.RequestTimeout = -1
+		}
+		if err := p.service.UpdateEndpoint(ctx, &endpoint); err != nil {
+			return err
+		}
+		if desired.SortOrder == 0 && before.SortOrder != 0 {
+			if err := database.Conn(ctx).Model(&model.APIEndpoint{}).Where("id = ?", endpoint.ID).Update("sort_order", 0).Error; err != nil {
+				return fmt.Errorf("failed to reset sort order: %w", err)
+			}
+		}
+		if oldURL != desired.URL {
+			database.AfterCommit(ctx, func() { p.service.cacheManager.InvalidateMemoryCache(oldURL) })
+			delete(p.state.endpointIDs, oldURL)
+		}
+		p.state.endpointIDs[desired.URL] = endpoint.ID
+		return nil
+	})
+}
+
+// planDataSources 数据源在端点内按名称对应; removeAll 为 true 时删除文档中没有的数据源
+func (p *importPlanner) planDataSources(item ExportEndpoint, current []model.DataSource, removeAll bool) {
+	byName := make(map[string]model.DataSource, len(current))
+	for _, ds := range current {
+		if _, dup := byName[ds.Name]; !dup {
+			byName[ds.Name] = ds
+		}
+	}
+
+	kept := make(map[uint]bool)
+	seen := make(map[string]bool)
+	for i, source := range item.DataSources {
+		key := item.URL + "#" + source.Name
+		if strings.TrimSpace(source.Name) == "" {
+			p.errorf("endpoint %s: data_sources[%d]: name is required", item.URL, i)
+			continue
+		}
+		if seen[source.Name] {
+			p.errorf("data source %s: duplicate name", key)
+			continue
+		}
+		seen[source.Name] = true
+		if err := validateDataSourceType(source.Type); err != nil {
+			p.errorf("data source %s: %v", key, err)
+			continue
+		}
+
+		existing, found := byName[source.Name]
+		cfg := source.Config
+		if cfg == nil {
+			cfg = map[string]interface{}{}
+		}
+		if !p.doc.Secrets {
+			var currentCfg interface{}
+			if found {
+				json.Unmarshal([]byte(existing.Config), &currentCfg)
+			}
+			restored, ok := restoreMaskedValues(cfg, currentCfg)
+			if !ok {
+				p.errorf("data source %s: config contains masked secrets without an existing value, export with secrets or fill them in", key)
+				continue
+			}
+			cfg, _ = restored.(map[string]interface{})
+		}
+		configJSON, err := remapEndpointIDs(cfg, p.state.resolve)
+		if err != nil {
+			p.errorf("data source %s: %v", key, err)
+			continue
+		}
+
+		desired := model.DataSource{Name: source.Name, Type: source.Type, Config: configJSON, IsActive: boolValue(source.IsActive, true), Managed: p.managed}
+		endpointURL := item.URL
+		if !found {
+			p.add("data_source", key, AuditActionCreate, nil, &desired, func(ctx context.Context) error {
+				ds := desired
+				ds.EndpointID = p.state.endpointIDs[endpointURL]
+				configJSON, err := remapEndpointIDs(cfg, p.state.resolve)
+				if err != nil {
+					return err
+				}
+				ds.Config = configJSON
+				if err := p.service.CreateDataSource(ctx, &ds); err != nil {
+					return err
+				}
+				if !desired.IsActive {
+					ds.IsActive = false
+					return p.service.UpdateDataSource(ctx, &ds)
+				}
+				return nil
+			})
+			continue
+		}
+
+		kept[existing.ID] = true
+		updated := existing
+		updated.Type, updated.Config, updated.IsActive = desired.Type, desired.Config, desired.IsActive
+		updated.Managed = p.managed || existing.Managed
+		if reflect.DeepEqual(auditSnapshot(&existing), auditSnapshot(&updated)) {
+			p.plan.Summary.Unchanged++
+			continue
+		}
+		p.guard("data source", key, existing.Managed)
+		p.add("data_source", key, AuditActionUpdate, &existing, &updated, func(ctx context.Context) error {
+			ds := updated
+			configJSON, err := remapEndpointIDs(cfg, p.state.resolve)
+			if err != nil {
+				return err
+			}
+			ds.Config = configJSON
+			return p.service.UpdateDataSource(ctx, &ds)
+		})
+	}
+
+	for _, ds := range current {
+		if kept[ds.ID] || !(removeAll || p.removes(ds.Managed)) {
+			continue
+		}
+		p.guard("data source", item.URL+"#"+ds.Name, ds.Managed)
+		id := ds.ID
+		p.add("data_source", item.URL+"#"+ds.Name, AuditActionDelete, &ds, nil, func(ctx context.Context) error {
+			return p.service.DeleteDataSource(ctx, id)
+		})
+	}
+}
+
+// planRules 替换规则在所属端点 (endpointURL 为空表示全局) 内按名称对应; removeAll 为 true 时删除文档中没有的规则
+func (p *importPlanner) planRules(scope, endpointURL string, rules []ExportURLReplaceRule, current []model.URLReplaceRule, removeAll bool) {
+	byName := make(map[string]model.URLReplaceRule, len(current))
+	for _, rule := range current {
+		if _, dup := byName[rule.Name]; !dup {
+			byName[rule.Name] = rule
+		}
+	}
+
+	kept := make(map[uint]bool)
+	seen := make(map[string]bool)
+	for i, item := range rules {
+		key := scope + "#" + item.Name
+		if strings.TrimSpace(item.Name) == "" {
+			p.errorf("%s: url_replace_rules[%d]: name is required", scope, i)
+			continue
+		}
+		if seen[item.Name] {
+			p.errorf("URL replace rule %s: duplicate name", key)
+			continue
+		}
+		seen[item.Name] = true
+
+		existing, found := byName[item.Name]
+		desired := existing
+		desired.Endpoint = nil
+		desired.Name, desired.Mode, desired.FromURL, desired.ToURL = item.Name, item.Mode, item.FromURL, item.ToURL
+		desired.Priority, desired.StopOnMatch, desired.IsActive = item.Priority, item.StopOnMatch, boolValue(item.IsActive, true)
+		desired.Managed = p.managed || existing.Managed
+		if err := ValidateURLReplaceRule(&desired); err != nil {
+			p.errorf("URL replace rule %s: %v", key, err)
+			continue
+		}
+
+		if !found {
+			p.add("url_replace_rule", key, AuditActionCreate, nil, &desired, func(ctx context.Context) error {
+				rule := desired
+				if endpointURL != "" {
+					id := p.state.endpointIDs[endpointURL]
+					rule.EndpointID = &id
+				}
+				if err := p.service.CreateURLReplaceRule(ctx, &rule); err != nil {
+					return err
+				}
+				if !desired.IsActive {
+					rule.IsActive = false
+					return p.service.UpdateURLReplaceRule(ctx, &rule)
+				}
+				return nil
+			})
+			continue
+		}
+
+		kept[existing.ID] = true
+		if reflect.DeepEqual(auditSnapshot(&existing), auditSnapshot(&desired)) {
+			p.plan.Summary.Unchanged++
+			continue
+		}
+		p.guard("URL replace rule", key, existing.Managed)
+		p.add("url_replace_rule", key, AuditActionUpdate, &existing, &desired, func(ctx context.Context) error {
+			rule := desired
+			return p.service.UpdateURLReplaceRule(ctx, &rule)
+		})
+	}
+
+	for _, rule := range current {
+		if kept[rule.ID] || !(removeAll || p.removes(rule.Managed)) {
+			continue
+		}
+		p.guard("URL replace rule", scope+"#"+rule.Name, rule.Managed)
+		id := rule.ID
+		p.add("url_replace_rule", scope+"#"+rule.Name, AuditActionDelete, &rule, nil, func(ctx context.Context) error {
+			return p.service.DeleteURLReplaceRule(ctx, id)
+		})
+	}
+}
+
+func (p *importPlanner) planGlobalRules() error {
+	var current []model.URLReplaceRule
+	if err := database.DB.Where("endpoint_id IS NULL").Order("id").Find(&current).Error; err != nil {
+		return fmt.Errorf("failed to list global URL replace rules: %w", err)
+	}
+	p.planRules("global", "", p.doc.GlobalURLReplaceRules, current, false)
+	return nil
+}
+
+// planBlockedDomains 黑名单按 (domain, match_type, path) 对应; replace 模式只删除手动添加的规则
+func (p *importPlanner) planBlockedDomains() error {
+	var current []model.BlockedDomain
+	if err := database.DB.Find(&current).Error; err != nil {
+		return fmt.Errorf("failed to list blocked domains: %w", err)
+	}
+	blockKey := func(rule model.BlockedDomain) string {
+		return rule.MatchType + ":" + rule.Domain + rule.Path
+	}
+	byKey := make(map[string]model.BlockedDomain, len(current))
+	for _, rule := range current {
+		byKey[blockKey(rule)] = rule
+	}
+
+	kept := make(map[uint]bool)
+	seen := make(map[string]bool)
+	for i, item := range p.doc.BlockedDomains {
+		desired := model.BlockedDomain{Domain: item.Domain, MatchType: item.MatchType, Path: item.Path, Reason: item.Reason, ExpiresAt: item.ExpiresAt}
+		if err := NormalizeBlockedDomain(&desired); err != nil {
+			p.errorf("blocked_domains[%d]: %v", i, err)
+			continue
+		}
+		key := blockKey(desired)
+		if seen[key] {
+			p.errorf("blocked domain %s: duplicate rule", key)
+			continue
+		}
+		seen[key] = true
+		if desired.ExpiresAt != nil && !desired.ExpiresAt.After(time.Now()) {
+			p.warnf("blocked domain %s: already expired, skipped", key)
+			continue
+		}
+		desired.Source = BlockSourceManual
+
+		existing, found := byKey[key]
+		desired.Managed = p.managed || existing.Managed
+		if !found {
+			p.add("blocked_domain", key, AuditActionCreate, nil, &desired, func(ctx context.Context) error {
+				rule := desired
+				if err := saveImportedBlockRule(ctx, &rule); err != nil {
+					return err
+				}
+				RecordAudit(ctx, AuditActionCreate, "blocked_domain", rule.ID, nil, &rule)
+				return nil
+			})
+			continue
+		}
+
+		kept[existing.ID] = true
+		updated := existing
+		updated.Reason, updated.Source, updated.ExpiresAt, updated.Managed = desired.Reason, desired.Source, desired.ExpiresAt, desired.Managed
+		if reflect.DeepEqual(auditSnapshot(&existing), auditSnapshot(&updated)) {
+			p.plan.Summary.Unchanged++
+			continue
+		}
+		p.guard("blocked domain", key, existing.Managed)
+		p.add("blocked_domain", key, AuditActionUpdate, &existing, &updated, func(ctx context.Context) error {
+			rule := desired
+			if err := saveImportedBlockRule(ctx, &rule); err != nil {
+				return err
+			}
+			RecordAudit(ctx, AuditActionUpdate, "blocked_domain", existing.ID, &existing, &updated)
+			return nil
+		})
+	}
+
+	for _, rule := range current {
+		if kept[rule.ID] || rule.Source != BlockSourceManual || !p.removes(rule.Managed) {
+			continue
+		}
+		p.guard("blocked domain", blockKey(rule), rule.Managed)
+		before := rule
+		p.add("blocked_domain", blockKey(rule), AuditActionDelete, &before, nil, func(ctx context.Context) error {
+			if err := GetDomainStatsService().DeleteBlockRule(ctx, before.ID); err != nil {
+				return err
+			}
+			RecordAudit(ctx, AuditActionDelete, "blocked_domain", before.ID, &before, nil)
+			return nil
+		})
+	}
+	return nil
+}
+
+// saveImportedBlockRule 写入手动黑名单规则 (同键规则被覆盖) 并同步受管标记
+func saveImportedBlockRule(ctx context.Context, rule *model.BlockedDomain) error {
+	if err := GetDomainStatsService().CreateBlockRule(ctx, rule); err != nil {
+		return err
+	}
+	return database.Conn(ctx).Model(&model.BlockedDomain{}).
+		Where("domain = ? AND match_type = ? AND path = ?", rule.Domain, rule.MatchType, rule.Path).
+		Update("managed", rule.Managed).Error
+}
+
+// planConfigs 系统配置按键对应; 不删除首页内容
+func (p *importPlanner) planConfigs() error {
+	current, err := database.ListConfigs()
+	if err != nil {
+		return fmt.Errorf("failed to list configs: %w", err)
+	}
+	byKey := make(map[string]model.Config, len(current))
+	for _, cfg := range current {
+		byKey[cfg.Key] = cfg
+	}
+
+	seen := make(map[string]bool)
+	for i, item := range p.doc.Configs {
+		item.Key = strings.TrimSpace(item.Key)
+		if item.Key == "" {
+			p.errorf("configs[%d]: key is required", i)
+			continue
+		}
+		if seen[item.Key] {
+			p.errorf("config %s: duplicate key", item.Key)
+			continue
+		}
+		seen[item.Key] = true
+		if item.Type == "" {
+			item.Type = "string"
+		}
+
+		existing, found := byKey[item.Key]
+		if !p.doc.Secrets && IsMaskedConfigValue(item.Key, item.Value) {
+			if !found {
+				p.warnf("config %s: value is masked and does not exist yet, skipped", item.Key)
+				continue
+			}
+			item.Value = existing.Value
+		}
+
+		desired := model.Config{Key: item.Key, Value: item.Value, Type: item.Type, Managed: p.managed || existing.Managed}
+		action := AuditActionCreate
+		var before *model.Config
+		if found {
+			if existing.Value == desired.Value && existing.Type == desired.Type && existing.Managed == desired.Managed {
+				p.plan.Summary.Unchanged++
+				continue
+			}
+			p.guard("config", item.Key, existing.Managed)
+			action, before = AuditActionUpdate, &existing
+			desired.ID, desired.CreatedAt, desired.UpdatedAt = existing.ID, existing.CreatedAt, existing.UpdatedAt
+		}
+		p.add("config", item.Key, action, before, &desired, func(ctx context.Context) error {
+			if err := database.SetConfig(ctx, desired.Key, desired.Value, desired.Type); err != nil {
+				return err
+			}
+			if err := database.Conn(ctx).Model(&model.Config{}).Where("key = ?", desired.Key).Update("managed", desired.Managed).Error; err != nil {
+				return err
+			}
+			RecordAudit(ctx, action, "config", desired.Key, before, &desired)
+			return nil
+		})
+	}
+
+	for _, cfg := range current {
+		if seen[cfg.Key] || cfg.Key == "homepage_content" || !p.removes(cfg.Managed) {
+			continue
+		}
+		p.guard("config", cfg.Key, cfg.Managed)
+		before := cfg
+		p.add("config", cfg.Key, AuditActionDelete, &before, nil, func(ctx context.Context) error {
+			if err := database.DeleteConfig(ctx, before.Key); err != nil {
+				return err
+			}
+			RecordAudit(ctx, AuditActionDelete, "config", before.Key, &before, nil)
+			return nil
+		})
+	}
+	return nil
+}
+
+// restoreMaskedValues 将文档中的 ****** 替换为现有配置中相同位置的值; 找不到现有值时返回 false
+func restoreMaskedValues(value, current interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		currentMap, _ := current.(map[string]interface{})
+		restored := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			r, ok := restoreMaskedValues(item, currentMap[k])
+			if !ok {
+				return nil, false
+			}
+			restored[k] = r
+		}
+		return restored, true
+	case []interface{}:
+		currentList, _ := current.([]interface{})
+		restored := make([]interface{}, len(v))
+		for i, item := range v {
+			var currentItem interface{}
+			if i < len(currentList) {
+				currentItem = currentList[i]
+			}
+			r, ok := restoreMaskedValues(item, currentItem)
+			if !ok {
+				return nil, false
+			}
+			restored[i] = r
+		}
+		return restored, true
+	case string:
+		if v == auditMask {
+			s, ok := current.(string)
+			return s, ok && s != ""
+		}
+	}
+	return value, true
+}
+
+// remapEndpointIDs 将 endpoint 类型数据源引用的文档端点 ID 转换为当前环境的 ID, 返回配置 JSON
+func remapEndpointIDs(cfg map[string]interface{}, resolve func(uint) (uint, error)) (string, error) {
+	out := make(map[string]interface{}, len(cfg))
+	for k, v := range cfg {
+		out[k] = v
+	}
+	if endpointCfg, ok := cfg["endpoint_config"].(map[string]interface{}); ok {
+		remapped := make(map[string]interface{}, len(endpointCfg))
+		for k, v := range endpointCfg {
+			remapped[k] = v
+		}
+		if ids, ok := endpointCfg["endpoint_ids"].([]interface{}); ok {
+			targets := make([]interface{}, 0, len(ids))
+			for _, raw := range ids {
+				sourceID, ok := toUint(raw)
+				if !ok {
+					return "", fmt.Errorf("invalid endpoint id %v in endpoint_config", raw)
+				}
+				targetID, err := resolve(sourceID)
+				if err != nil {
+					return "", err
+				}
+				targets = append(targets, targetID)
+			}
+			remapped["endpoint_ids"] = targets
+		}
+		out["endpoint_config"] = remapped
+	}
+
+	data, err := json.Marshal(out)
+	if err != nil {
+		return "", fmt.Errorf("invalid data source config: %w", err)
+	}
+	var check model.DataSourceConfig
+	if err := json.Unmarshal(data, &check); err != nil {
+		return "", fmt.Errorf("invalid data source config: %w", err)
+	}
+	return string(data), nil
+}
+
+// toUint 转换 JSON (float64) 或 YAML (int) 解码出的非负整数
+func toUint(v interface{}) (uint, bool) {
+	switch n := v.(type) {
+	case int:
+		return uint(n), n >= 0
+	case int64:
+		return uint(n), n >= 0
+	case uint64:
+		return uint(n), true
+	case float64:
+		return uint(n), n >= 0 && n == float64(uint(n))
+	}
+	return 0, false
+}
+
+func boolPtr(v bool) *bool {
+	return &v
+}
+
+func boolValue(v *bool, defaultValue bool) bool {
+	if v == nil {
+		return defaultValue
+	}
+	return *v
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"random-api-go/database"
+	"random-api-go/model"
+)
+
+// 端点 CORS 覆盖模式
+const (
+	CORSModeEnabled  = "enabled"
+	CORSModeDisabled = "disabled"
+)
+
+// 全局 CORS 配置默认值
+const (
+	defaultCORSMethods = "GET, HEAD, OPTIONS"
+	defaultCORSHeaders = "Content-Type, X-API-Key"
+	defaultCORSMaxAge  = 600
+)
+
+// CORSExposeHeaders 允许跨域读取的响应头 (限流信息)
+const CORSExposeHeaders = "X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, Retry-After"
+
+// CORSConfig 请求最终使用的 CORS 配置
+type CORSConfig struct {
+	Origins []string
+	Methods []string
+	Headers []string
+	MaxAge  int
+}
+
+// AllowOrigin 判断 Origin 是否允许, 返回应写入 Access-Control-Allow-Origin 的值 (空表示不允许)
+// 支持: * 任意来源; https://example.com 精确匹配; https://*.example.com 匹配子域名 (协议需一致)
+func (c *CORSConfig) AllowOrigin(origin string) string {
+	if origin == "" {
+		return ""
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+
+	for _, pattern := range c.Origins {
+		if pattern == "*" {
+			return "*"
+		}
+		if strings.EqualFold(pattern, origin) {
+			return origin
+		}
+		p, err := url.Parse(pattern)
+		if err != nil || !strings.EqualFold(p.Scheme, u.Scheme) {
+			continue
+		}
+		if strings.Contains(p.Host, "*") && matchHostPattern(p.Hostname(), u.Hostname()) && p.Port() == u.Port() {
+			return origin
+		}
+	}
+	return ""
+}
+
+// globalCORSConfig 读取全局 CORS 配置; 未开启时返回 nil
+func globalCORSConfig() *CORSConfig {
+	if database.GetConfig("cors_enabled", "false") != "true" {
+		return nil
+	}
+	return &CORSConfig{
+		Origins: splitList(database.GetConfig("cors_origins", "*")),
+		Methods: splitList(database.GetConfig("cors_methods", defaultCORSMethods)),
+		Headers: splitList(database.GetConfig("cors_headers", defaultCORSHeaders)),
+		MaxAge:  getIntConfig("cors_max_age", defaultCORSMaxAge),
+	}
+}
+
+// ResolveCORS 计算请求路径使用的 CORS 配置, 返回 nil 表示不处理跨域
+// 公开 API (/api/...) 使用全局配置; 随机端点可在端点策略中覆盖
+func (s *EndpointPolicyService) ResolveCORS(path string, randomEndpoint bool) *CORSConfig {
+	global := globalCORSConfig()
+	if !randomEndpoint {
+		return global
+	}
+
+	policy := s.lookup(path)
+	if policy == nil || policy.CORSMode == "" {
+		return global
+	}
+	if policy.CORSMode == CORSModeDisabled {
+		return nil
+	}
+
+	// 端点单独开启: 未覆盖的字段沿用全局配置 (全局未开启时使用默认值)
+	cfg := global
+	if cfg == nil {
+		cfg = &CORSConfig{
+			Origins: splitList(database.GetConfig("cors_origins", "*")),
+			Methods: splitList(defaultCORSMethods),
+			Headers: splitList(defaultCORSHeaders),
+			MaxAge:  defaultCORSMaxAge,
+		}
+	}
+	merged := *cfg
+	if len(policy.CORSOrigins) > 0 {
+		merged.Origins = policy.CORSOrigins
+	}
+	if len(policy.CORSMethods) > 0 {
+		merged.Methods = policy.CORSMethods
+	}
+	if len(policy.CORSHeaders) > 0 {
+		merged.Headers = policy.CORSHeaders
+	}
+	if policy.CORSMaxAge > 0 {
+		merged.MaxAge = policy.CORSMaxAge
+	}
+	return &merged
+}
+
+// normalizeCORSPolicy 校验并规范化端点的 CORS 覆盖配置
+func normalizeCORSPolicy(policy *model.EndpointPolicy) error {
+	policy.CORSMode = strings.ToLower(strings.TrimSpace(policy.CORSMode))
+	switch policy.CORSMode {
+	case "", CORSModeEnabled, CORSModeDisabled:
+	default:
+		return fmt.Errorf("unsupported cors mode: %s, supported modes: enabled, disabled", policy.CORSMode)
+	}
+	if policy.CORSMaxAge < 0 {
+		return fmt.Errorf("cors_max_age must not be negative")
+	}
+
+	policy.CORSOrigins = trimList(policy.CORSOrigins)
+	for _, origin := range policy.CORSOrigins {
+		if origin == "*" {
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid cors origin %q, expected * or scheme://host", origin)
+		}
+	}
+	policy.CORSMethods = trimList(policy.CORSMethods)
+	for i, m := range policy.CORSMethods {
+		policy.CORSMethods[i] = strings.ToUpper(m)
+	}
+	policy.CORSHeaders = trimList(policy.CORSHeaders)
+	return nil
+}
+
+// splitList 解析逗号分隔的配置值
+func splitList(value string) []string {
+	return trimList(strings.Split(value, ","))
+}
+
+// trimList 去除空白与空项
+func trimList(items []string) []string {
+	result := make([]string, 0, len(items))
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -17,6 +18,7 @@ type DataSourceFetcher struct {
 	lankongFetcher *LankongFetcher
 	apiFetcher     *APIFetcher
 	s3Fetcher      *S3Fetcher
+	loadGroup      *LoadGroup
 }
 
 // NewDataSourceFetcher 创建数据源获取器
@@ -40,6 +42,7 @@ func NewDataSourceFetcher(cacheManager *CacheManager) *DataSourceFetcher {
 		lankongFetcher: lankongFetcher,
 		apiFetcher:     NewAPIFetcher(),
 		s3Fetcher:      NewS3Fetcher(),
+		loadGroup:      NewLoadGroup(),
 	}
 }
 
@@ -59,16 +62,28 @@ func getIntConfig(key string, defaultValue int) int {
 	return value
 }
 
-// FetchURLs 从数据源获取URL列表
-func (dsf *DataSourceFetcher) FetchURLs(dataSource *model.DataSource) ([]string, error) {
-	return dsf.FetchURLsWithOptions(dataSource, false)
+// FetchURLs 从数据源获取URL列表（请求路径使用）
+// 缓存为空且已有加载进行中时，按 cold_load_policy 配置快速失败或等待
+func (dsf *DataSourceFetcher) FetchURLs(ctx context.Context, dataSource *model.DataSource) ([]string, error) {
+	return dsf.fetchURLs(ctx, dataSource, false, coldLoadFailFast())
 }
 
 // FetchURLsWithOptions 从数据源获取URL列表，支持跳过缓存选项
-func (dsf *DataSourceFetcher) FetchURLsWithOptions(dataSource *model.DataSource, skipCache bool) ([]string, error) {
+// ctx 取消后正在进行的上游请求会被中断；与进行中的同源加载合并并等待其结果
+func (dsf *DataSourceFetcher) FetchURLsWithOptions(ctx context.Context, dataSource *model.DataSource, skipCache bool) ([]string, error) {
+	return dsf.fetchURLs(ctx, dataSource, skipCache, false)
+}
+
+// coldLoadFailFast 冷加载策略: fail_fast（默认）时并发请求不排队等待，直接返回 ErrColdLoadInProgress；wait 时等待共享加载
+func coldLoadFailFast() bool {
+	return database.GetConfig("cold_load_policy", "fail_fast") != "wait"
+}
+
+// fetchURLs 获取URL列表，同一数据源的并发加载会合并为一次上游请求
+func (dsf *DataSourceFetcher) fetchURLs(ctx context.Context, dataSource *model.DataSource, skipCache, failFast bool) ([]string, error) {
 	// API类型的数据源直接实时请求，不使用缓存
 	if dataSource.Type == "api_get" || dataSource.Type == "api_post" {
-		return dsf.fetchAPIURLs(dataSource)
+		return dsf.fetchAPIURLs(ctx, dataSource)
 	}
 
 	// 构建内存缓存的key（使用数据源ID）
@@ -81,6 +96,22 @@ func (dsf *DataSourceFetcher) FetchURLsWithOptions(dataSource *model.DataSource,
 		}
 	}
 
+	// 拷贝一份数据源，加载在独立 goroutine 中运行，避免与调用方共享可变状态
+	ds := *dataSource
+	urls, err := dsf.loadGroup.Do(ctx, cacheKey, failFast, func(loadCtx context.Context) ([]string, error) {
+		return dsf.loadURLs(loadCtx, &ds, cacheKey)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if ds.LastSync != nil {
+		dataSource.LastSync = ds.LastSync
+	}
+	return urls, nil
+}
+
+// loadURLs 从上游加载URL并写入内存缓存
+func (dsf *DataSourceFetcher) loadURLs(ctx context.Context, dataSource *model.DataSource, cacheKey string) ([]string, error) {
 	var urls []string
 	var err error
 
@@ -88,13 +119,13 @@ func (dsf *DataSourceFetcher) FetchURLsWithOptions(dataSource *model.DataSource,
 
 	switch dataSource.Type {
 	case "lankong":
-		urls, err = dsf.fetchLankongURLs(dataSource)
+		urls, err = dsf.fetchLankongURLs(ctx, dataSource)
 	case "manual":
 		urls, err = dsf.fetchManualURLs(dataSource)
 	case "endpoint":
 		urls, err = dsf.fetchEndpointURLs(dataSource)
 	case "s3":
-		urls, err = dsf.fetchS3URLs(dataSource)
+		urls, err = dsf.fetchS3URLs(ctx, dataSource)
 	default:
 		return nil, fmt.Errorf("unsupported data source type: %s", dataSource.Type)
 	}
@@ -122,14 +153,19 @@ func (dsf *DataSourceFetcher) FetchURLsWithOptions(dataSource *model.DataSource,
 	return urls, nil
 }
 
+// LoadStats 获取并发加载合并统计
+func (dsf *DataSourceFetcher) LoadStats() map[string]interface{} {
+	return dsf.loadGroup.Stats()
+}
+
 // fetchLankongURLs 获取兰空图床URL
-func (dsf *DataSourceFetcher) fetchLankongURLs(dataSource *model.DataSource) ([]string, error) {
+func (dsf *DataSourceFetcher) fetchLankongURLs(ctx context.Context, dataSource *model.DataSource) ([]string, error) {
 	var config model.LankongConfig
 	if err := json.Unmarshal([]byte(dataSource.Config), &config); err != nil {
 		return nil, fmt.Errorf("invalid lankong config: %w", err)
 	}
 
-	return dsf.lankongFetcher.FetchURLs(&config)
+	return dsf.lankongFetcher.FetchURLs(ctx, &config)
 }
 
 // fetchManualURLs 获取手动配置的URL
@@ -157,14 +193,14 @@ func (dsf *DataSourceFetcher) fetchManualURLs(dataSource *model.DataSource) ([]s
 }
 
 // fetchAPIURLs 获取API接口URL (实时请求，不缓存)
-func (dsf *DataSourceFetcher) fetchAPIURLs(dataSource *model.DataSource) ([]string, error) {
+func (dsf *DataSourceFetcher) fetchAPIURLs(ctx context.Context, dataSource *model.DataSource) ([]string, error) {
 	var config model.APIConfig
 	if err := json.Unmarshal([]byte(dataSource.Config), &config); err != nil {
 		return nil, fmt.Errorf("invalid API config: %w", err)
 	}
 
 	// 对于API类型的数据源，直接进行实时请求，不使用预存储的数据
-	return dsf.apiFetcher.FetchSingleURL(&config)
+	return dsf.apiFetcher.FetchSingleURL(ctx, &config)
 }
 
 // fetchEndpointURLs 获取端点URL (直接返回端点URL列表)
@@ -190,13 +226,13 @@ func (dsf *DataSourceFetcher) fetchEndpointURLs(dataSource *model.DataSource) ([
 }
 
 // fetchS3URLs 获取S3存储桶URL
-func (dsf *DataSourceFetcher) fetchS3URLs(dataSource *model.DataSource) ([]string, error) {
+func (dsf *DataSourceFetcher) fetchS3URLs(ctx context.Context, dataSource *model.DataSource) ([]string, error) {
 	var config model.S3Config
 	if err := json.Unmarshal([]byte(dataSource.Config), &config); err != nil {
 		return nil, fmt.Errorf("invalid S3 config: %w", err)
 	}
 
-	return dsf.s3Fetcher.FetchURLs(&config)
+	return dsf.s3Fetcher.FetchURLs(ctx, &config)
 }
 
 // updateDataSourceSyncTime 更新数据源的同步时间
@@ -209,10 +245,11 @@ func (dsf *DataSourceFetcher) updateDataSourceSyncTime(dataSource *model.DataSou
 }
 
 // PreloadDataSource 预加载数据源（在保存时调用）
-func (dsf *DataSourceFetcher) PreloadDataSource(dataSource *model.DataSource) error {
+func (dsf *DataSourceFetcher) PreloadDataSource(ctx context.Context, dataSource *model.DataSource) error {
 	log.Printf("开始预加载数据源 (类型: %s, ID: %d)", dataSource.Type, dataSource.ID)
 
-	_, err := dsf.FetchURLs(dataSource)
+	// 预加载需要等待结果，不走请求路径的快速失败策略
+	_, err := dsf.FetchURLsWithOptions(ctx, dataSource, false)
 	if err != nil {
 		return fmt.Errorf("failed to preload data source %d: %w", dataSource.ID, err)
 	}
@@ -222,10 +259,10 @@ func (dsf *DataSourceFetcher) PreloadDataSource(dataSource *model.DataSource) er
 }
 
 // RefreshDataSource 强制刷新数据源（跳过缓存）
-func (dsf *DataSourceFetcher) RefreshDataSource(dataSource *model.DataSource) error {
+func (dsf *DataSourceFetcher) RefreshDataSource(ctx context.Context, dataSource *model.DataSource) error {
 	log.Printf("开始强制刷新数据源 (类型: %s, ID: %d)", dataSource.Type, dataSource.ID)
 
-	_, err := dsf.FetchURLsWithOptions(dataSource, true) // 跳过缓存
+	_, err := dsf.FetchURLsWithOptions(ctx, dataSource, true) // 跳过缓存
 	if err != nil {
 		return fmt.Errorf("failed to refresh data source %d: %w", dataSource.ID, err)
 	}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"random-api-go/model"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// 域名黑名单匹配方式
+const (
+	BlockMatchExact  = "exact"  // 精确匹配主机名
+	BlockMatchSuffix = "suffix" // 匹配域名自身及所有子域名 (*.spam.com)
+	BlockMatchETLD1  = "etld1"  // 按可注册域名 (eTLD+1) 匹配, a.spam.co.uk 与 b.spam.co.uk 同属 spam.co.uk
+	BlockMatchRegex  = "regex"  // 正则匹配主机名
+)
+
+// 黑名单规则来源
+const (
+	BlockSourceManual = "manual" // 管理员手动添加
+	BlockSourceAuto   = "auto"   // 滥用检测自动添加 (带过期时间)
+)
+
+// blockRule 编译后的黑名单规则
+type blockRule struct {
+	id        uint
+	matchType string
+	value     string
+	path      string // 为空表示作用于全部路径
+	re        *regexp.Regexp
+}
+
+// blockRuleSet 内存中的黑名单规则集
+// 精确规则按域名建索引, 其余规则顺序匹配
+type blockRuleSet struct {
+	exact    map[string][]*blockRule
+	patterns []*blockRule
+}
+
+// blockHit 尚未写入数据库的命中计数
+type blockHit struct {
+	count   uint64
+	lastHit time.Time
+}
+
+// NormalizeBlockedDomain 校验并规范化黑名单规则
+// 未指定匹配方式时: *.spam.com / .spam.com 视为 suffix, 其他视为 exact
+func NormalizeBlockedDomain(rule *model.BlockedDomain) error {
+	rule.Domain = strings.TrimSpace(rule.Domain)
+	rule.Path = strings.TrimSpace(rule.Path)
+	if rule.Domain == "" {
+		return fmt.Errorf("domain is required")
+	}
+	if rule.Path != "" && !strings.HasPrefix(rule.Path, "/") {
+		rule.Path = "/" + rule.Path
+	}
+
+	if rule.MatchType == "" {
+		rule.MatchType = BlockMatchExact
+		if strings.HasPrefix(rule.Domain, "*.") || strings.HasPrefix(rule.Domain, ".") {
+			rule.MatchType = BlockMatchSuffix
+		}
+	}
+
+	switch rule.MatchType {
+	case BlockMatchExact:
+		rule.Domain = strings.ToLower(rule.Domain)
+		if rule.Domain == "direct" || rule.Domain == "unknown" {
+			return fmt.Errorf("direct/unknown cannot be blocked")
+		}
+	case BlockMatchSuffix:
+		rule.Domain = strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(rule.Domain), "*"), ".")
+		if rule.Domain == "" || !strings.Contains(rule.Domain, ".") {
+			return fmt.Errorf("suffix must contain at least one dot, got %q", rule.Domain)
+		}
+	case BlockMatchETLD1:
+		etld1, err := publicsuffix.EffectiveTLDPlusOne(strings.ToLower(rule.Domain))
+		if err != nil {
+			return fmt.Errorf("invalid registrable domain %q: %w", rule.Domain, err)
+		}
+		rule.Domain = etld1
+	case BlockMatchRegex:
+		if _, err := regexp.Compile(rule.Domain); err != nil {
+			return fmt.Errorf("invalid regex %q: %w", rule.Domain, err)
+		}
+	default:
+		return fmt.Errorf("unsupported match type: %s, supported types: exact, suffix, etld1, regex", rule.MatchType)
+	}
+	return nil
+}
+
+// newBlockRuleSet 编译数据库中的黑名单规则 (跳过已过期的规则)
+func newBlockRuleSet(rows []model.BlockedDomain) *blockRuleSet {
+	set := &blockRuleSet{exact: make(map[string][]*blockRule)}
+	now := time.Now()
+	for _, row := range rows {
+		if row.ExpiresAt != nil && !row.ExpiresAt.After(now) {
+			continue
+		}
+		rule := &blockRule{
+			id:        row.ID,
+			matchType: row.MatchType,
+			value:     strings.ToLower(row.Domain),
+			path:      row.Path,
+		}
+		switch row.MatchType {
+		case "", BlockMatchExact:
+			set.exact[rule.value] = append(set.exact[rule.value], rule)
+			continue
+		case BlockMatchRegex:
+			re, err := regexp.Compile(row.Domain)
+			if err != nil {
+				continue
+			}
+			rule.re = re
+		}
+		set.patterns = append(set.patterns, rule)
+	}
+	return set
+}
+
+// match 查找命中的规则; path 为空时只匹配作用于全部路径的规则
+func (set *blockRuleSet) match(domain, path string) *blockRule {
+	for _, rule := range set.exact[domain] {
+		if rule.appliesTo(path) {
+			return rule
+		}
+	}
+
+	var etld1 string
+	for _, rule := range set.patterns {
+		if !rule.appliesTo(path) {
+			continue
+		}
+		switch rule.matchType {
+		case BlockMatchSuffix:
+			if domain == rule.value || strings.HasSuffix(domain, "."+rule.value) {
+				return rule
+			}
+		case BlockMatchETLD1:
+			if etld1 == "" {
+				etld1, _ = publicsuffix.EffectiveTLDPlusOne(domain)
+			}
+			if etld1 == rule.value {
+				return rule
+			}
+		case BlockMatchRegex:
+			if rule.re.MatchString(domain) {
+				return rule
+			}
+		}
+	}
+	return nil
+}
+
+// appliesTo 判断规则是否作用于该路径
+func (r *blockRule) appliesTo(path string) bool {
+	return r.path == "" || r.path == path
+}
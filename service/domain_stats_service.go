@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"log"
 	"net/url"
 	"sort"
@@ -21,8 +22,11 @@ type DomainStatsService struct {
 	mu          sync.RWMutex
 	memoryStats map[domainPathKey]*DomainStatsBatch
 
-	blockMu      sync.RWMutex
-	blockedHosts map[string]struct{}
+	blockMu    sync.RWMutex
+	blockRules *blockRuleSet
+
+	hitMu     sync.Mutex
+	blockHits map[uint]*blockHit // 规则 ID -> 未写入数据库的命中计数
 }
 
 type domainPathKey struct {
@@ -45,8 +49,9 @@ var (
 func GetDomainStatsService() *DomainStatsService {
 	domainStatsOnce.Do(func() {
 		domainStatsService = &DomainStatsService{
-			memoryStats:  make(map[domainPathKey]*DomainStatsBatch),
-			blockedHosts: make(map[string]struct{}),
+			memoryStats: make(map[domainPathKey]*DomainStatsBatch),
+			blockRules:  newBlockRuleSet(nil),
+			blockHits:   make(map[uint]*blockHit),
 		}
 		if err := domainStatsService.reloadBlockedDomains(); err != nil {
 			log.Printf("Failed to load blocked domains: %v", err)
@@ -72,15 +77,39 @@ func (s *DomainStatsService) ExtractDomain(referer string) string {
 	return strings.ToLower(domain)
 }
 
-// IsBlocked 判断指定域名是否被黑名单禁用
+// IsBlocked 判断指定域名是否被作用于全部路径的黑名单规则禁用 (不计入命中次数)
 func (s *DomainStatsService) IsBlocked(domain string) bool {
-	if domain == "" {
+	domain = strings.ToLower(domain)
+	if domain == "" || domain == "direct" || domain == "unknown" {
 		return false
 	}
 	s.blockMu.RLock()
 	defer s.blockMu.RUnlock()
-	_, ok := s.blockedHosts[strings.ToLower(domain)]
-	return ok
+	return s.blockRules.match(domain, "") != nil
+}
+
+// CheckBlocked 判断来源域名访问指定路径时是否被拦截, 命中时累加规则的命中次数
+func (s *DomainStatsService) CheckBlocked(domain, path string) bool {
+	domain = strings.ToLower(domain)
+	if domain == "" || domain == "direct" || domain == "unknown" {
+		return false
+	}
+	s.blockMu.RLock()
+	rule := s.blockRules.match(domain, path)
+	s.blockMu.RUnlock()
+	if rule == nil {
+		return false
+	}
+
+	s.hitMu.Lock()
+	if hit, ok := s.blockHits[rule.id]; ok {
+		hit.count++
+		hit.lastHit = time.Now()
+	} else {
+		s.blockHits[rule.id] = &blockHit{count: 1, lastHit: time.Now()}
+	}
+	s.hitMu.Unlock()
+	return true
 }
 
 // reloadBlockedDomains 从数据库重新加载黑名单到内存
@@ -89,17 +118,14 @@ func (s *DomainStatsService) reloadBlockedDomains() error {
 	if err := database.DB.Find(&rows).Error; err != nil {
 		return err
 	}
-	next := make(map[string]struct{}, len(rows))
-	for _, r := range rows {
-		next[strings.ToLower(r.Domain)] = struct{}{}
-	}
+	next := newBlockRuleSet(rows)
 	s.blockMu.Lock()
-	s.blockedHosts = next
+	s.blockRules = next
 	s.blockMu.Unlock()
 	return nil
 }
 
-// SetBlocked 设置/取消禁用指定域名, 同步更新内存缓存
+// SetBlocked 设置/取消禁用指定域名 (精确匹配, 作用于全部路径), 同步更新内存缓存
 // direct / unknown 是占位伪域名, 禁用会误伤无 referer 的真实浏览器访问, 在此层硬挡
 func (s *DomainStatsService) SetBlocked(domain, reason string, blocked bool) error {
 	domain = strings.ToLower(strings.TrimSpace(domain))
@@ -107,27 +133,119 @@ func (s *DomainStatsService) SetBlocked(domain, reason string, blocked bool) err
 		return nil
 	}
 	if blocked {
-		entry := model.BlockedDomain{Domain: domain, Reason: reason}
-		err := database.DB.Clauses(clause.OnConflict{
-			Columns:   []clause.Column{{Name: "domain"}},
-			DoUpdates: clause.AssignmentColumns([]string{"reason", "updated_at"}),
-		}).Create(&entry).Error
-		if err != nil {
-			return err
-		}
-	} else {
-		if err := database.DB.Unscoped().Where("domain = ?", domain).Delete(&model.BlockedDomain{}).Error; err != nil {
-			return err
-		}
+		return s.CreateBlockRule(context.Background(), &model.BlockedDomain{Domain: domain, MatchType: BlockMatchExact, Reason: reason})
+	}
+	if err := database.DB.Unscoped().
+		Where("domain = ? AND match_type = ? AND path = ?", domain, BlockMatchExact, "").
+		Delete(&model.BlockedDomain{}).Error; err != nil {
+		return err
 	}
 	return s.reloadBlockedDomains()
 }
 
-// ListBlockedDomains 返回当前所有被禁用的域名
+// CreateBlockRule 新增手动黑名单规则; 同一 (domain, match_type, path) 已存在时更新原因与过期时间
+// 手动规则覆盖同键的自动规则后变为手动 (ExpiresAt 为空即永久)
+func (s *DomainStatsService) CreateBlockRule(ctx context.Context, rule *model.BlockedDomain) error {
+	if err := NormalizeBlockedDomain(rule); err != nil {
+		return err
+	}
+	rule.Source = BlockSourceManual
+	return s.upsertBlockRule(ctx, rule)
+}
+
+// upsertBlockRule 写入黑名单规则并重新加载内存缓存 (在 ctx 的事务中执行时, 提交后才重新加载)
+func (s *DomainStatsService) upsertBlockRule(ctx context.Context, rule *model.BlockedDomain) error {
+	err := database.Conn(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "domain"}, {Name: "match_type"}, {Name: "path"}},
+		DoUpdates: clause.AssignmentColumns([]string{"reason", "source", "expires_at", "updated_at"}),
+	}).Create(rule).Error
+	if err != nil {
+		return err
+	}
+	s.reloadBlockedDomainsAfterCommit(ctx)
+	return nil
+}
+
+// expireBlockRules 删除已过期的黑名单规则
+func (s *DomainStatsService) expireBlockRules() error {
+	result := database.DB.Unscoped().
+		Where("expires_at IS NOT NULL AND expires_at <= ?", time.Now()).
+		Delete(&model.BlockedDomain{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+	log.Printf("已解除 %d 条到期的域名禁用规则", result.RowsAffected)
+	return s.reloadBlockedDomains()
+}
+
+// DeleteBlockRule 删除黑名单规则
+func (s *DomainStatsService) DeleteBlockRule(ctx context.Context, id uint) error {
+	result := database.Conn(ctx).Unscoped().Delete(&model.BlockedDomain{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	s.hitMu.Lock()
+	delete(s.blockHits, id)
+	s.hitMu.Unlock()
+	s.reloadBlockedDomainsAfterCommit(ctx)
+	return nil
+}
+
+// reloadBlockedDomainsAfterCommit 重新加载黑名单缓存; ctx 中有事务时在提交后执行, 失败只记录日志
+func (s *DomainStatsService) reloadBlockedDomainsAfterCommit(ctx context.Context) {
+	database.AfterCommit(ctx, func() {
+		if err := s.reloadBlockedDomains(); err != nil {
+			log.Printf("重新加载域名黑名单失败: %v", err)
+		}
+	})
+}
+
+// ListBlockedDomains 返回当前所有黑名单规则 (命中次数含内存中尚未写入的部分)
 func (s *DomainStatsService) ListBlockedDomains() ([]model.BlockedDomain, error) {
 	var rows []model.BlockedDomain
-	err := database.DB.Order("created_at DESC").Find(&rows).Error
-	return rows, err
+	if err := database.DB.Order("created_at DESC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	s.hitMu.Lock()
+	defer s.hitMu.Unlock()
+	for i := range rows {
+		if hit, ok := s.blockHits[rows[i].ID]; ok {
+			rows[i].HitCount += hit.count
+			lastHit := hit.lastHit
+			rows[i].LastHitAt = &lastHit
+		}
+	}
+	return rows, nil
+}
+
+// flushBlockHits 将内存中的规则命中次数写入数据库
+func (s *DomainStatsService) flushBlockHits() error {
+	s.hitMu.Lock()
+	current := s.blockHits
+	s.blockHits = make(map[uint]*blockHit)
+	s.hitMu.Unlock()
+
+	if len(current) == 0 {
+		return nil
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		for id, hit := range current {
+			if err := tx.Model(&model.BlockedDomain{}).Where("id = ?", id).UpdateColumns(map[string]interface{}{
+				"hit_count":   gorm.Expr("hit_count + ?", hit.count),
+				"last_hit_at": hit.lastHit,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 // RecordRequest 记录一次请求 (忽略静态文件与管理后台)
@@ -204,6 +322,12 @@ func (s *DomainStatsService) startPeriodicSave() {
 			if err := s.flushToDatabase(); err != nil {
 				log.Printf("Failed to flush domain stats to database: %v", err)
 			}
+			if err := s.flushBlockHits(); err != nil {
+				log.Printf("Failed to flush blocked domain hits to database: %v", err)
+			}
+			if err := s.expireBlockRules(); err != nil {
+				log.Printf("Failed to expire blocked domains: %v", err)
+			}
 		case <-cleanupTicker.C:
 			if err := s.CleanupOldStats(); err != nil {
 				log.Printf("Failed to cleanup old domain stats: %v", err)
@@ -225,7 +349,7 @@ func (s *DomainStatsService) flushToDatabase() error {
 
 	today := time.Now().Truncate(24 * time.Hour)
 
-	return database.DB.Transaction(func(tx *gorm.DB) error {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
 		for key, batch := range current {
 			// 累计表 upsert: count += batch.Count, last_seen 取较新值
 			total := model.DomainStats{
@@ -264,6 +388,13 @@ func (s *DomainStatsService) flushToDatabase() error {
 		}
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+
+	// 每次 flush 的批次即最近一分钟的流量, 在此基础上评估滥用规则
+	s.detectAbuse(current, today)
+	return nil
 }
 
 // memoryAggregateByDomain 把内存数据按 domain 维度聚合
@@ -305,11 +436,8 @@ func sortAndLimit(results []model.DomainStatsResult, top int) []model.DomainStat
 }
 
 func (s *DomainStatsService) attachBlockedFlag(results []model.DomainStatsResult) []model.DomainStatsResult {
-	s.blockMu.RLock()
-	defer s.blockMu.RUnlock()
 	for i := range results {
-		_, ok := s.blockedHosts[strings.ToLower(results[i].Domain)]
-		results[i].IsBlocked = ok
+		results[i].IsBlocked = s.IsBlocked(results[i].Domain)
 	}
 	return results
 }
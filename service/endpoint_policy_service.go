@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"random-api-go/database"
+	"random-api-go/model"
+)
+
+// EndpointPolicyService 端点访问策略服务
+// 策略按端点路径缓存在内存中, 随机请求的中间件直接查内存; 策略或端点变更后整体重新加载
+type EndpointPolicyService struct {
+	mu        sync.RWMutex
+	byPath    map[string]*model.EndpointPolicy // key: 端点 URL (不含前导 /)
+	endpoints map[string]bool                  // 全部端点 URL (不含前导 /), 包括没有策略的端点
+}
+
+var (
+	endpointPolicyService *EndpointPolicyService
+	endpointPolicyOnce    sync.Once
+)
+
+// GetEndpointPolicyService 获取端点访问策略服务实例
+func GetEndpointPolicyService() *EndpointPolicyService {
+	endpointPolicyOnce.Do(func() {
+		endpointPolicyService = &EndpointPolicyService{
+			byPath: make(map[string]*model.EndpointPolicy),
+		}
+		if err := endpointPolicyService.Reload(); err != nil {
+			log.Printf("Failed to load endpoint policies: %v", err)
+		}
+	})
+	return endpointPolicyService
+}
+
+// DefaultEndpointPolicy 端点未单独配置时的默认策略
+func DefaultEndpointPolicy(endpointID uint) model.EndpointPolicy {
+	return model.EndpointPolicy{
+		EndpointID:        endpointID,
+		AllowEmptyReferer: true,
+	}
+}
+
+// Reload 从数据库重新加载全部策略, 并按端点路径建立索引
+func (s *EndpointPolicyService) Reload() error {
+	var policies []model.EndpointPolicy
+	if err := database.DB.Find(&policies).Error; err != nil {
+		return err
+	}
+
+	var endpoints []model.APIEndpoint
+	if err := database.DB.Select("id", "url").Find(&endpoints).Error; err != nil {
+		return err
+	}
+	urlByID := make(map[uint]string, len(endpoints))
+	known := make(map[string]bool, len(endpoints))
+	for _, e := range endpoints {
+		urlByID[e.ID] = e.URL
+		known[e.URL] = true
+	}
+
+	next := make(map[string]*model.EndpointPolicy, len(policies))
+	for i := range policies {
+		if url, ok := urlByID[policies[i].EndpointID]; ok {
+			next[url] = &policies[i]
+		}
+	}
+
+	s.mu.Lock()
+	s.byPath = next
+	s.endpoints = known
+	s.mu.Unlock()
+	return nil
+}
+
+// GetPolicy 获取端点策略 (未配置时返回默认策略)
+func (s *EndpointPolicyService) GetPolicy(endpointID uint) (*model.EndpointPolicy, error) {
+	var policy model.EndpointPolicy
+	result := database.DB.Where("endpoint_id = ?", endpointID).Limit(1).Find(&policy)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		policy = DefaultEndpointPolicy(endpointID)
+	}
+	return &policy, nil
+}
+
+// SavePolicy 保存端点策略 (不存在则创建), 同步更新内存缓存
+func (s *EndpointPolicyService) SavePolicy(policy *model.EndpointPolicy) error {
+	policy.RefererAllowlist = normalizePatterns(policy.RefererAllowlist)
+	if err := normalizeBotPolicy(policy); err != nil {
+		return err
+	}
+	if err := normalizeCORSPolicy(policy); err != nil {
+		return err
+	}
+	if policy.RefererAllowlistEnabled && len(policy.RefererAllowlist) == 0 && !policy.AllowEmptyReferer {
+		return fmt.Errorf("referer allowlist is empty and empty referers are rejected, every request would be denied")
+	}
+
+	var existing model.EndpointPolicy
+	result := database.DB.Where("endpoint_id = ?", policy.EndpointID).Limit(1).Find(&existing)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		policy.ID = existing.ID
+		policy.CreatedAt = existing.CreatedAt
+	} else {
+		policy.ID = 0
+	}
+
+	// Save 会写入全部字段 (包括 false), 不受 gorm 零值跳过影响
+	if err := database.DB.Save(policy).Error; err != nil {
+		return err
+	}
+	return s.Reload()
+}
+
+// DeletePolicy 删除端点策略 (端点删除时调用, 事务提交后重新加载)
+func (s *EndpointPolicyService) DeletePolicy(ctx context.Context, endpointID uint) error {
+	if err := database.Conn(ctx).Where("endpoint_id = ?", endpointID).Delete(&model.EndpointPolicy{}).Error; err != nil {
+		return err
+	}
+	database.AfterCommit(ctx, func() {
+		if err := s.Reload(); err != nil {
+			log.Printf("重新加载端点访问策略失败: %v", err)
+		}
+	})
+	return nil
+}
+
+// lookup 按请求路径查找策略 (路径带不带前导 / 均可)
+func (s *EndpointPolicyService) lookup(path string) *model.EndpointPolicy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.byPath[strings.TrimPrefix(path, "/")]
+}
+
+// IsEndpoint 判断路径是否对应已配置的端点
+func (s *EndpointPolicyService) IsEndpoint(path string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.endpoints[strings.TrimPrefix(path, "/")]
+}
+
+// RequiresAPIKey 判断端点是否要求携带 API Key
+func (s *EndpointPolicyService) RequiresAPIKey(path string) bool {
+	policy := s.lookup(path)
+	return policy != nil && policy.RequireAPIKey
+}
+
+// RequiresSignature 判断端点是否要求签名链接
+func (s *EndpointPolicyService) RequiresSignature(path string) bool {
+	policy := s.lookup(path)
+	return policy != nil && policy.RequireSignature
+}
+
+// CheckReferer 校验请求的 Referer 是否在端点白名单内
+// 返回是否放行, 以及不放行时的回退地址 (空表示返回 403)
+// host 为本站主机名, 同站 Referer 始终放行 (首页预览等)
+func (s *EndpointPolicyService) CheckReferer(path, referer, host string) (bool, string) {
+	policy := s.lookup(path)
+	if policy == nil || !policy.RefererAllowlistEnabled {
+		return true, ""
+	}
+
+	domain := GetDomainStatsService().ExtractDomain(referer)
+	switch {
+	case domain == "direct":
+		if policy.AllowEmptyReferer {
+			return true, ""
+		}
+	case domain == "unknown":
+		// Referer 无法解析, 按不在白名单处理
+	case strings.EqualFold(domain, stripPort(host)):
+		return true, ""
+	case matchAnyHostPattern(policy.RefererAllowlist, domain):
+		return true, ""
+	}
+	return false, policy.RefererFallbackURL
+}
+
+// normalizePatterns 去除空白与空项, 统一小写
+func normalizePatterns(patterns []string) []string {
+	result := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p != "" {
+			result = append(result, p)
+		}
+	}
+	return result
+}
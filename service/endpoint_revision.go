@@ -0,0 +1,318 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"random-api-go/database"
+	"random-api-go/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrRevisionNotFound 端点没有指定的版本
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// RevisionDiff 两个版本之间的差异 (敏感字段脱敏方式与审计记录相同)
+type RevisionDiff struct {
+	From            int                          `json:"from"`
+	To              int                          `json:"to"`
+	Endpoint        map[string]model.AuditChange `json:"endpoint"`
+	DataSources     []RevisionItemDiff           `json:"data_sources"`
+	URLReplaceRules []RevisionItemDiff           `json:"url_replace_rules"`
+}
+
+// RevisionItemDiff 数据源或 URL 替换规则的差异
+type RevisionItemDiff struct {
+	ID      uint                         `json:"id"`
+	Name    string                       `json:"name"`
+	Status  string                       `json:"status"` // added / removed / changed
+	Changes map[string]model.AuditChange `json:"changes"`
+}
+
+// recordRevision 保存端点当前配置的快照; 与最新版本内容相同时不重复保存
+// 保存失败只记录日志, 不影响修改本身
+func (s *EndpointService) recordRevision(ctx context.Context, endpointID uint, action string) {
+	if endpointID == 0 {
+		return
+	}
+	endpoint, err := s.getEndpoint(ctx, endpointID)
+	if err != nil {
+		return
+	}
+
+	db := database.Conn(ctx)
+	var latest model.EndpointRevision
+	db.Where("endpoint_id = ?", endpointID).Order("revision DESC").Limit(1).Find(&latest)
+	if latest.ID != 0 && latest.Snapshot != nil && revisionContentEqual(latest.Snapshot, endpoint) {
+		return
+	}
+
+	revision := model.EndpointRevision{
+		EndpointID: endpointID,
+		Revision:   latest.Revision + 1,
+		Action:     action,
+		Actor:      AuditActorFromContext(ctx).Name,
+		Snapshot:   endpoint,
+	}
+	if err := db.Create(&revision).Error; err != nil {
+		log.Printf("保存端点 %d 的配置版本失败: %v", endpointID, err)
+		return
+	}
+
+	// 只保留最近的 endpoint_revision_limit 个版本 (默认 50); 读取配置会访问数据库, 放到事务提交后
+	database.AfterCommit(ctx, func() {
+		if limit := getIntConfig("endpoint_revision_limit", 50); limit > 0 && revision.Revision > limit {
+			database.DB.Where("endpoint_id = ? AND revision <= ?", endpointID, revision.Revision-limit).Delete(&model.EndpointRevision{})
+		}
+	})
+}
+
+// recordRuleRevision URL 替换规则属于端点时保存该端点的版本 (全局规则不产生版本)
+func (s *EndpointService) recordRuleRevision(ctx context.Context, endpointID *uint, action string) {
+	if endpointID != nil {
+		s.recordRevision(ctx, *endpointID, action)
+	}
+}
+
+// ListRevisions 列出端点的历史版本 (新的在前, 不含快照内容)
+func (s *EndpointService) ListRevisions(endpointID uint) ([]model.EndpointRevision, error) {
+	var revisions []model.EndpointRevision
+	if err := database.DB.Omit("snapshot").Where("endpoint_id = ?", endpointID).
+		Order("revision DESC").Find(&revisions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list revisions: %w", err)
+	}
+	return revisions, nil
+}
+
+// GetRevision 获取端点的指定版本 (含快照)
+func (s *EndpointService) GetRevision(endpointID uint, revision int) (*model.EndpointRevision, error) {
+	var rev model.EndpointRevision
+	if err := database.DB.Where("endpoint_id = ? AND revision = ?", endpointID, revision).Limit(1).Find(&rev).Error; err != nil {
+		return nil, fmt.Errorf("failed to get revision: %w", err)
+	}
+	if rev.ID == 0 || rev.Snapshot == nil {
+		return nil, ErrRevisionNotFound
+	}
+	return &rev, nil
+}
+
+// DiffRevisions 比较端点的两个版本, to 为 0 时与最新版本比较
+func (s *EndpointService) DiffRevisions(endpointID uint, from, to int) (*RevisionDiff, error) {
+	if to == 0 {
+		var latest model.EndpointRevision
+		if err := database.DB.Omit("snapshot").Where("endpoint_id = ?", endpointID).
+			Order("revision DESC").Limit(1).Find(&latest).Error; err != nil {
+			return nil, fmt.Errorf("failed to get latest revision: %w", err)
+		}
+		to = latest.Revision
+	}
+	fromRev, err := s.GetRevision(endpointID, from)
+	if err != nil {
+		return nil, err
+	}
+	toRev, err := s.GetRevision(endpointID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	before, after := *fromRev.Snapshot, *toRev.Snapshot
+	beforeSources, afterSources := before.DataSources, after.DataSources
+	beforeRules, afterRules := before.URLReplaceRules, after.URLReplaceRules
+	before.DataSources, before.URLReplaceRules = nil, nil
+	after.DataSources, after.URLReplaceRules = nil, nil
+
+	diff := &RevisionDiff{
+		From:     from,
+		To:       to,
+		Endpoint: auditDiff(auditSnapshot(&before), auditSnapshot(&after)),
+	}
+
+	oldSources := make(map[uint]interface{})
+	newSources := make(map[uint]interface{})
+	names := make(map[uint]string)
+	for i := range beforeSources {
+		oldSources[beforeSources[i].ID] = &beforeSources[i]
+		names[beforeSources[i].ID] = beforeSources[i].Name
+	}
+	for i := range afterSources {
+		newSources[afterSources[i].ID] = &afterSources[i]
+		names[afterSources[i].ID] = afterSources[i].Name
+	}
+	diff.DataSources = diffRevisionItems(oldSources, newSources, names)
+
+	oldRules := make(map[uint]interface{})
+	newRules := make(map[uint]interface{})
+	names = make(map[uint]string)
+	for i := range beforeRules {
+		oldRules[beforeRules[i].ID] = &beforeRules[i]
+		names[beforeRules[i].ID] = beforeRules[i].Name
+	}
+	for i := range afterRules {
+		newRules[afterRules[i].ID] = &afterRules[i]
+		names[afterRules[i].ID] = afterRules[i].Name
+	}
+	diff.URLReplaceRules = diffRevisionItems(oldRules, newRules, names)
+
+	return diff, nil
+}
+
+// diffRevisionItems 按 ID 比较两个版本中的子项, 结果按 ID 排序
+func diffRevisionItems(before, after map[uint]interface{}, names map[uint]string) []RevisionItemDiff {
+	ids := make([]uint, 0, len(names))
+	for id := range names {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	items := make([]RevisionItemDiff, 0)
+	for _, id := range ids {
+		oldItem, hadOld := before[id]
+		newItem, hasNew := after[id]
+		item := RevisionItemDiff{ID: id, Name: names[id]}
+		switch {
+		case !hadOld:
+			item.Status = "added"
+			item.Changes = auditDiff(nil, auditSnapshot(newItem))
+		case !hasNew:
+			item.Status = "removed"
+			item.Changes = auditDiff(auditSnapshot(oldItem), nil)
+		default:
+			item.Status = "changed"
+			item.Changes = auditDiff(auditSnapshot(oldItem), auditSnapshot(newItem))
+			if len(item.Changes) == 0 {
+				continue
+			}
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// RestoreRevision 将端点恢复到指定版本: 在一个事务中恢复端点字段、数据源与端点专属的 URL 替换规则
+// 版本中没有的数据源与规则会被删除, 已删除的会按原 ID 恢复; 恢复本身也会产生一个新版本
+func (s *EndpointService) RestoreRevision(ctx context.Context, endpointID uint, revision int) (*model.APIEndpoint, error) {
+	rev, err := s.GetRevision(endpointID, revision)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.GetEndpoint(endpointID)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := rev.Snapshot
+	now := time.Now()
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.APIEndpoint{}).Where("id = ?", endpointID).Updates(map[string]interface{}{
+			"name":             snapshot.Name,
+			"url":              snapshot.URL,
+			"description":      snapshot.Description,
+			"is_active":        snapshot.IsActive,
+			"show_on_homepage": snapshot.ShowOnHomepage,
+			"sort_order":       snapshot.SortOrder,
+			"request_timeout":  snapshot.RequestTimeout,
+			"updated_at":       now,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to restore endpoint: %w", err)
+		}
+
+		// 数据源
+		keep := make([]uint, 0, len(snapshot.DataSources))
+		for _, dataSource := range snapshot.DataSources {
+			keep = append(keep, dataSource.ID)
+		}
+		query := tx.Where("endpoint_id = ?", endpointID)
+		if len(keep) > 0 {
+			query = query.Where("id NOT IN ?", keep)
+		}
+		if err := query.Delete(&model.DataSource{}).Error; err != nil {
+			return fmt.Errorf("failed to remove data sources: %w", err)
+		}
+		for _, dataSource := range snapshot.DataSources {
+			dataSource.EndpointID = endpointID
+			dataSource.UpdatedAt = now
+			dataSource.DeletedAt = gorm.DeletedAt{}
+			if err := tx.Unscoped().Omit("Endpoint").Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"endpoint_id", "name", "type", "config", "is_active", "updated_at", "deleted_at"}),
+			}).Create(&dataSource).Error; err != nil {
+				return fmt.Errorf("failed to restore data source %d: %w", dataSource.ID, err)
+			}
+		}
+
+		// 端点专属的 URL 替换规则 (全局规则不属于端点版本)
+		keep = keep[:0]
+		for _, rule := range snapshot.URLReplaceRules {
+			keep = append(keep, rule.ID)
+		}
+		query = tx.Where("endpoint_id = ?", endpointID)
+		if len(keep) > 0 {
+			query = query.Where("id NOT IN ?", keep)
+		}
+		if err := query.Delete(&model.URLReplaceRule{}).Error; err != nil {
+			return fmt.Errorf("failed to remove URL replace rules: %w", err)
+		}
+		for _, rule := range snapshot.URLReplaceRules {
+			id := endpointID
+			rule.EndpointID = &id
+			rule.Endpoint = nil
+			rule.UpdatedAt = now
+			rule.DeletedAt = gorm.DeletedAt{}
+			if err := tx.Unscoped().Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"endpoint_id", "name", "mode", "from_url", "to_url", "priority", "stop_on_match", "is_active", "updated_at", "deleted_at"}),
+			}).Create(&rule).Error; err != nil {
+				return fmt.Errorf("failed to restore URL replace rule %d: %w", rule.ID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	restored, err := s.GetEndpoint(endpointID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 与 UpdateEndpoint 相同: 清理缓存、重建策略索引并预加载数据源
+	s.cacheManager.InvalidateMemoryCache(current.URL)
+	s.cacheManager.InvalidateMemoryCache(restored.URL)
+	s.urlRuleCache.Invalidate()
+	s.reloadEndpointPolicies()
+	s.preloader.PreloadEndpointOnSave(restored)
+
+	RecordAudit(ctx, "restore", "endpoint", endpointID, nil, map[string]interface{}{"revision": revision})
+	s.recordRevision(ctx, endpointID, fmt.Sprintf("restore:%d", revision))
+	return restored, nil
+}
+
+// revisionContentEqual 比较两个快照的配置内容 (忽略时间戳)
+func revisionContentEqual(a, b *model.APIEndpoint) bool {
+	return string(revisionContent(a)) == string(revisionContent(b))
+}
+
+// revisionContent 去掉时间戳后的快照内容
+func revisionContent(endpoint *model.APIEndpoint) []byte {
+	copied := *endpoint
+	copied.CreatedAt, copied.UpdatedAt = time.Time{}, time.Time{}
+	copied.DataSources = append([]model.DataSource(nil), endpoint.DataSources...)
+	for i := range copied.DataSources {
+		copied.DataSources[i].CreatedAt, copied.DataSources[i].UpdatedAt = time.Time{}, time.Time{}
+		copied.DataSources[i].LastSync = nil
+	}
+	copied.URLReplaceRules = append([]model.URLReplaceRule(nil), endpoint.URLReplaceRules...)
+	for i := range copied.URLReplaceRules {
+		copied.URLReplaceRules[i].CreatedAt, copied.URLReplaceRules[i].UpdatedAt = time.Time{}, time.Time{}
+	}
+	data, _ := json.Marshal(copied)
+	return data
+}
@@ -1,7 +1,9 @@
 package service
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"math/rand"
 	"random-api-go/database"
 	"random-api-go/model"
@@ -16,11 +18,23 @@ type EndpointService struct {
 	cacheManager      *CacheManager
 	dataSourceFetcher *DataSourceFetcher
 	preloader         *Preloader
+	urlRewriter       *URLRewriter
+	urlRuleCache      *URLRuleCache
+	urlSigner         *URLSignerService
+
+	// cachesLoadedAt 上次从数据库加载内存缓存的时间, 只由 SIGHUP 处理协程更新
+	cachesLoadedAt time.Time
 }
 
 var endpointService *EndpointService
 var once sync.Once
 
+// preloaderAutoStart 首次创建端点服务时是否启动预加载器
+var preloaderAutoStart = true
+
+// defaultRequestTimeout 端点未单独配置且全局配置缺失时的请求超时
+const defaultRequestTimeout = 10 * time.Second
+
 // 支持的数据源类型列表
 var supportedDataSourceTypes = []string{
 	"lankong",
@@ -53,33 +67,54 @@ func GetEndpointService() *EndpointService {
 			cacheManager:      cacheManager,
 			dataSourceFetcher: dataSourceFetcher,
 			preloader:         preloader,
+			urlRewriter:       NewURLRewriter(),
+			urlRuleCache:      NewURLRuleCache(),
+			urlSigner:         NewURLSignerService(),
+			cachesLoadedAt:    time.Now(),
 		}
 
 		// 启动预加载器
-		preloader.Start()
+		if preloaderAutoStart {
+			preloader.Start()
+		}
 	})
 	return endpointService
 }
 
+// DisablePreloaderAutoStart 不启动预加载器的定时刷新, 命令行子命令需在首次调用 GetEndpointService 前调用
+func DisablePreloaderAutoStart() {
+	preloaderAutoStart = false
+}
+
 // CreateEndpoint 创建API端点
-func (s *EndpointService) CreateEndpoint(endpoint *model.APIEndpoint) error {
-	if err := database.DB.Create(endpoint).Error; err != nil {
+func (s *EndpointService) CreateEndpoint(ctx context.Context, endpoint *model.APIEndpoint) error {
+	if err := database.Conn(ctx).Create(endpoint).Error; err != nil {
 		return fmt.Errorf("failed to create endpoint: %w", err)
 	}
+	RecordAudit(ctx, AuditActionCreate, "endpoint", endpoint.ID, nil, endpoint)
+	s.recordRevision(ctx, endpoint.ID, AuditActionCreate)
 
-	// 清理缓存
-	s.cacheManager.InvalidateMemoryCache(endpoint.URL)
+	database.AfterCommit(ctx, func() {
+		// 清理缓存
+		s.cacheManager.InvalidateMemoryCache(endpoint.URL)
+		s.reloadEndpointPolicies()
 
-	// 预加载数据源
-	s.preloader.PreloadEndpointOnSave(endpoint)
+		// 预加载数据源
+		s.preloader.PreloadEndpointOnSave(endpoint)
+	})
 
 	return nil
 }
 
 // GetEndpoint 获取API端点
 func (s *EndpointService) GetEndpoint(id uint) (*model.APIEndpoint, error) {
+	return s.getEndpoint(context.Background(), id)
+}
+
+// getEndpoint 获取API端点 (在 ctx 的事务中读取)
+func (s *EndpointService) getEndpoint(ctx context.Context, id uint) (*model.APIEndpoint, error) {
 	var endpoint model.APIEndpoint
-	if err := database.DB.Preload("DataSources").Preload("URLReplaceRules").First(&endpoint, id).Error; err != nil {
+	if err := database.Conn(ctx).Preload("DataSources").Preload("URLReplaceRules").First(&endpoint, id).Error; err != nil {
 		return nil, fmt.Errorf("failed to get endpoint: %w", err)
 	}
 	return &endpoint, nil
@@ -106,7 +141,13 @@ func (s *EndpointService) ListEndpoints() ([]*model.APIEndpoint, error) {
 }
 
 // UpdateEndpoint 更新API端点
-func (s *EndpointService) UpdateEndpoint(endpoint *model.APIEndpoint) error {
+func (s *EndpointService) UpdateEndpoint(ctx context.Context, endpoint *model.APIEndpoint) error {
+	db := database.Conn(ctx)
+	var before model.APIEndpoint
+	if err := db.First(&before, endpoint.ID).Error; err != nil {
+		return fmt.Errorf("failed to get endpoint: %w", err)
+	}
+
 	// 只更新指定字段，避免覆盖 created_at 和 sort_order 等字段
 	updates := map[string]interface{}{
 		"name":             endpoint.Name,
@@ -122,46 +163,168 @@ func (s *EndpointService) UpdateEndpoint(endpoint *model.APIEndpoint) error {
 		updates["sort_order"] = endpoint.SortOrder
 	}
 
-	if err := database.DB.Model(&model.APIEndpoint{}).Where("id = ?", endpoint.ID).Updates(updates).Error; err != nil {
+	// request_timeout 同理，0 表示沿用原值；负数表示恢复为全局默认
+	if endpoint.RequestTimeout > 0 {
+		updates["request_timeout"] = endpoint.RequestTimeout
+	} else if endpoint.RequestTimeout < 0 {
+		updates["request_timeout"] = 0
+	}
+
+	if err := db.Model(&model.APIEndpoint{}).Where("id = ?", endpoint.ID).Updates(updates).Error; err != nil {
 		return fmt.Errorf("failed to update endpoint: %w", err)
 	}
+	var after model.APIEndpoint
+	if err := db.First(&after, endpoint.ID).Error; err == nil {
+		RecordAudit(ctx, AuditActionUpdate, "endpoint", endpoint.ID, &before, &after)
+	}
+	s.recordRevision(ctx, endpoint.ID, AuditActionUpdate)
 
-	// 清理缓存
-	s.cacheManager.InvalidateMemoryCache(endpoint.URL)
+	database.AfterCommit(ctx, func() {
+		// 清理缓存
+		s.cacheManager.InvalidateMemoryCache(endpoint.URL)
+		s.reloadEndpointPolicies()
 
-	// 预加载数据源
-	s.preloader.PreloadEndpointOnSave(endpoint)
+		// 预加载数据源
+		s.preloader.PreloadEndpointOnSave(endpoint)
+	})
 
 	return nil
 }
 
 // DeleteEndpoint 删除API端点
-func (s *EndpointService) DeleteEndpoint(id uint) error {
+func (s *EndpointService) DeleteEndpoint(ctx context.Context, id uint) error {
 	// 先获取URL用于清理缓存
-	endpoint, err := s.GetEndpoint(id)
+	endpoint, err := s.getEndpoint(ctx, id)
 	if err != nil {
 		return fmt.Errorf("failed to get endpoint for deletion: %w", err)
 	}
+	db := database.Conn(ctx)
 
 	// 删除相关的数据源和URL替换规则
-	if err := database.DB.Select("DataSources", "URLReplaceRules").Delete(&model.APIEndpoint{}, id).Error; err != nil {
+	if err := db.Select("DataSources", "URLReplaceRules").Delete(&model.APIEndpoint{}, id).Error; err != nil {
 		return fmt.Errorf("failed to delete endpoint: %w", err)
 	}
 
-	// 清理缓存
-	s.cacheManager.InvalidateMemoryCache(endpoint.URL)
+	// 删除端点专属的签名配置
+	if err := db.Where("endpoint_id = ?", id).Delete(&model.URLSigner{}).Error; err != nil {
+		return fmt.Errorf("failed to delete endpoint URL signers: %w", err)
+	}
+
+	// 删除端点访问策略
+	if err := GetEndpointPolicyService().DeletePolicy(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete endpoint policy: %w", err)
+	}
+
+	// 删除端点专属的 IP 规则
+	if err := GetIPRuleService().DeleteEndpointRules(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete endpoint IP rules: %w", err)
+	}
+
+	// 删除端点专属的限流策略
+	if err := GetRateLimitService().DeleteEndpointPolicies(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete endpoint rate limit policies: %w", err)
+	}
+
+	RecordAudit(ctx, AuditActionDelete, "endpoint", id, endpoint, nil)
+
+	database.AfterCommit(ctx, func() {
+		// 清理缓存
+		s.cacheManager.InvalidateMemoryCache(endpoint.URL)
+		s.urlRuleCache.Invalidate()
+		s.urlSigner.Invalidate()
+	})
 
 	return nil
 }
 
+// reloadEndpointPolicies 端点 URL 变化后重建策略、IP 规则、限流策略与 API Key 的路径索引
+func (s *EndpointService) reloadEndpointPolicies() {
+	if err := GetEndpointPolicyService().Reload(); err != nil {
+		log.Printf("重新加载端点访问策略失败: %v", err)
+	}
+	if err := GetIPRuleService().Reload(); err != nil {
+		log.Printf("重新加载 IP 规则失败: %v", err)
+	}
+	if err := GetRateLimitService().Reload(); err != nil {
+		log.Printf("重新加载限流策略失败: %v", err)
+	}
+	if err := GetAPIKeyService().Reload(); err != nil {
+		log.Printf("重新加载 API Key 失败: %v", err)
+	}
+}
+
+// ReloadCaches 从数据库重新加载内存缓存, 命令行等其他进程修改数据库后由 SIGHUP 触发
+// 上次加载后有变动的数据源在后台重新拉取并覆盖缓存, 拉取完成前继续使用旧数据
+func (s *EndpointService) ReloadCaches() {
+	loadedAt := s.cachesLoadedAt
+	s.cachesLoadedAt = time.Now()
+
+	database.ClearConfigCache()
+	s.urlRuleCache.Invalidate()
+	s.urlSigner.Invalidate()
+	s.reloadEndpointPolicies()
+	if err := GetDomainStatsService().reloadBlockedDomains(); err != nil {
+		log.Printf("重新加载域名黑名单失败: %v", err)
+	}
+	if err := GetAdminUserService().Reload(); err != nil {
+		log.Printf("重新加载管理员名单失败: %v", err)
+	}
+
+	var dataSources []model.DataSource
+	if err := database.DB.Find(&dataSources).Error; err != nil {
+		log.Printf("重新加载数据源失败: %v", err)
+		return
+	}
+	for _, dataSource := range dataSources {
+		if !dataSource.IsActive {
+			s.cacheManager.InvalidateMemoryCacheForDataSource(dataSource.ID)
+			continue
+		}
+		changed := dataSource.UpdatedAt.After(loadedAt) ||
+			(dataSource.LastSync != nil && dataSource.LastSync.After(loadedAt))
+		if !changed || dataSource.Type == "api_get" || dataSource.Type == "api_post" || dataSource.Type == "endpoint" {
+			continue
+		}
+		go func(ds model.DataSource) {
+			if err := s.dataSourceFetcher.RefreshDataSource(context.Background(), &ds); err != nil {
+				log.Printf("重新加载数据源 %d 失败: %v", ds.ID, err)
+			}
+		}(dataSource)
+	}
+}
+
 // GetRandomURL 获取随机URL
-func (s *EndpointService) GetRandomURL(url string) (string, error) {
+// ctx 取消（客户端断开）或超过端点超时时间后，所有上游请求随之取消
+func (s *EndpointService) GetRandomURL(ctx context.Context, url string) (string, error) {
 	// 获取端点信息
 	endpoint, err := s.GetEndpointByURL(url)
 	if err != nil {
 		return "", fmt.Errorf("endpoint not found: %w", err)
 	}
 
+	randomURL, err := s.resolveRandomURL(ctx, endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	// CDN签名只在最外层执行一次，嵌套端点返回的都是未签名URL
+	return s.urlSigner.Sign(randomURL, endpoint.ID), nil
+}
+
+// getRandomURL 获取未签名的随机URL（用于 endpoint:// 嵌套调用）
+func (s *EndpointService) getRandomURL(ctx context.Context, url string) (string, error) {
+	endpoint, err := s.GetEndpointByURL(url)
+	if err != nil {
+		return "", fmt.Errorf("endpoint not found: %w", err)
+	}
+	return s.resolveRandomURL(ctx, endpoint)
+}
+
+// resolveRandomURL 在端点超时时间内选取随机URL并应用替换规则
+func (s *EndpointService) resolveRandomURL(ctx context.Context, endpoint *model.APIEndpoint) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.requestTimeout(endpoint))
+	defer cancel()
+
 	// 检查是否包含API类型或端点类型的数据源
 	hasRealtimeDataSource := false
 	for _, dataSource := range endpoint.DataSources {
@@ -173,15 +336,26 @@ func (s *EndpointService) GetRandomURL(url string) (string, error) {
 
 	// 如果包含实时数据源，不使用内存缓存，直接实时获取
 	if hasRealtimeDataSource {
-		return s.getRandomURLRealtime(endpoint)
+		return s.getRandomURLRealtime(ctx, endpoint)
 	}
 
 	// 非实时数据源，使用缓存模式但也先选择数据源
-	return s.getRandomURLWithCache(endpoint)
+	return s.getRandomURLWithCache(ctx, endpoint)
+}
+
+// requestTimeout 获取端点的请求超时：端点配置优先，其次全局配置 api_request_timeout（秒）
+func (s *EndpointService) requestTimeout(endpoint *model.APIEndpoint) time.Duration {
+	if endpoint.RequestTimeout > 0 {
+		return time.Duration(endpoint.RequestTimeout) * time.Second
+	}
+	if seconds := getIntConfig("api_request_timeout", 0); seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultRequestTimeout
 }
 
 // getRandomURLRealtime 实时获取随机URL（用于包含API数据源的端点）
-func (s *EndpointService) getRandomURLRealtime(endpoint *model.APIEndpoint) (string, error) {
+func (s *EndpointService) getRandomURLRealtime(ctx context.Context, endpoint *model.APIEndpoint) (string, error) {
 	// 收集所有激活的数据源
 	var activeDataSources []model.DataSource
 	for _, dataSource := range endpoint.DataSources {
@@ -198,7 +372,7 @@ func (s *EndpointService) getRandomURLRealtime(endpoint *model.APIEndpoint) (str
 	selectedDataSource := activeDataSources[rand.Intn(len(activeDataSources))]
 
 	// 只从选中的数据源获取URL
-	urls, err := s.dataSourceFetcher.FetchURLs(&selectedDataSource)
+	urls, err := s.dataSourceFetcher.FetchURLs(ctx, &selectedDataSource)
 	if err != nil {
 		return "", fmt.Errorf("failed to get URLs from selected data source %d: %w", selectedDataSource.ID, err)
 	}
@@ -225,20 +399,20 @@ func (s *EndpointService) getRandomURLRealtime(endpoint *model.APIEndpoint) (str
 		}
 
 		// 递归调用获取目标端点的随机URL
-		targetURL, err := s.GetRandomURL(targetEndpoint.URL)
+		targetURL, err := s.getRandomURL(ctx, targetEndpoint.URL)
 		if err != nil {
 			return "", err
 		}
 
-		// 对从目标端点获取的URL应用当前端点的替换规则
-		return s.applyURLReplaceRules(targetURL, endpoint.URL), nil
+		// 对从目标端点获取的URL应用当前端点的替换规则（全局规则已在目标端点执行过，不再重复）
+		return s.urlRewriter.Rewrite(targetURL, s.urlRuleCache.EndpointRules(endpoint.ID)), nil
 	}
 
-	return s.applyURLReplaceRules(randomURL, endpoint.URL), nil
+	return s.applyURLReplaceRules(randomURL, endpoint.ID), nil
 }
 
 // getRandomURLWithCache 使用缓存模式获取随机URL（先选择数据源）
-func (s *EndpointService) getRandomURLWithCache(endpoint *model.APIEndpoint) (string, error) {
+func (s *EndpointService) getRandomURLWithCache(ctx context.Context, endpoint *model.APIEndpoint) (string, error) {
 	// 收集所有激活的数据源
 	var activeDataSources []model.DataSource
 	for _, dataSource := range endpoint.DataSources {
@@ -255,7 +429,7 @@ func (s *EndpointService) getRandomURLWithCache(endpoint *model.APIEndpoint) (st
 	selectedDataSource := activeDataSources[rand.Intn(len(activeDataSources))]
 
 	// 从选中的数据源获取URL（会使用缓存）
-	urls, err := s.dataSourceFetcher.FetchURLs(&selectedDataSource)
+	urls, err := s.dataSourceFetcher.FetchURLs(ctx, &selectedDataSource)
 	if err != nil {
 		return "", fmt.Errorf("failed to get URLs from selected data source %d: %w", selectedDataSource.ID, err)
 	}
@@ -282,96 +456,233 @@ func (s *EndpointService) getRandomURLWithCache(endpoint *model.APIEndpoint) (st
 		}
 
 		// 递归调用获取目标端点的随机URL
-		targetURL, err := s.GetRandomURL(targetEndpoint.URL)
+		targetURL, err := s.getRandomURL(ctx, targetEndpoint.URL)
 		if err != nil {
 			return "", err
 		}
 
-		// 对从目标端点获取的URL应用当前端点的替换规则
-		return s.applyURLReplaceRules(targetURL, endpoint.URL), nil
+		// 对从目标端点获取的URL应用当前端点的替换规则（全局规则已在目标端点执行过，不再重复）
+		return s.urlRewriter.Rewrite(targetURL, s.urlRuleCache.EndpointRules(endpoint.ID)), nil
 	}
 
-	return s.applyURLReplaceRules(randomURL, endpoint.URL), nil
+	return s.applyURLReplaceRules(randomURL, endpoint.ID), nil
 }
 
-// applyURLReplaceRules 应用URL替换规则
-func (s *EndpointService) applyURLReplaceRules(url, endpointURL string) string {
-	// 获取端点的替换规则
-	endpoint, err := s.GetEndpointByURL(endpointURL)
-	if err != nil {
-		return url
-	}
+// applyURLReplaceRules 应用URL替换规则（全局规则 + 端点规则，规则来自内存缓存）
+func (s *EndpointService) applyURLReplaceRules(url string, endpointID uint) string {
+	return s.urlRewriter.Rewrite(url, s.urlRuleCache.Rules(endpointID))
+}
 
-	result := url
-	for _, rule := range endpoint.URLReplaceRules {
-		if rule.IsActive {
-			result = strings.ReplaceAll(result, rule.FromURL, rule.ToURL)
+// TraceURLReplaceRules 对示例URL执行替换链，返回每条规则的执行过程
+// endpointID 为 0 时只执行全局规则
+func (s *EndpointService) TraceURLReplaceRules(url string, endpointID uint) (string, []URLRewriteStep, error) {
+	if endpointID != 0 {
+		if _, err := s.GetEndpoint(endpointID); err != nil {
+			return "", nil, err
 		}
 	}
 
-	return result
+	result, steps := s.urlRewriter.Trace(url, s.urlRuleCache.Rules(endpointID))
+	return result, steps, nil
+}
+
+// CreateURLReplaceRule 创建URL替换规则
+func (s *EndpointService) CreateURLReplaceRule(ctx context.Context, rule *model.URLReplaceRule) error {
+	if err := ValidateURLReplaceRule(rule); err != nil {
+		return err
+	}
+
+	if err := database.Conn(ctx).Create(rule).Error; err != nil {
+		return fmt.Errorf("failed to create URL replace rule: %w", err)
+	}
+	RecordAudit(ctx, AuditActionCreate, "url_replace_rule", rule.ID, nil, rule)
+	s.recordRuleRevision(ctx, rule.EndpointID, "url_replace_rule.create")
+
+	database.AfterCommit(ctx, s.urlRuleCache.Invalidate)
+	return nil
+}
+
+// UpdateURLReplaceRule 更新URL替换规则
+func (s *EndpointService) UpdateURLReplaceRule(ctx context.Context, rule *model.URLReplaceRule) error {
+	if err := ValidateURLReplaceRule(rule); err != nil {
+		return err
+	}
+
+	db := database.Conn(ctx)
+	var before model.URLReplaceRule
+	if err := db.First(&before, rule.ID).Error; err != nil {
+		return fmt.Errorf("failed to get URL replace rule: %w", err)
+	}
+
+	if err := db.Save(rule).Error; err != nil {
+		return fmt.Errorf("failed to update URL replace rule: %w", err)
+	}
+	RecordAudit(ctx, AuditActionUpdate, "url_replace_rule", rule.ID, &before, rule)
+	s.recordRuleRevision(ctx, before.EndpointID, "url_replace_rule.update")
+	if rule.EndpointID != nil && (before.EndpointID == nil || *before.EndpointID != *rule.EndpointID) {
+		s.recordRuleRevision(ctx, rule.EndpointID, "url_replace_rule.update")
+	}
+
+	database.AfterCommit(ctx, s.urlRuleCache.Invalidate)
+	return nil
+}
+
+// DeleteURLReplaceRule 删除URL替换规则
+func (s *EndpointService) DeleteURLReplaceRule(ctx context.Context, id uint) error {
+	db := database.Conn(ctx)
+	var before model.URLReplaceRule
+	if err := db.First(&before, id).Error; err != nil {
+		return fmt.Errorf("failed to get URL replace rule: %w", err)
+	}
+
+	if err := db.Delete(&before).Error; err != nil {
+		return fmt.Errorf("failed to delete URL replace rule: %w", err)
+	}
+	RecordAudit(ctx, AuditActionDelete, "url_replace_rule", id, &before, nil)
+	s.recordRuleRevision(ctx, before.EndpointID, "url_replace_rule.delete")
+
+	database.AfterCommit(ctx, s.urlRuleCache.Invalidate)
+	return nil
+}
+
+// ListURLSigners 列出URL签名配置
+func (s *EndpointService) ListURLSigners() ([]model.URLSigner, error) {
+	var signers []model.URLSigner
+	if err := database.DB.Preload("Endpoint").Order("id ASC").Find(&signers).Error; err != nil {
+		return nil, fmt.Errorf("failed to list URL signers: %w", err)
+	}
+	return signers, nil
+}
+
+// CreateURLSigner 创建URL签名配置
+func (s *EndpointService) CreateURLSigner(ctx context.Context, signer *model.URLSigner) error {
+	if err := ValidateURLSigner(signer); err != nil {
+		return err
+	}
+
+	if err := database.Conn(ctx).Create(signer).Error; err != nil {
+		return fmt.Errorf("failed to create URL signer: %w", err)
+	}
+	RecordAudit(ctx, AuditActionCreate, "url_signer", signer.ID, nil, signer)
+
+	database.AfterCommit(ctx, s.urlSigner.Invalidate)
+	return nil
+}
+
+// UpdateURLSigner 更新URL签名配置
+func (s *EndpointService) UpdateURLSigner(ctx context.Context, signer *model.URLSigner) error {
+	if err := ValidateURLSigner(signer); err != nil {
+		return err
+	}
+
+	db := database.Conn(ctx)
+	var before model.URLSigner
+	if err := db.First(&before, signer.ID).Error; err != nil {
+		return fmt.Errorf("failed to get URL signer: %w", err)
+	}
+
+	if err := db.Save(signer).Error; err != nil {
+		return fmt.Errorf("failed to update URL signer: %w", err)
+	}
+	RecordAudit(ctx, AuditActionUpdate, "url_signer", signer.ID, &before, signer)
+
+	database.AfterCommit(ctx, s.urlSigner.Invalidate)
+	return nil
+}
+
+// DeleteURLSigner 删除URL签名配置
+func (s *EndpointService) DeleteURLSigner(ctx context.Context, id uint) error {
+	db := database.Conn(ctx)
+	var before model.URLSigner
+	if err := db.First(&before, id).Error; err != nil {
+		return fmt.Errorf("failed to get URL signer: %w", err)
+	}
+
+	if err := db.Delete(&before).Error; err != nil {
+		return fmt.Errorf("failed to delete URL signer: %w", err)
+	}
+	RecordAudit(ctx, AuditActionDelete, "url_signer", id, &before, nil)
+
+	database.AfterCommit(ctx, s.urlSigner.Invalidate)
+	return nil
 }
 
 // CreateDataSource 创建数据源
-func (s *EndpointService) CreateDataSource(dataSource *model.DataSource) error {
+func (s *EndpointService) CreateDataSource(ctx context.Context, dataSource *model.DataSource) error {
 	// 验证数据源类型
 	if err := validateDataSourceType(dataSource.Type); err != nil {
 		return err
 	}
 
-	if err := database.DB.Create(dataSource).Error; err != nil {
+	if err := database.Conn(ctx).Create(dataSource).Error; err != nil {
 		return fmt.Errorf("failed to create data source: %w", err)
 	}
+	RecordAudit(ctx, AuditActionCreate, "data_source", dataSource.ID, nil, dataSource)
+	s.recordRevision(ctx, dataSource.EndpointID, "data_source.create")
 
 	// 获取关联的端点URL用于清理缓存
-	if endpoint, err := s.GetEndpoint(dataSource.EndpointID); err == nil {
-		s.cacheManager.InvalidateMemoryCache(endpoint.URL)
+	if endpoint, err := s.getEndpoint(ctx, dataSource.EndpointID); err == nil {
+		database.AfterCommit(ctx, func() { s.cacheManager.InvalidateMemoryCache(endpoint.URL) })
 	}
 
 	// 预加载数据源
-	s.preloader.PreloadDataSourceOnSave(dataSource)
+	database.AfterCommit(ctx, func() { s.preloader.PreloadDataSourceOnSave(dataSource) })
 
 	return nil
 }
 
 // UpdateDataSource 更新数据源
-func (s *EndpointService) UpdateDataSource(dataSource *model.DataSource) error {
+func (s *EndpointService) UpdateDataSource(ctx context.Context, dataSource *model.DataSource) error {
 	// 验证数据源类型
 	if err := validateDataSourceType(dataSource.Type); err != nil {
 		return err
 	}
 
-	if err := database.DB.Save(dataSource).Error; err != nil {
+	db := database.Conn(ctx)
+	var before model.DataSource
+	if err := db.First(&before, dataSource.ID).Error; err != nil {
+		return fmt.Errorf("failed to get data source: %w", err)
+	}
+
+	if err := db.Save(dataSource).Error; err != nil {
 		return fmt.Errorf("failed to update data source: %w", err)
 	}
+	RecordAudit(ctx, AuditActionUpdate, "data_source", dataSource.ID, &before, dataSource)
+	s.recordRevision(ctx, dataSource.EndpointID, "data_source.update")
+	if before.EndpointID != dataSource.EndpointID {
+		s.recordRevision(ctx, before.EndpointID, "data_source.update")
+	}
 
 	// 获取关联的端点URL用于清理缓存
-	if endpoint, err := s.GetEndpoint(dataSource.EndpointID); err == nil {
-		s.cacheManager.InvalidateMemoryCache(endpoint.URL)
+	if endpoint, err := s.getEndpoint(ctx, dataSource.EndpointID); err == nil {
+		database.AfterCommit(ctx, func() { s.cacheManager.InvalidateMemoryCache(endpoint.URL) })
 	}
 
 	// 预加载数据源
-	s.preloader.PreloadDataSourceOnSave(dataSource)
+	database.AfterCommit(ctx, func() { s.preloader.PreloadDataSourceOnSave(dataSource) })
 
 	return nil
 }
 
 // DeleteDataSource 删除数据源
-func (s *EndpointService) DeleteDataSource(id uint) error {
+func (s *EndpointService) DeleteDataSource(ctx context.Context, id uint) error {
 	// 先获取数据源信息
+	db := database.Conn(ctx)
 	var dataSource model.DataSource
-	if err := database.DB.First(&dataSource, id).Error; err != nil {
+	if err := db.First(&dataSource, id).Error; err != nil {
 		return fmt.Errorf("failed to get data source: %w", err)
 	}
 
 	// 删除数据源
-	if err := database.DB.Delete(&dataSource).Error; err != nil {
+	if err := db.Delete(&dataSource).Error; err != nil {
 		return fmt.Errorf("failed to delete data source: %w", err)
 	}
+	RecordAudit(ctx, AuditActionDelete, "data_source", id, &dataSource, nil)
+	s.recordRevision(ctx, dataSource.EndpointID, "data_source.delete")
 
 	// 获取关联的端点URL用于清理缓存
-	if endpoint, err := s.GetEndpoint(dataSource.EndpointID); err == nil {
-		s.cacheManager.InvalidateMemoryCache(endpoint.URL)
+	if endpoint, err := s.getEndpoint(ctx, dataSource.EndpointID); err == nil {
+		database.AfterCommit(ctx, func() { s.cacheManager.InvalidateMemoryCache(endpoint.URL) })
 	}
 
 	return nil
@@ -397,6 +708,11 @@ func (s *EndpointService) GetCacheManager() *CacheManager {
 	return s.cacheManager
 }
 
+// GetDataSourceLoadStats 获取数据源并发加载合并统计（用于监控）
+func (s *EndpointService) GetDataSourceLoadStats() map[string]interface{} {
+	return s.dataSourceFetcher.LoadStats()
+}
+
 // GetDataSourceURLCount 获取数据源的URL数量
 func (s *EndpointService) GetDataSourceURLCount(dataSource *model.DataSource) (int, error) {
 	// 对于API类型和端点类型的数据源，返回1（因为每次都是实时请求）
@@ -414,7 +730,7 @@ func (s *EndpointService) GetDataSourceURLCount(dataSource *model.DataSource) (i
 	switch dataSource.Type {
 	case "manual":
 		// 手动数据源可以快速解析配置获取数量
-		urls, err := s.dataSourceFetcher.FetchURLs(dataSource)
+		urls, err := s.dataSourceFetcher.FetchURLs(context.Background(), dataSource)
 		if err != nil {
 			return 0, nil // 返回0而不是错误，避免影响整体统计
 		}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"path"
+	"strings"
+)
+
+// matchHostPattern 判断主机名是否匹配模式（不区分大小写，忽略端口）
+// 支持: example.com 精确匹配; *.example.com 仅匹配子域名; .example.com 匹配自身及子域名;
+// 其他包含 * 或 ? 的模式按通配符匹配
+func matchHostPattern(pattern, host string) bool {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	host = strings.ToLower(stripPort(host))
+	if pattern == "" || host == "" {
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(pattern, "*.") && !strings.ContainsAny(pattern[2:], "*?"):
+		return strings.HasSuffix(host, pattern[1:])
+	case strings.HasPrefix(pattern, "."):
+		return host == pattern[1:] || strings.HasSuffix(host, pattern)
+	case strings.ContainsAny(pattern, "*?"):
+		matched, err := path.Match(pattern, host)
+		return err == nil && matched
+	default:
+		return host == pattern
+	}
+}
+
+// matchAnyHostPattern 判断主机名是否匹配任一模式
+func matchAnyHostPattern(patterns []string, host string) bool {
+	for _, pattern := range patterns {
+		if matchHostPattern(pattern, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripPort 去掉主机名中的端口（兼容 IPv6 字面量）
+func stripPort(host string) string {
+	if strings.HasPrefix(host, "[") {
+		if end := strings.Index(host, "]"); end > 0 {
+			return host[1:end]
+		}
+		return host
+	}
+	if i := strings.LastIndex(host, ":"); i >= 0 && strings.Count(host, ":") == 1 {
+		return host[:i]
+	}
+	return host
+}
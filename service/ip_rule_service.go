@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/netip"
+	"strings"
+	"sync"
+
+	"random-api-go/database"
+	"random-api-go/model"
+	"random-api-go/utils"
+)
+
+// IP 规则动作
+const (
+	IPRuleAllow = "allow"
+	IPRuleDeny  = "deny"
+)
+
+// ipRuleSet 单个范围 (全局或某个端点) 的规则前缀树
+type ipRuleSet struct {
+	trie     *utils.CIDRTrie[*model.IPRule]
+	hasAllow bool // 存在 allow 规则时, 未命中的地址视为拒绝
+}
+
+// IPRuleService IP/CIDR 访问规则服务
+// 规则按范围编译成前缀树缓存在内存中, 随机请求的中间件直接查内存; 规则或端点变更后整体重新加载
+type IPRuleService struct {
+	mu     sync.RWMutex
+	global *ipRuleSet
+	byPath map[string]*ipRuleSet // key: 端点 URL (不含前导 /)
+}
+
+var (
+	ipRuleService *IPRuleService
+	ipRuleOnce    sync.Once
+)
+
+// GetIPRuleService 获取 IP 规则服务实例
+func GetIPRuleService() *IPRuleService {
+	ipRuleOnce.Do(func() {
+		ipRuleService = &IPRuleService{
+			byPath: make(map[string]*ipRuleSet),
+		}
+		if err := ipRuleService.Reload(); err != nil {
+			log.Printf("Failed to load IP rules: %v", err)
+		}
+	})
+	return ipRuleService
+}
+
+// ValidateIPRule 校验并规范化 IP 规则
+func ValidateIPRule(rule *model.IPRule) error {
+	prefix, err := utils.ParsePrefix(rule.CIDR)
+	if err != nil {
+		return err
+	}
+	rule.CIDR = prefix.String()
+
+	rule.Action = strings.ToLower(strings.TrimSpace(rule.Action))
+	switch rule.Action {
+	case IPRuleAllow, IPRuleDeny:
+	default:
+		return fmt.Errorf("unsupported action: %s, supported actions: allow, deny", rule.Action)
+	}
+	return nil
+}
+
+// Reload 从数据库重新加载全部启用的规则, 并按端点路径建立索引
+func (s *IPRuleService) Reload() error {
+	var rules []model.IPRule
+	if err := database.DB.Where("is_active = ?", true).Order("id ASC").Find(&rules).Error; err != nil {
+		return err
+	}
+
+	var endpoints []model.APIEndpoint
+	if err := database.DB.Select("id", "url").Find(&endpoints).Error; err != nil {
+		return err
+	}
+	urlByID := make(map[uint]string, len(endpoints))
+	for _, e := range endpoints {
+		urlByID[e.ID] = e.URL
+	}
+
+	global := newIPRuleSet()
+	byPath := make(map[string]*ipRuleSet)
+	for i := range rules {
+		rule := &rules[i]
+		prefix, err := utils.ParsePrefix(rule.CIDR)
+		if err != nil {
+			log.Printf("忽略无效的 IP 规则 %d: %v", rule.ID, err)
+			continue
+		}
+
+		set := global
+		if rule.EndpointID != nil {
+			url, ok := urlByID[*rule.EndpointID]
+			if !ok {
+				continue
+			}
+			if set = byPath[url]; set == nil {
+				set = newIPRuleSet()
+				byPath[url] = set
+			}
+		}
+		set.add(prefix, rule)
+	}
+
+	s.mu.Lock()
+	s.global = global
+	s.byPath = byPath
+	s.mu.Unlock()
+	return nil
+}
+
+// ListRules 列出 IP 规则
+func (s *IPRuleService) ListRules() ([]model.IPRule, error) {
+	var rules []model.IPRule
+	if err := database.DB.Preload("Endpoint").Order("id ASC").Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("failed to list IP rules: %w", err)
+	}
+	return rules, nil
+}
+
+// CreateRule 创建 IP 规则
+func (s *IPRuleService) CreateRule(rule *model.IPRule) error {
+	if err := ValidateIPRule(rule); err != nil {
+		return err
+	}
+	if err := database.DB.Create(rule).Error; err != nil {
+		return fmt.Errorf("failed to create IP rule: %w", err)
+	}
+	return s.Reload()
+}
+
+// UpdateRule 更新 IP 规则
+func (s *IPRuleService) UpdateRule(rule *model.IPRule) error {
+	if err := ValidateIPRule(rule); err != nil {
+		return err
+	}
+	if err := database.DB.Save(rule).Error; err != nil {
+		return fmt.Errorf("failed to update IP rule: %w", err)
+	}
+	return s.Reload()
+}
+
+// DeleteRule 删除 IP 规则
+func (s *IPRuleService) DeleteRule(id uint) error {
+	if err := database.DB.Delete(&model.IPRule{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete IP rule: %w", err)
+	}
+	return s.Reload()
+}
+
+// DeleteEndpointRules 删除端点专属的 IP 规则 (端点删除时调用, 事务提交后重新加载)
+func (s *IPRuleService) DeleteEndpointRules(ctx context.Context, endpointID uint) error {
+	if err := database.Conn(ctx).Where("endpoint_id = ?", endpointID).Delete(&model.IPRule{}).Error; err != nil {
+		return err
+	}
+	database.AfterCommit(ctx, func() {
+		if err := s.Reload(); err != nil {
+			log.Printf("重新加载 IP 规则失败: %v", err)
+		}
+	})
+	return nil
+}
+
+// Check 判断客户端 IP 是否允许访问该路径 (路径带不带前导 / 均可)
+// 先看端点规则, 端点范围无法判定时再看全局规则; 返回是否放行及命中的规则 (未命中为 nil)
+func (s *IPRuleService) Check(path, ip string) (bool, *model.IPRule) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		// 无法解析的地址不做限制, 交给后续的限流处理
+		return true, nil
+	}
+
+	s.mu.RLock()
+	endpointSet := s.byPath[strings.TrimPrefix(path, "/")]
+	global := s.global
+	s.mu.RUnlock()
+
+	for _, set := range []*ipRuleSet{endpointSet, global} {
+		if set == nil {
+			continue
+		}
+		if allowed, rule, decided := set.check(addr); decided {
+			return allowed, rule
+		}
+	}
+	return true, nil
+}
+
+func newIPRuleSet() *ipRuleSet {
+	return &ipRuleSet{trie: utils.NewCIDRTrie[*model.IPRule]()}
+}
+
+// add 加入规则; 同一前缀重复配置时 deny 优先
+func (set *ipRuleSet) add(prefix netip.Prefix, rule *model.IPRule) {
+	if existing, ok := set.trie.Get(prefix); ok && existing.Action == IPRuleDeny {
+		return
+	}
+	set.trie.Insert(prefix, rule)
+	if rule.Action == IPRuleAllow {
+		set.hasAllow = true
+	}
+}
+
+// check 最长前缀匹配; 未命中且存在 allow 规则时拒绝, 否则交给下一层判定
+func (set *ipRuleSet) check(addr netip.Addr) (allowed bool, rule *model.IPRule, decided bool) {
+	if rule, _, ok := set.trie.Lookup(addr); ok {
+		return rule.Action == IPRuleAllow, rule, true
+	}
+	if set.hasAllow {
+		return false, nil, true
+	}
+	return false, nil, false
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -64,7 +65,8 @@ type LankongResponse struct {
 }
 
 // FetchURLs 从兰空图床获取URL列表
-func (lf *LankongFetcher) FetchURLs(config *model.LankongConfig) ([]string, error) {
+// ctx 取消后立即停止翻页与重试等待
+func (lf *LankongFetcher) FetchURLs(ctx context.Context, config *model.LankongConfig) ([]string, error) {
 	var allURLs []string
 	baseURL := config.BaseURL
 	if baseURL == "" {
@@ -76,8 +78,11 @@ func (lf *LankongFetcher) FetchURLs(config *model.LankongConfig) ([]string, erro
 
 		// 获取第一页以确定总页数
 		firstPageURL := fmt.Sprintf("%s?album_id=%s&page=1", baseURL, albumID)
-		response, err := lf.fetchPageWithRetry(firstPageURL, config.APIToken)
+		response, err := lf.fetchPageWithRetry(ctx, firstPageURL, config.APIToken)
 		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
 			log.Printf("Failed to fetch first page for album %s: %v", albumID, err)
 			continue
 		}
@@ -89,8 +94,11 @@ func (lf *LankongFetcher) FetchURLs(config *model.LankongConfig) ([]string, erro
 		albumURLs := []string{}
 		for page := 1; page <= totalPages; page++ {
 			reqURL := fmt.Sprintf("%s?album_id=%s&page=%d", baseURL, albumID, page)
-			pageResponse, err := lf.fetchPageWithRetry(reqURL, config.APIToken)
+			pageResponse, err := lf.fetchPageWithRetry(ctx, reqURL, config.APIToken)
 			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
 				log.Printf("Failed to fetch page %d for album %s: %v", page, albumID, err)
 				continue
 			}
@@ -115,17 +123,22 @@ func (lf *LankongFetcher) FetchURLs(config *model.LankongConfig) ([]string, erro
 }
 
 // fetchPageWithRetry 带重试的页面获取
-func (lf *LankongFetcher) fetchPageWithRetry(url string, apiToken string) (*LankongResponse, error) {
+func (lf *LankongFetcher) fetchPageWithRetry(ctx context.Context, url string, apiToken string) (*LankongResponse, error) {
 	var lastErr error
 
 	for attempt := 0; attempt <= lf.retryConfig.MaxRetries; attempt++ {
-		response, err := lf.fetchPage(url, apiToken)
+		response, err := lf.fetchPage(ctx, url, apiToken)
 		if err == nil {
 			return response, nil
 		}
 
 		lastErr = err
 
+		// 请求已被取消，不再重试
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
 		// 如果是最后一次尝试，不再重试
 		if attempt == lf.retryConfig.MaxRetries {
 			break
@@ -143,7 +156,11 @@ func (lf *LankongFetcher) fetchPageWithRetry(url string, apiToken string) (*Lank
 			log.Printf("请求失败 (尝试 %d/%d): %v，%v 后重试", attempt+1, lf.retryConfig.MaxRetries+1, err, delay)
 		}
 
-		time.Sleep(delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
 	}
 
 	return nil, fmt.Errorf("重试 %d 次后仍然失败: %v", lf.retryConfig.MaxRetries, lastErr)
@@ -182,8 +199,8 @@ func isRateLimitError(err error) bool {
 }
 
 // fetchPage 获取兰空图床单页数据
-func (lf *LankongFetcher) fetchPage(url string, apiToken string) (*LankongResponse, error) {
-	req, err := http.NewRequest("GET", url, nil)
+func (lf *LankongFetcher) fetchPage(ctx context.Context, url string, apiToken string) (*LankongResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
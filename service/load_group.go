@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// sharedLoadTimeout 共享加载的最长耗时（兰空图床重试序列最长可达数分钟）
+const sharedLoadTimeout = 10 * time.Minute
+
+// ErrColdLoadInProgress 数据源缓存为空且已有加载在进行中（快速失败策略）
+var ErrColdLoadInProgress = errors.New("data source is warming up, please retry later")
+
+// loadCall 一次正在进行的共享加载
+type loadCall struct {
+	done    chan struct{}
+	urls    []string
+	err     error
+	waiters int // 当前等待结果的调用方数量（含发起方）
+}
+
+// LoadGroup 合并同一缓存 key 的并发加载
+// 同一时刻每个 key 只会有一次上游请求，其余调用方等待同一结果或快速失败
+type LoadGroup struct {
+	mu    sync.Mutex
+	calls map[string]*loadCall
+
+	loadsTotal     uint64 // 实际发起的加载次数
+	collapsedTotal uint64 // 合并到已有加载上并等待结果的调用方累计数 (fail_fast 下只有预加载、手动刷新等不快速失败的调用方)
+	fastFailTotal  uint64 // 快速失败累计数 (fail_fast 下被短路的请求, 不计入 collapsedTotal)
+}
+
+// NewLoadGroup 创建加载合并器
+func NewLoadGroup() *LoadGroup {
+	return &LoadGroup{
+		calls: make(map[string]*loadCall),
+	}
+}
+
+// Do 执行 key 对应的加载
+// 已有同 key 加载在进行时: failFast 为 true 直接返回 ErrColdLoadInProgress，否则等待其结果
+// fn 运行在与调用方解耦的 context 上（不继承取消，仅受 sharedLoadTimeout 限制），
+// 避免首个调用方断开导致其他等待者失败、缓存无法写入
+func (g *LoadGroup) Do(ctx context.Context, key string, failFast bool, fn func(context.Context) ([]string, error)) ([]string, error) {
+	g.mu.Lock()
+	if c, ok := g.calls[key]; ok {
+		if failFast {
+			g.fastFailTotal++
+			g.mu.Unlock()
+			return nil, ErrColdLoadInProgress
+		}
+		c.waiters++
+		g.collapsedTotal++
+		g.mu.Unlock()
+		return g.wait(ctx, c)
+	}
+
+	c := &loadCall{done: make(chan struct{}), waiters: 1}
+	g.calls[key] = c
+	g.loadsTotal++
+	g.mu.Unlock()
+
+	go func() {
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sharedLoadTimeout)
+		defer cancel()
+
+		c.urls, c.err = fn(loadCtx)
+
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+
+	return g.wait(ctx, c)
+}
+
+// wait 等待共享加载完成，调用方 ctx 结束时提前返回（加载本身继续进行）
+func (g *LoadGroup) wait(ctx context.Context, c *loadCall) ([]string, error) {
+	defer func() {
+		g.mu.Lock()
+		c.waiters--
+		g.mu.Unlock()
+	}()
+
+	select {
+	case <-c.done:
+		return c.urls, c.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Stats 获取加载合并统计（用于监控）
+// 被合并掉的调用方总数为 collapsed_waiters + fast_fail_total：等待共享结果的计入前者，快速失败的只计入后者
+func (g *LoadGroup) Stats() map[string]interface{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	waiting := 0
+	keys := make([]string, 0, len(g.calls))
+	for key, c := range g.calls {
+		waiting += c.waiters
+		keys = append(keys, key)
+	}
+
+	return map[string]interface{}{
+		"in_flight":         len(g.calls),
+		"in_flight_keys":    keys,
+		"waiting":           waiting,
+		"loads_total":       g.loadsTotal,
+		"collapsed_waiters": g.collapsedTotal,
+		"fast_fail_total":   g.fastFailTotal,
+	}
+}
@@ -0,0 +1,361 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"random-api-go/database"
+	"random-api-go/model"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// 登录限流: 同一用户名或同一 IP 在窗口内失败次数达到上限后锁定
+const (
+	localLoginMaxFailures = 5
+	localLoginWindow      = 15 * time.Minute
+	localLoginLockout     = 15 * time.Minute
+	localPasswordMinLen   = 8
+)
+
+// 本地登录失败的原因 (对外统一返回用户名或密码错误, 不暴露账号是否存在)
+var (
+	ErrLocalLoginInvalid      = errors.New("invalid username or password")
+	ErrLocalLoginTOTPRequired = errors.New("two-factor code required")
+	ErrLocalLoginTOTPInvalid  = errors.New("invalid two-factor code")
+)
+
+// LoginThrottledError 登录失败次数过多
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, retry after %d seconds", int(e.RetryAfter.Seconds()))
+}
+
+// loginFailure 某个用户名或 IP 的失败记录
+type loginFailure struct {
+	count       int
+	firstAt     time.Time
+	lockedUntil time.Time
+}
+
+var (
+	loginFailureMu sync.Mutex
+	loginFailures  = make(map[string]*loginFailure) // key: user:{name} / ip:{addr}
+
+	// dummyPasswordHash 账号不存在时也做一次 bcrypt 比较, 避免通过响应时间判断账号是否存在
+	dummyPasswordHash = sync.OnceValue(func() []byte {
+		hash, _ := bcrypt.GenerateFromPassword([]byte("random-api-dummy-password"), bcrypt.DefaultCost)
+		return hash
+	})
+)
+
+// ValidateLocalAdminPassword 校验密码强度
+func ValidateLocalAdminPassword(password string) error {
+	if len(password) < localPasswordMinLen {
+		return fmt.Errorf("password must be at least %d characters", localPasswordMinLen)
+	}
+	if len(password) > 72 {
+		// bcrypt 只使用前 72 字节
+		return fmt.Errorf("password must be at most 72 bytes")
+	}
+	return nil
+}
+
+// normalizeLocalUsername 用户名不区分大小写
+func normalizeLocalUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// HasLocalAdmins 是否存在可用的本地管理员 (决定登录页是否显示账号密码登录)
+func HasLocalAdmins() bool {
+	var count int64
+	database.DB.Model(&model.LocalAdmin{}).Where("disabled = ?", false).Count(&count)
+	return count > 0
+}
+
+// ListLocalAdmins 列出本地管理员
+func ListLocalAdmins() ([]model.LocalAdmin, error) {
+	var admins []model.LocalAdmin
+	if err := database.DB.Order("id ASC").Find(&admins).Error; err != nil {
+		return nil, fmt.Errorf("failed to list local admins: %w", err)
+	}
+	return admins, nil
+}
+
+// CreateLocalAdmin 创建本地管理员, 角色为空时默认 admin
+func CreateLocalAdmin(username, password, role, endpointIDs string) (*model.LocalAdmin, error) {
+	username = normalizeLocalUsername(username)
+	if username == "" {
+		return nil, fmt.Errorf("username is required")
+	}
+	if err := ValidateLocalAdminPassword(password); err != nil {
+		return nil, err
+	}
+	role, err := normalizeRole(role)
+	if err != nil {
+		return nil, err
+	}
+	if endpointIDs, err = normalizeEndpointIDs(endpointIDs); err != nil {
+		return nil, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	admin := &model.LocalAdmin{Username: username, PasswordHash: string(hash), Role: role, EndpointIDs: endpointIDs}
+	if err := database.DB.Create(admin).Error; err != nil {
+		return nil, fmt.Errorf("failed to create local admin: %w", err)
+	}
+	return admin, nil
+}
+
+// FindLocalAdmin 按用户名查找本地管理员
+func FindLocalAdmin(username string) (*model.LocalAdmin, error) {
+	// 用 Find 而不是 First, 避免登录时每个不存在的用户名都打印 record not found 日志
+	var admin model.LocalAdmin
+	result := database.DB.Where("username = ?", normalizeLocalUsername(username)).Limit(1).Find(&admin)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &admin, nil
+}
+
+// SetLocalAdminPassword 重置密码并撤销该账号的全部会话
+func SetLocalAdminPassword(id uint, password string) error {
+	if err := ValidateLocalAdminPassword(password); err != nil {
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := database.DB.Model(&model.LocalAdmin{}).Where("id = ?", id).Update("password_hash", string(hash)).Error; err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	return RevokeLocalAdminSessions(id)
+}
+
+// ChangeLocalAdminPassword 本人修改密码, 需要验证旧密码
+func ChangeLocalAdminPassword(id uint, oldPassword, newPassword string) error {
+	var admin model.LocalAdmin
+	if err := database.DB.First(&admin, id).Error; err != nil {
+		return err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(admin.PasswordHash), []byte(oldPassword)) != nil {
+		return ErrLocalLoginInvalid
+	}
+	return SetLocalAdminPassword(id, newPassword)
+}
+
+// SetLocalAdminRole 修改本地管理员的角色与端点范围
+func SetLocalAdminRole(id uint, role, endpointIDs string) error {
+	role, err := normalizeRole(role)
+	if err != nil {
+		return err
+	}
+	endpointIDs, err = normalizeEndpointIDs(endpointIDs)
+	if err != nil {
+		return err
+	}
+	return database.DB.Model(&model.LocalAdmin{}).Where("id = ?", id).UpdateColumns(map[string]interface{}{
+		"role":         role,
+		"endpoint_ids": endpointIDs,
+	}).Error
+}
+
+// SetLocalAdminDisabled 启用或禁用本地管理员, 禁用时撤销其会话
+func SetLocalAdminDisabled(id uint, disabled bool) error {
+	if err := database.DB.Model(&model.LocalAdmin{}).Where("id = ?", id).Update("disabled", disabled).Error; err != nil {
+		return fmt.Errorf("failed to update local admin: %w", err)
+	}
+	if disabled {
+		return RevokeLocalAdminSessions(id)
+	}
+	return nil
+}
+
+// DeleteLocalAdmin 删除本地管理员及其会话
+func DeleteLocalAdmin(id uint) error {
+	if err := database.DB.Delete(&model.LocalAdmin{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete local admin: %w", err)
+	}
+	return RevokeLocalAdminSessions(id)
+}
+
+// AuthenticateLocalAdmin 校验用户名、密码与二次验证码, 带失败次数限制
+func AuthenticateLocalAdmin(username, password, totpCode, ip string) (*model.LocalAdmin, error) {
+	username = normalizeLocalUsername(username)
+	keys := []string{"user:" + username, "ip:" + ip}
+	if retry := loginLockedFor(keys); retry > 0 {
+		return nil, &LoginThrottledError{RetryAfter: retry}
+	}
+
+	admin, err := FindLocalAdmin(username)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+		recordLoginFailure(keys)
+		return nil, ErrLocalLoginInvalid
+	}
+	if bcrypt.CompareHashAndPassword([]byte(admin.PasswordHash), []byte(password)) != nil || admin.Disabled {
+		recordLoginFailure(keys)
+		return nil, ErrLocalLoginInvalid
+	}
+
+	if admin.TOTPEnabled {
+		if strings.TrimSpace(totpCode) == "" {
+			// 密码正确, 提示前端输入验证码, 不计入失败次数
+			return nil, ErrLocalLoginTOTPRequired
+		}
+		step, ok := verifyTOTP(admin.TOTPSecret, totpCode, time.Now(), admin.TOTPLastStep)
+		if !ok {
+			recordLoginFailure(keys)
+			return nil, ErrLocalLoginTOTPInvalid
+		}
+		admin.TOTPLastStep = step
+	}
+
+	clearLoginFailures(keys)
+	now := time.Now()
+	admin.LastLoginAt = &now
+	if err := database.DB.Model(admin).UpdateColumns(map[string]interface{}{
+		"last_login_at":  now,
+		"totp_last_step": admin.TOTPLastStep,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return admin, nil
+}
+
+// LocalAdminUserInfo 转换为会话使用的用户信息
+func LocalAdminUserInfo(admin *model.LocalAdmin) *OAuthUserInfo {
+	return &OAuthUserInfo{
+		ID:       localAdminSubject(admin.ID),
+		Username: admin.Username,
+		Nickname: admin.Username,
+		Source:   SessionSourceLocal,
+	}
+}
+
+// BeginLocalAdminTOTP 生成待确认的二次验证密钥, 返回密钥与 otpauth:// 地址
+func BeginLocalAdminTOTP(id uint) (string, string, error) {
+	var admin model.LocalAdmin
+	if err := database.DB.First(&admin, id).Error; err != nil {
+		return "", "", err
+	}
+	if admin.TOTPEnabled {
+		return "", "", fmt.Errorf("two-factor authentication is already enabled")
+	}
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	if err := database.DB.Model(&admin).Update("totp_secret", secret).Error; err != nil {
+		return "", "", err
+	}
+	return secret, totpURL(admin.Username, secret), nil
+}
+
+// EnableLocalAdminTOTP 用验证码确认密钥后开启二次验证
+func EnableLocalAdminTOTP(id uint, code string) error {
+	var admin model.LocalAdmin
+	if err := database.DB.First(&admin, id).Error; err != nil {
+		return err
+	}
+	if admin.TOTPEnabled {
+		return fmt.Errorf("two-factor authentication is already enabled")
+	}
+	if admin.TOTPSecret == "" {
+		return fmt.Errorf("call setup first")
+	}
+	step, ok := verifyTOTP(admin.TOTPSecret, code, time.Now(), admin.TOTPLastStep)
+	if !ok {
+		return ErrLocalLoginTOTPInvalid
+	}
+	return database.DB.Model(&admin).UpdateColumns(map[string]interface{}{
+		"totp_enabled":   true,
+		"totp_last_step": step,
+	}).Error
+}
+
+// DisableLocalAdminTOTP 关闭二次验证; code 为空时跳过验证 (命令行重置使用)
+func DisableLocalAdminTOTP(id uint, code string, verify bool) error {
+	var admin model.LocalAdmin
+	if err := database.DB.First(&admin, id).Error; err != nil {
+		return err
+	}
+	if verify && admin.TOTPEnabled {
+		if _, ok := verifyTOTP(admin.TOTPSecret, code, time.Now(), admin.TOTPLastStep); !ok {
+			return ErrLocalLoginTOTPInvalid
+		}
+	}
+	return database.DB.Model(&admin).UpdateColumns(map[string]interface{}{
+		"totp_enabled":   false,
+		"totp_secret":    "",
+		"totp_last_step": 0,
+	}).Error
+}
+
+// loginLockedFor 返回仍需等待的锁定时长, 0 表示未锁定
+func loginLockedFor(keys []string) time.Duration {
+	loginFailureMu.Lock()
+	defer loginFailureMu.Unlock()
+	now := time.Now()
+	var wait time.Duration
+	for _, key := range keys {
+		if f, ok := loginFailures[key]; ok && now.Before(f.lockedUntil) {
+			if d := f.lockedUntil.Sub(now); d > wait {
+				wait = d
+			}
+		}
+	}
+	return wait
+}
+
+// recordLoginFailure 记录一次失败, 窗口内达到上限后锁定
+func recordLoginFailure(keys []string) {
+	loginFailureMu.Lock()
+	defer loginFailureMu.Unlock()
+	now := time.Now()
+
+	// 顺带清理过期记录, 防止被大量随机用户名撑大
+	for key, f := range loginFailures {
+		if now.Sub(f.firstAt) > localLoginWindow && now.After(f.lockedUntil) {
+			delete(loginFailures, key)
+		}
+	}
+
+	for _, key := range keys {
+		f, ok := loginFailures[key]
+		if !ok || now.Sub(f.firstAt) > localLoginWindow {
+			f = &loginFailure{firstAt: now}
+			loginFailures[key] = f
+		}
+		f.count++
+		if f.count >= localLoginMaxFailures {
+			f.lockedUntil = now.Add(localLoginLockout)
+			f.count = 0
+			f.firstAt = now
+		}
+	}
+}
+
+// clearLoginFailures 登录成功后清除用户名的失败记录 (IP 记录保留, 防止用一个正确账号给其他账号解锁)
+func clearLoginFailures(keys []string) {
+	loginFailureMu.Lock()
+	defer loginFailureMu.Unlock()
+	delete(loginFailures, keys[0])
+}
@@ -0,0 +1,478 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"random-api-go/config"
+)
+
+// OAuth 预设
+const (
+	OAuthProviderCZL    = "czl"
+	OAuthProviderGitHub = "github"
+	OAuthProviderOIDC   = "oidc"
+)
+
+// OAuthStateTTL 登录流程 (跳转到回调) 的最长时间
+const OAuthStateTTL = 10 * time.Minute
+
+// 等待回调的登录数量上限 (总数与单个 IP), 防止未完成的登录请求无限占用内存
+const (
+	maxOAuthPendings      = 10000
+	maxOAuthPendingsPerIP = 20
+)
+
+// ErrTooManyOAuthLogins 未完成的登录过多
+var ErrTooManyOAuthLogins = errors.New("too many pending OAuth logins, try again later")
+
+// OAuthProvider 解析后的登录提供方配置
+type OAuthProvider struct {
+	Name               string
+	AuthorizeURL       string
+	TokenURL           string
+	UserInfoURL        string
+	Scopes             string
+	PKCE               bool
+	IDClaim            string
+	UsernameClaim      string
+	EmailClaim         string
+	EmailVerifiedClaim string // 邮箱是否已验证的声明, 不为 true 时丢弃邮箱; 为空时信任提供方返回的邮箱
+	NicknameClaim      string
+	AvatarClaim        string
+}
+
+// OAuthToken 令牌端点响应
+type OAuthToken struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// OAuthUserInfo 按声明映射后的用户信息
+type OAuthUserInfo struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Nickname string `json:"nickname"`
+	Email    string `json:"email"`
+	Avatar   string `json:"avatar"`
+	Source   string `json:"source"` // oauth / local
+}
+
+// Identity 转换为管理员名单匹配使用的身份
+func (u *OAuthUserInfo) Identity() AdminIdentity {
+	return AdminIdentity{ID: u.ID, Username: u.Username, Email: u.Email, Local: u.Source == SessionSourceLocal}
+}
+
+// oauthPending 已发起、等待回调的登录
+type oauthPending struct {
+	verifier    string
+	redirectURI string
+	ip          string
+	expiresAt   time.Time
+}
+
+var (
+	oauthProvider   *OAuthProvider
+	oauthProviderMu sync.Mutex
+
+	oauthPendingMu sync.Mutex
+	oauthPendings  = make(map[string]*oauthPending) // key: state
+
+	oauthHTTPClient = &http.Client{Timeout: 10 * time.Second}
+)
+
+// oauthPresets 内置提供方; 环境变量中的端点、scope 与声明映射会覆盖预设
+var oauthPresets = map[string]OAuthProvider{
+	OAuthProviderCZL: {
+		Name:          "CZL Connect",
+		AuthorizeURL:  "https://connect.czl.net/oauth2/authorize",
+		TokenURL:      "https://connect.czl.net/api/oauth2/token",
+		UserInfoURL:   "https://connect.czl.net/api/oauth2/userinfo",
+		Scopes:        "read write",
+		IDClaim:       "id",
+		UsernameClaim: "username",
+		EmailClaim:    "email",
+		NicknameClaim: "nickname",
+		AvatarClaim:   "avatar",
+	},
+	OAuthProviderGitHub: {
+		Name:          "GitHub",
+		AuthorizeURL:  "https://github.com/login/oauth/authorize",
+		TokenURL:      "https://github.com/login/oauth/access_token",
+		UserInfoURL:   "https://api.github.com/user",
+		Scopes:        "read:user user:email",
+		PKCE:          true,
+		IDClaim:       "id",
+		UsernameClaim: "login",
+		EmailClaim:    "email",
+		NicknameClaim: "name",
+		AvatarClaim:   "avatar_url",
+	},
+	OAuthProviderOIDC: {
+		Name:               "OpenID Connect",
+		Scopes:             "openid profile email",
+		PKCE:               true,
+		IDClaim:            "sub",
+		UsernameClaim:      "preferred_username",
+		EmailClaim:         "email",
+		EmailVerifiedClaim: "email_verified",
+		NicknameClaim:      "name",
+		AvatarClaim:        "picture",
+	},
+}
+
+// GetOAuthProvider 解析登录提供方配置 (oidc 通过 issuer 的 discovery 文档获取端点), 成功后缓存
+func GetOAuthProvider() (*OAuthProvider, error) {
+	oauthProviderMu.Lock()
+	defer oauthProviderMu.Unlock()
+	if oauthProvider != nil {
+		return oauthProvider, nil
+	}
+
+	cfg := config.Get().OAuth
+	preset, ok := oauthPresets[cfg.Provider]
+	if !ok {
+		return nil, fmt.Errorf("unsupported OAuth provider: %s, supported providers: czl, github, oidc", cfg.Provider)
+	}
+	provider := preset
+
+	if cfg.Issuer != "" {
+		if err := discoverOIDC(&provider, cfg.Issuer); err != nil {
+			return nil, err
+		}
+	}
+
+	override := func(target *string, value string) {
+		if value != "" {
+			*target = value
+		}
+	}
+	override(&provider.Name, cfg.ProviderName)
+	override(&provider.AuthorizeURL, cfg.AuthorizeURL)
+	override(&provider.TokenURL, cfg.TokenURL)
+	override(&provider.UserInfoURL, cfg.UserInfoURL)
+	override(&provider.Scopes, cfg.Scopes)
+	override(&provider.IDClaim, cfg.IDClaim)
+	override(&provider.UsernameClaim, cfg.UsernameClaim)
+	override(&provider.EmailClaim, cfg.EmailClaim)
+	override(&provider.EmailVerifiedClaim, cfg.EmailVerifiedClaim)
+	if strings.EqualFold(provider.EmailVerifiedClaim, "none") {
+		provider.EmailVerifiedClaim = ""
+	}
+	switch strings.ToLower(cfg.PKCE) {
+	case "true", "1", "yes":
+		provider.PKCE = true
+	case "false", "0", "no":
+		provider.PKCE = false
+	}
+
+	if provider.AuthorizeURL == "" || provider.TokenURL == "" || provider.UserInfoURL == "" {
+		return nil, fmt.Errorf("OAuth provider %q is missing endpoints, set OAUTH_ISSUER or OAUTH_AUTHORIZE_URL / OAUTH_TOKEN_URL / OAUTH_USERINFO_URL", cfg.Provider)
+	}
+
+	log.Printf("OAuth provider: %s (authorize=%s, pkce=%t)", provider.Name, provider.AuthorizeURL, provider.PKCE)
+	oauthProvider = &provider
+	return oauthProvider, nil
+}
+
+// discoverOIDC 读取 {issuer}/.well-known/openid-configuration 填充端点
+func discoverOIDC(provider *OAuthProvider, issuer string) error {
+	discoveryURL := strings.TrimRight(issuer, "/") + "/.well-known/openid-configuration"
+	resp, err := oauthHTTPClient.Get(discoveryURL)
+	if err != nil {
+		return fmt.Errorf("OIDC discovery failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("OIDC discovery failed with status: %d", resp.StatusCode)
+	}
+
+	var doc struct {
+		Issuer                string   `json:"issuer"`
+		AuthorizationEndpoint string   `json:"authorization_endpoint"`
+		TokenEndpoint         string   `json:"token_endpoint"`
+		UserinfoEndpoint      string   `json:"userinfo_endpoint"`
+		CodeChallengeMethods  []string `json:"code_challenge_methods_supported"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return fmt.Errorf("failed to decode OIDC discovery document: %w", err)
+	}
+	if strings.TrimRight(doc.Issuer, "/") != strings.TrimRight(issuer, "/") {
+		return fmt.Errorf("OIDC issuer mismatch: configured %s, discovered %s", issuer, doc.Issuer)
+	}
+
+	provider.AuthorizeURL = doc.AuthorizationEndpoint
+	provider.TokenURL = doc.TokenEndpoint
+	provider.UserInfoURL = doc.UserinfoEndpoint
+	for _, method := range doc.CodeChallengeMethods {
+		if method == "S256" {
+			provider.PKCE = true
+		}
+	}
+	return nil
+}
+
+// OAuthRedirectURI 回调地址, 需要在提供方处登记
+func OAuthRedirectURI() string {
+	return strings.TrimRight(config.Get().App.BaseURL, "/") + "/api/admin/oauth/callback"
+}
+
+// StartOAuthLogin 生成 state (与 PKCE verifier) 并返回提供方的授权地址与 state
+// 调用方需把 state 绑定到发起登录的浏览器 (cookie), 回调时核对
+// 未完成的登录超过总数或该 IP 的上限时返回 ErrTooManyOAuthLogins
+func StartOAuthLogin(ip string) (string, string, error) {
+	provider, err := GetOAuthProvider()
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := randomURLToken(24)
+	if err != nil {
+		return "", "", err
+	}
+	pending := &oauthPending{
+		redirectURI: OAuthRedirectURI(),
+		ip:          ip,
+		expiresAt:   time.Now().Add(OAuthStateTTL),
+	}
+
+	params := url.Values{}
+	params.Set("client_id", config.Get().OAuth.ClientID)
+	params.Set("redirect_uri", pending.redirectURI)
+	params.Set("response_type", "code")
+	params.Set("scope", provider.Scopes)
+	params.Set("state", state)
+	if provider.PKCE {
+		if pending.verifier, err = randomURLToken(32); err != nil {
+			return "", "", err
+		}
+		sum := sha256.Sum256([]byte(pending.verifier))
+		params.Set("code_challenge", base64.RawURLEncoding.EncodeToString(sum[:]))
+		params.Set("code_challenge_method", "S256")
+	}
+
+	now := time.Now()
+	oauthPendingMu.Lock()
+	fromIP := 0
+	for key, p := range oauthPendings {
+		if now.After(p.expiresAt) {
+			delete(oauthPendings, key)
+		} else if p.ip == ip {
+			fromIP++
+		}
+	}
+	if len(oauthPendings) >= maxOAuthPendings || fromIP >= maxOAuthPendingsPerIP {
+		oauthPendingMu.Unlock()
+		return "", "", ErrTooManyOAuthLogins
+	}
+	oauthPendings[state] = pending
+	oauthPendingMu.Unlock()
+
+	separator := "?"
+	if strings.Contains(provider.AuthorizeURL, "?") {
+		separator = "&"
+	}
+	return provider.AuthorizeURL + separator + params.Encode(), state, nil
+}
+
+// CompleteOAuthLogin 校验 state 后用授权码换取令牌并获取用户信息
+func CompleteOAuthLogin(ctx context.Context, state, code string) (*OAuthToken, *OAuthUserInfo, error) {
+	oauthPendingMu.Lock()
+	pending, ok := oauthPendings[state]
+	delete(oauthPendings, state)
+	oauthPendingMu.Unlock()
+	if !ok || time.Now().After(pending.expiresAt) {
+		return nil, nil, fmt.Errorf("invalid or expired state")
+	}
+
+	token, err := ExchangeOAuthCode(ctx, code, pending.redirectURI, pending.verifier)
+	if err != nil {
+		return nil, nil, err
+	}
+	user, err := FetchOAuthUserInfo(ctx, token.AccessToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	return token, user, nil
+}
+
+// ExchangeOAuthCode 使用授权码换取访问令牌
+// 先用 Basic Auth 认证客户端, 失败后改为在请求体中发送 client credentials; 未配置 client_secret 时按公共客户端处理
+func ExchangeOAuthCode(ctx context.Context, code, redirectURI, verifier string) (*OAuthToken, error) {
+	provider, err := GetOAuthProvider()
+	if err != nil {
+		return nil, err
+	}
+	cfg := config.Get().OAuth
+	if cfg.ClientID == "" {
+		return nil, fmt.Errorf("OAuth配置缺失: client_id")
+	}
+
+	data := url.Values{}
+	data.Set("grant_type", "authorization_code")
+	data.Set("code", code)
+	data.Set("redirect_uri", redirectURI)
+	if verifier != "" {
+		data.Set("code_verifier", verifier)
+	}
+
+	// 记录调试信息（不包含敏感信息）
+	log.Printf("OAuth token exchange: client_id=%s, redirect_uri=%s", cfg.ClientID, redirectURI)
+
+	if cfg.ClientSecret != "" {
+		token, err := requestOAuthToken(ctx, provider.TokenURL, data, cfg.ClientID, cfg.ClientSecret, "Basic Auth")
+		if err == nil {
+			return token, nil
+		}
+		log.Printf("Basic Auth failed, trying with client credentials in body: %v", err)
+		data.Set("client_secret", cfg.ClientSecret)
+	}
+	data.Set("client_id", cfg.ClientID)
+	return requestOAuthToken(ctx, provider.TokenURL, data, "", "", "Body Auth")
+}
+
+// requestOAuthToken 请求令牌端点; basicUser 非空时使用 Basic Auth
+func requestOAuthToken(ctx context.Context, tokenURL string, data url.Values, basicUser, basicPass, method string) (*OAuthToken, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json") // GitHub 默认返回表单格式
+	if basicUser != "" {
+		req.SetBasicAuth(basicUser, basicPass)
+	}
+
+	resp, err := oauthHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %v", err)
+	}
+
+	log.Printf("OAuth token response (%s): status=%d, body_length=%d", method, resp.StatusCode, len(body))
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("OAuth token exchange failed (%s): status=%d, response=%s", method, resp.StatusCode, string(body))
+		return nil, fmt.Errorf("token request failed with status: %d, body: %s", resp.StatusCode, string(body))
+	}
+
+	var token OAuthToken
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("failed to parse token response: %v, body: %s", err, string(body))
+	}
+	if token.AccessToken == "" {
+		// GitHub 等提供方出错时仍返回 200, 错误信息在响应体中
+		return nil, fmt.Errorf("token response has no access_token, body: %s", string(body))
+	}
+	return &token, nil
+}
+
+// FetchOAuthUserInfo 通过访问令牌获取用户信息, 按配置的声明映射字段
+func FetchOAuthUserInfo(ctx context.Context, accessToken string) (*OAuthUserInfo, error) {
+	provider, err := GetOAuthProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, provider.UserInfoURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := oauthHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user info: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get user info, status: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user info: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber() // 数字 ID 保持原样, 不转成浮点
+	var claims map[string]interface{}
+	if err := decoder.Decode(&claims); err != nil {
+		return nil, fmt.Errorf("failed to decode user info: %w", err)
+	}
+
+	user := &OAuthUserInfo{
+		ID:       claimString(claims, provider.IDClaim),
+		Username: claimString(claims, provider.UsernameClaim),
+		Nickname: claimString(claims, provider.NicknameClaim),
+		Email:    claimString(claims, provider.EmailClaim),
+		Avatar:   claimString(claims, provider.AvatarClaim),
+		Source:   SessionSourceOAuth,
+	}
+	if user.ID == "" {
+		return nil, fmt.Errorf("user info has no %q claim", provider.IDClaim)
+	}
+	// 未验证的邮箱可能由用户随意填写, 丢弃后名单只能按 ID / 用户名匹配
+	if user.Email != "" && provider.EmailVerifiedClaim != "" && claimString(claims, provider.EmailVerifiedClaim) != "true" {
+		log.Printf("OAuth user %s: email %s is not verified (%s), ignored", user.ID, user.Email, provider.EmailVerifiedClaim)
+		user.Email = ""
+	}
+	if user.Username == "" {
+		user.Username = user.ID
+	}
+	return user, nil
+}
+
+// claimString 读取声明并转成字符串, 支持 a.b 形式的嵌套声明
+func claimString(claims map[string]interface{}, name string) string {
+	if name == "" {
+		return ""
+	}
+	var value interface{} = claims
+	for _, part := range strings.Split(name, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		value = m[part]
+	}
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+// randomURLToken 生成 base64url 随机串
+func randomURLToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
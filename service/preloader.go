@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"random-api-go/database"
@@ -90,7 +91,7 @@ func (p *Preloader) PreloadDataSourceOnSave(dataSource *model.DataSource) {
 	go func() {
 		log.Printf("开始预加载数据源 %d (%s)", dataSource.ID, dataSource.Type)
 
-		if err := p.dataSourceFetcher.PreloadDataSource(dataSource); err != nil {
+		if err := p.dataSourceFetcher.PreloadDataSource(context.Background(), dataSource); err != nil {
 			log.Printf("预加载数据源 %d 失败: %v", dataSource.ID, err)
 		} else {
 			log.Printf("数据源 %d 预加载成功", dataSource.ID)
@@ -120,7 +121,7 @@ func (p *Preloader) PreloadEndpointOnSave(endpoint *model.APIEndpoint) {
 			go func(ds model.DataSource) {
 				defer wg.Done()
 
-				if err := p.dataSourceFetcher.PreloadDataSource(&ds); err != nil {
+				if err := p.dataSourceFetcher.PreloadDataSource(context.Background(), &ds); err != nil {
 					log.Printf("预加载数据源 %d 失败: %v", ds.ID, err)
 				}
 			}(dataSource)
@@ -154,7 +155,7 @@ func (p *Preloader) RefreshDataSource(dataSourceID uint) error {
 	}
 
 	log.Printf("手动刷新数据源 %d", dataSourceID)
-	return p.dataSourceFetcher.RefreshDataSource(&dataSource)
+	return p.dataSourceFetcher.RefreshDataSource(context.Background(), &dataSource)
 }
 
 // RefreshEndpoint 手动刷新指定端点的所有数据源
@@ -184,7 +185,7 @@ func (p *Preloader) RefreshEndpoint(endpointID uint) error {
 		go func(ds model.DataSource) {
 			defer wg.Done()
 
-			if err := p.dataSourceFetcher.RefreshDataSource(&ds); err != nil {
+			if err := p.dataSourceFetcher.RefreshDataSource(context.Background(), &ds); err != nil {
 				log.Printf("刷新数据源 %d 失败: %v", ds.ID, err)
 				lastErr = err
 			}
@@ -310,7 +311,7 @@ func (p *Preloader) shouldPeriodicRefresh(dataSource *model.DataSource) bool {
 
 // refreshDataSourceAsync 异步刷新数据源
 func (p *Preloader) refreshDataSourceAsync(dataSource *model.DataSource) {
-	if err := p.dataSourceFetcher.PreloadDataSource(dataSource); err != nil {
+	if err := p.dataSourceFetcher.PreloadDataSource(context.Background(), dataSource); err != nil {
 		log.Printf("定期刷新数据源 %d 失败: %v", dataSource.ID, err)
 	} else {
 		log.Printf("数据源 %d 定期刷新成功", dataSource.ID)
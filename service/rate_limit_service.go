@@ -0,0 +1,267 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"random-api-go/database"
+	"random-api-go/model"
+
+	"golang.org/x/time/rate"
+)
+
+// 限流策略范围
+const (
+	RateLimitScopeGlobal   = "global"
+	RateLimitScopeEndpoint = "endpoint"
+	RateLimitScopeReferer  = "referer"
+)
+
+// 配置表 rate_limit_* 未设置时的默认值: 40 次 / 2 秒, 即每秒 20 次, 突发 40
+const (
+	defaultRateLimitRequests = 40
+	defaultRateLimitWindow   = 2
+	defaultIPv6Prefix        = 64
+)
+
+// RateLimitRule 请求最终使用的限流参数
+type RateLimitRule struct {
+	Key        string // 限流桶命名空间, 策略修改后随之变化, 旧桶自然闲置淘汰
+	Requests   int
+	Window     time.Duration
+	Burst      int
+	IPv6Prefix int
+	Shared     bool
+}
+
+// Limit 令牌补充速率
+func (r *RateLimitRule) Limit() rate.Limit {
+	return rate.Limit(float64(r.Requests) / r.Window.Seconds())
+}
+
+// RateLimitService 限流策略服务
+// 策略缓存在内存中, 限流中间件直接查内存; 策略或端点变更后整体重新加载
+type RateLimitService struct {
+	mu       sync.RWMutex
+	global   *model.RateLimitPolicy
+	byPath   map[string]*model.RateLimitPolicy // key: 端点 URL (不含前导 /)
+	referers []*model.RateLimitPolicy          // 按 ID 顺序匹配
+}
+
+var (
+	rateLimitService *RateLimitService
+	rateLimitOnce    sync.Once
+)
+
+// GetRateLimitService 获取限流策略服务实例
+func GetRateLimitService() *RateLimitService {
+	rateLimitOnce.Do(func() {
+		rateLimitService = &RateLimitService{
+			byPath: make(map[string]*model.RateLimitPolicy),
+		}
+		if err := rateLimitService.Reload(); err != nil {
+			log.Printf("Failed to load rate limit policies: %v", err)
+		}
+	})
+	return rateLimitService
+}
+
+// ValidateRateLimitPolicy 校验并规范化限流策略
+func ValidateRateLimitPolicy(policy *model.RateLimitPolicy) error {
+	policy.Scope = strings.ToLower(strings.TrimSpace(policy.Scope))
+	policy.RefererPattern = strings.ToLower(strings.TrimSpace(policy.RefererPattern))
+
+	switch policy.Scope {
+	case RateLimitScopeGlobal:
+		policy.EndpointID = nil
+		policy.RefererPattern = ""
+	case RateLimitScopeEndpoint:
+		if policy.EndpointID == nil {
+			return fmt.Errorf("endpoint_id is required for endpoint scope")
+		}
+		policy.RefererPattern = ""
+	case RateLimitScopeReferer:
+		if policy.RefererPattern == "" {
+			return fmt.Errorf("referer_pattern is required for referer scope")
+		}
+		policy.EndpointID = nil
+	default:
+		return fmt.Errorf("unsupported scope: %s, supported scopes: global, endpoint, referer", policy.Scope)
+	}
+
+	if policy.Requests < 0 || policy.Burst < 0 {
+		return fmt.Errorf("requests and burst must not be negative")
+	}
+	if policy.Requests > 0 && policy.WindowSeconds <= 0 {
+		return fmt.Errorf("window_seconds must be positive")
+	}
+	if policy.IPv6Prefix < 0 || policy.IPv6Prefix > 128 {
+		return fmt.Errorf("ipv6_prefix must be between 0 and 128")
+	}
+	return nil
+}
+
+// Reload 从数据库重新加载全部启用的策略, 并按端点路径建立索引
+func (s *RateLimitService) Reload() error {
+	var policies []model.RateLimitPolicy
+	if err := database.DB.Where("is_active = ?", true).Order("id ASC").Find(&policies).Error; err != nil {
+		return err
+	}
+
+	var endpoints []model.APIEndpoint
+	if err := database.DB.Select("id", "url").Find(&endpoints).Error; err != nil {
+		return err
+	}
+	urlByID := make(map[uint]string, len(endpoints))
+	for _, e := range endpoints {
+		urlByID[e.ID] = e.URL
+	}
+
+	var global *model.RateLimitPolicy
+	byPath := make(map[string]*model.RateLimitPolicy)
+	var referers []*model.RateLimitPolicy
+	for i := range policies {
+		policy := &policies[i]
+		switch policy.Scope {
+		case RateLimitScopeGlobal:
+			if global == nil {
+				global = policy
+			}
+		case RateLimitScopeEndpoint:
+			if policy.EndpointID == nil {
+				continue
+			}
+			if url, ok := urlByID[*policy.EndpointID]; ok {
+				if _, exists := byPath[url]; !exists {
+					byPath[url] = policy
+				}
+			}
+		case RateLimitScopeReferer:
+			referers = append(referers, policy)
+		}
+	}
+
+	s.mu.Lock()
+	s.global = global
+	s.byPath = byPath
+	s.referers = referers
+	s.mu.Unlock()
+	return nil
+}
+
+// Resolve 按请求路径与来源域名选出限流参数, 返回 nil 表示不限流
+func (s *RateLimitService) Resolve(path, refererDomain string) *RateLimitRule {
+	if database.GetConfig("rate_limit_enabled", "true") != "true" {
+		return nil
+	}
+
+	s.mu.RLock()
+	policy := s.byPath[strings.TrimPrefix(path, "/")]
+	if policy == nil && refererDomain != "" {
+		for _, p := range s.referers {
+			if matchHostPattern(p.RefererPattern, refererDomain) {
+				policy = p
+				break
+			}
+		}
+	}
+	if policy == nil {
+		policy = s.global
+	}
+	s.mu.RUnlock()
+
+	if policy != nil {
+		return ruleFromPolicy(policy)
+	}
+
+	requests := getIntConfig("rate_limit_requests", defaultRateLimitRequests)
+	window := getIntConfig("rate_limit_window", defaultRateLimitWindow)
+	if requests <= 0 || window <= 0 {
+		return nil
+	}
+	return &RateLimitRule{
+		Key:        fmt.Sprintf("cfg:%d:%d", requests, window),
+		Requests:   requests,
+		Window:     time.Duration(window) * time.Second,
+		Burst:      requests,
+		IPv6Prefix: defaultIPv6Prefix,
+	}
+}
+
+// ruleFromPolicy 将策略转换为限流参数; requests 为 0 表示该范围不限流
+func ruleFromPolicy(policy *model.RateLimitPolicy) *RateLimitRule {
+	if policy.Requests <= 0 || policy.WindowSeconds <= 0 {
+		return nil
+	}
+	rule := &RateLimitRule{
+		Key:        fmt.Sprintf("p%d:%d", policy.ID, policy.UpdatedAt.UnixNano()),
+		Requests:   policy.Requests,
+		Window:     time.Duration(policy.WindowSeconds) * time.Second,
+		Burst:      policy.Burst,
+		IPv6Prefix: policy.IPv6Prefix,
+		Shared:     policy.Shared,
+	}
+	if rule.Burst <= 0 {
+		rule.Burst = rule.Requests
+	}
+	if rule.IPv6Prefix <= 0 {
+		rule.IPv6Prefix = defaultIPv6Prefix
+	}
+	return rule
+}
+
+// ListPolicies 列出限流策略
+func (s *RateLimitService) ListPolicies() ([]model.RateLimitPolicy, error) {
+	var policies []model.RateLimitPolicy
+	if err := database.DB.Preload("Endpoint").Order("id ASC").Find(&policies).Error; err != nil {
+		return nil, fmt.Errorf("failed to list rate limit policies: %w", err)
+	}
+	return policies, nil
+}
+
+// CreatePolicy 创建限流策略
+func (s *RateLimitService) CreatePolicy(policy *model.RateLimitPolicy) error {
+	if err := ValidateRateLimitPolicy(policy); err != nil {
+		return err
+	}
+	if err := database.DB.Create(policy).Error; err != nil {
+		return fmt.Errorf("failed to create rate limit policy: %w", err)
+	}
+	return s.Reload()
+}
+
+// UpdatePolicy 更新限流策略
+func (s *RateLimitService) UpdatePolicy(policy *model.RateLimitPolicy) error {
+	if err := ValidateRateLimitPolicy(policy); err != nil {
+		return err
+	}
+	if err := database.DB.Save(policy).Error; err != nil {
+		return fmt.Errorf("failed to update rate limit policy: %w", err)
+	}
+	return s.Reload()
+}
+
+// DeletePolicy 删除限流策略
+func (s *RateLimitService) DeletePolicy(id uint) error {
+	if err := database.DB.Delete(&model.RateLimitPolicy{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete rate limit policy: %w", err)
+	}
+	return s.Reload()
+}
+
+// DeleteEndpointPolicies 删除端点专属的限流策略 (端点删除时调用, 事务提交后重新加载)
+func (s *RateLimitService) DeleteEndpointPolicies(ctx context.Context, endpointID uint) error {
+	if err := database.Conn(ctx).Where("endpoint_id = ?", endpointID).Delete(&model.RateLimitPolicy{}).Error; err != nil {
+		return err
+	}
+	database.AfterCommit(ctx, func() {
+		if err := s.Reload(); err != nil {
+			log.Printf("重新加载限流策略失败: %v", err)
+		}
+	})
+	return nil
+}
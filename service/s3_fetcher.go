@@ -30,7 +30,7 @@ func NewS3Fetcher() *S3Fetcher {
 }
 
 // FetchURLs 从S3存储桶获取文件URL列表
-func (sf *S3Fetcher) FetchURLs(s3Config *model.S3Config) ([]string, error) {
+func (sf *S3Fetcher) FetchURLs(ctx context.Context, s3Config *model.S3Config) ([]string, error) {
 	if s3Config == nil {
 		return nil, fmt.Errorf("S3配置不能为空")
 	}
@@ -50,13 +50,13 @@ func (sf *S3Fetcher) FetchURLs(s3Config *model.S3Config) ([]string, error) {
 	}
 
 	// 创建S3客户端
-	client, err := sf.createS3Client(s3Config)
+	client, err := sf.createS3Client(ctx, s3Config)
 	if err != nil {
 		return nil, fmt.Errorf("创建S3客户端失败: %w", err)
 	}
 
 	// 获取对象列表
-	objects, err := sf.listObjects(client, s3Config)
+	objects, err := sf.listObjects(ctx, client, s3Config)
 	if err != nil {
 		return nil, fmt.Errorf("获取对象列表失败: %w", err)
 	}
@@ -69,7 +69,7 @@ func (sf *S3Fetcher) FetchURLs(s3Config *model.S3Config) ([]string, error) {
 }
 
 // createS3Client 创建S3客户端
-func (sf *S3Fetcher) createS3Client(s3Config *model.S3Config) (*s3.Client, error) {
+func (sf *S3Fetcher) createS3Client(ctx context.Context, s3Config *model.S3Config) (*s3.Client, error) {
 	// 设置默认地区
 	region := s3Config.Region
 	if region == "" {
@@ -84,7 +84,7 @@ func (sf *S3Fetcher) createS3Client(s3Config *model.S3Config) (*s3.Client, error
 	)
 
 	// 创建配置
-	cfg, err := config.LoadDefaultConfig(context.TODO(),
+	cfg, err := config.LoadDefaultConfig(ctx,
 		config.WithRegion(region),
 		config.WithCredentialsProvider(creds),
 	)
@@ -105,8 +105,9 @@ func (sf *S3Fetcher) createS3Client(s3Config *model.S3Config) (*s3.Client, error
 }
 
 // listObjects 列出存储桶中的对象
-func (sf *S3Fetcher) listObjects(client *s3.Client, s3Config *model.S3Config) ([]types.Object, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), sf.timeout)
+// 整体耗时受 sf.timeout 与调用方 ctx 共同约束
+func (sf *S3Fetcher) listObjects(ctx context.Context, client *s3.Client, s3Config *model.S3Config) ([]types.Object, error) {
+	ctx, cancel := context.WithTimeout(ctx, sf.timeout)
 	defer cancel()
 
 	var allObjects []types.Object
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"random-api-go/config"
+	"random-api-go/database"
+	"random-api-go/utils"
+)
+
+// 签名链接使用的查询参数
+const (
+	SignedLinkParamExpires = "exp"
+	SignedLinkParamReferer = "ref"
+	SignedLinkParamIP      = "ip"
+	SignedLinkParamSig     = "sig"
+)
+
+// linkSigningSecretKey 签名密钥所在的配置项, 首次使用时自动生成
+const linkSigningSecretKey = "link_signing_secret"
+
+// 签名链接校验失败的原因
+var (
+	ErrSignedLinkInvalid  = errors.New("invalid link signature")
+	ErrSignedLinkExpired  = errors.New("signed link has expired")
+	ErrSignedLinkReferer  = errors.New("signed link is not valid for this referer")
+	ErrSignedLinkIP       = errors.New("signed link is not valid for this IP")
+	ErrSignedLinkRequired = errors.New("signature required")
+)
+
+var linkSecretMu sync.Mutex
+
+// SignedLinkRequest 生成签名链接的参数
+type SignedLinkRequest struct {
+	EndpointID uint   `json:"endpoint_id"`
+	ExpiresIn  int    `json:"expires_in"` // 有效期(秒), 与 expires_at 二选一
+	ExpiresAt  int64  `json:"expires_at"` // 过期时间 (Unix 秒)
+	Referer    string `json:"referer"`    // 可选, 绑定来源域名 (支持 *.example.com)
+	IP         string `json:"ip"`         // 可选, 绑定客户端 IP 或 CIDR
+}
+
+// SignedLink 生成结果
+type SignedLink struct {
+	URL       string    `json:"url"`
+	Path      string    `json:"path"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// getLinkSigningSecret 读取签名密钥, 未配置时生成并保存
+func getLinkSigningSecret() (string, error) {
+	if secret := database.GetConfig(linkSigningSecretKey, ""); secret != "" {
+		return secret, nil
+	}
+
+	linkSecretMu.Lock()
+	defer linkSecretMu.Unlock()
+	if secret := database.GetConfig(linkSigningSecretKey, ""); secret != "" {
+		return secret, nil
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	secret := hex.EncodeToString(buf)
+	if err := database.SetConfig(context.Background(), linkSigningSecretKey, secret, "string"); err != nil {
+		return "", fmt.Errorf("failed to save link signing secret: %w", err)
+	}
+	return secret, nil
+}
+
+// signLink 计算签名: HMAC-SHA256(path \n exp \n ref \n ip), base64url 无填充
+func signLink(secret, path string, exp int64, ref, ip string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strings.Join([]string{"/" + strings.TrimPrefix(path, "/"), strconv.FormatInt(exp, 10), ref, ip}, "\n")))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// MintSignedLink 为端点生成签名链接
+func (s *EndpointService) MintSignedLink(req *SignedLinkRequest) (*SignedLink, error) {
+	endpoint, err := s.GetEndpoint(req.EndpointID)
+	if err != nil {
+		return nil, fmt.Errorf("endpoint not found: %w", err)
+	}
+
+	var expiresAt time.Time
+	switch {
+	case req.ExpiresAt > 0:
+		expiresAt = time.Unix(req.ExpiresAt, 0)
+	case req.ExpiresIn > 0:
+		expiresAt = time.Now().Add(time.Duration(req.ExpiresIn) * time.Second)
+	default:
+		return nil, fmt.Errorf("expires_in or expires_at is required")
+	}
+	if !expiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("expiry must be in the future")
+	}
+
+	ref := strings.ToLower(strings.TrimSpace(req.Referer))
+	ip := strings.TrimSpace(req.IP)
+	if ip != "" {
+		prefix, err := utils.ParsePrefix(ip)
+		if err != nil {
+			return nil, err
+		}
+		ip = prefix.String()
+		if prefix.IsSingleIP() {
+			ip = prefix.Addr().String()
+		}
+	}
+
+	secret, err := getLinkSigningSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	exp := expiresAt.Unix()
+	query := url.Values{}
+	query.Set(SignedLinkParamExpires, strconv.FormatInt(exp, 10))
+	if ref != "" {
+		query.Set(SignedLinkParamReferer, ref)
+	}
+	if ip != "" {
+		query.Set(SignedLinkParamIP, ip)
+	}
+	query.Set(SignedLinkParamSig, signLink(secret, endpoint.URL, exp, ref, ip))
+
+	path := "/" + endpoint.URL + "?" + query.Encode()
+	return &SignedLink{
+		URL:       strings.TrimRight(config.Get().App.BaseURL, "/") + path,
+		Path:      path,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// VerifySignedLink 校验请求携带的签名参数
+// refererDomain 为 ExtractDomain 的结果, clientIP 为 RealIPMiddleware 解析出的地址
+func VerifySignedLink(path string, query url.Values, refererDomain, clientIP string) error {
+	sig := query.Get(SignedLinkParamSig)
+	if sig == "" {
+		return ErrSignedLinkRequired
+	}
+
+	exp, err := strconv.ParseInt(query.Get(SignedLinkParamExpires), 10, 64)
+	if err != nil {
+		return ErrSignedLinkInvalid
+	}
+	ref := query.Get(SignedLinkParamReferer)
+	ip := query.Get(SignedLinkParamIP)
+
+	secret, err := getLinkSigningSecret()
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(sig), []byte(signLink(secret, path, exp, ref, ip))) {
+		return ErrSignedLinkInvalid
+	}
+
+	if time.Now().Unix() >= exp {
+		return ErrSignedLinkExpired
+	}
+	if ref != "" && !matchHostPattern(ref, refererDomain) {
+		return ErrSignedLinkReferer
+	}
+	if ip != "" {
+		prefix, err := utils.ParsePrefix(ip)
+		if err != nil {
+			return ErrSignedLinkInvalid
+		}
+		addr, err := netip.ParseAddr(clientIP)
+		if err != nil || !prefix.Contains(addr.Unmap()) {
+			return ErrSignedLinkIP
+		}
+	}
+	return nil
+}
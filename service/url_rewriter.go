@@ -0,0 +1,206 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"random-api-go/model"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// URL替换规则匹配模式
+const (
+	URLReplaceModeLiteral = "literal" // 子串替换（strings.ReplaceAll）
+	URLReplaceModeRegex   = "regex"   // 正则替换，ToURL 支持 $1 捕获组
+	URLReplaceModePrefix  = "prefix"  // 前缀替换
+	URLReplaceModeHost    = "host"    // 仅替换主机名
+)
+
+// URLRewriteStep 单条规则的执行过程（用于调试替换链）
+type URLRewriteStep struct {
+	RuleID      uint   `json:"rule_id"`
+	Name        string `json:"name"`
+	Global      bool   `json:"global"`
+	Mode        string `json:"mode"`
+	Priority    int    `json:"priority"`
+	Matched     bool   `json:"matched"`
+	Before      string `json:"before"`
+	After       string `json:"after"`
+	StopOnMatch bool   `json:"stop_on_match"`
+	Error       string `json:"error,omitempty"`
+}
+
+// URLRewriter URL替换规则执行器
+type URLRewriter struct {
+	regexMu    sync.RWMutex
+	regexCache map[string]*regexp.Regexp
+}
+
+// NewURLRewriter 创建URL替换规则执行器
+func NewURLRewriter() *URLRewriter {
+	return &URLRewriter{
+		regexCache: make(map[string]*regexp.Regexp),
+	}
+}
+
+// ValidateURLReplaceRule 校验替换规则的模式与表达式
+func ValidateURLReplaceRule(rule *model.URLReplaceRule) error {
+	if rule.Mode == "" {
+		rule.Mode = URLReplaceModeLiteral
+	}
+
+	switch rule.Mode {
+	case URLReplaceModeLiteral, URLReplaceModePrefix:
+		return nil
+	case URLReplaceModeRegex:
+		if _, err := regexp.Compile(rule.FromURL); err != nil {
+			return fmt.Errorf("invalid regex %q: %w", rule.FromURL, err)
+		}
+		return nil
+	case URLReplaceModeHost:
+		if strings.Contains(rule.FromURL, "/") {
+			return fmt.Errorf("host mode expects a bare host name, got %q", rule.FromURL)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported rule mode: %s, supported modes: literal, regex, prefix, host", rule.Mode)
+	}
+}
+
+// sortURLReplaceRules 过滤出启用的规则并按执行顺序排序（Priority 升序，同优先级按 ID 升序）
+func sortURLReplaceRules(rules []model.URLReplaceRule) []model.URLReplaceRule {
+	active := make([]model.URLReplaceRule, 0, len(rules))
+	for _, rule := range rules {
+		if rule.IsActive {
+			active = append(active, rule)
+		}
+	}
+
+	sort.SliceStable(active, func(i, j int) bool {
+		if active[i].Priority != active[j].Priority {
+			return active[i].Priority < active[j].Priority
+		}
+		return active[i].ID < active[j].ID
+	})
+	return active
+}
+
+// Rewrite 按给定顺序应用规则（调用方负责排序，见 URLRuleCache），返回最终URL
+func (rw *URLRewriter) Rewrite(rawURL string, rules []model.URLReplaceRule) string {
+	result, _ := rw.run(rawURL, rules, false)
+	return result
+}
+
+// Trace 按给定顺序应用规则，并返回每条规则的执行过程
+func (rw *URLRewriter) Trace(rawURL string, rules []model.URLReplaceRule) (string, []URLRewriteStep) {
+	return rw.run(rawURL, rules, true)
+}
+
+// run 执行替换链，trace 为 true 时记录每一步
+func (rw *URLRewriter) run(rawURL string, rules []model.URLReplaceRule, trace bool) (string, []URLRewriteStep) {
+	var steps []URLRewriteStep
+	result := rawURL
+
+	for _, rule := range rules {
+		after, matched, err := rw.applyRule(&rule, result)
+
+		if trace {
+			step := URLRewriteStep{
+				RuleID:      rule.ID,
+				Name:        rule.Name,
+				Global:      rule.EndpointID == nil,
+				Mode:        rule.Mode,
+				Priority:    rule.Priority,
+				Matched:     matched,
+				Before:      result,
+				After:       after,
+				StopOnMatch: rule.StopOnMatch,
+			}
+			if err != nil {
+				step.Error = err.Error()
+			}
+			steps = append(steps, step)
+		}
+
+		result = after
+		if matched && rule.StopOnMatch {
+			break
+		}
+	}
+
+	return result, steps
+}
+
+// applyRule 应用单条规则，返回替换结果与是否命中
+// 规则本身有误时原样返回输入，不影响后续规则
+func (rw *URLRewriter) applyRule(rule *model.URLReplaceRule, input string) (string, bool, error) {
+	switch rule.Mode {
+	case "", URLReplaceModeLiteral:
+		if rule.FromURL == "" || !strings.Contains(input, rule.FromURL) {
+			return input, false, nil
+		}
+		return strings.ReplaceAll(input, rule.FromURL, rule.ToURL), true, nil
+
+	case URLReplaceModeRegex:
+		re, err := rw.compile(rule.FromURL)
+		if err != nil {
+			return input, false, err
+		}
+		if !re.MatchString(input) {
+			return input, false, nil
+		}
+		return re.ReplaceAllString(input, rule.ToURL), true, nil
+
+	case URLReplaceModePrefix:
+		if !strings.HasPrefix(input, rule.FromURL) {
+			return input, false, nil
+		}
+		return rule.ToURL + strings.TrimPrefix(input, rule.FromURL), true, nil
+
+	case URLReplaceModeHost:
+		parsed, err := url.Parse(input)
+		if err != nil || parsed.Host == "" {
+			return input, false, nil
+		}
+		if !strings.EqualFold(parsed.Host, rule.FromURL) && !strings.EqualFold(parsed.Hostname(), rule.FromURL) {
+			return input, false, nil
+		}
+		// ToURL 可带协议（如 https://cdn.example.com），此时同时替换协议
+		if strings.Contains(rule.ToURL, "://") {
+			target, err := url.Parse(rule.ToURL)
+			if err != nil {
+				return input, false, fmt.Errorf("invalid target %q: %w", rule.ToURL, err)
+			}
+			parsed.Scheme = target.Scheme
+			parsed.Host = target.Host
+		} else {
+			parsed.Host = rule.ToURL
+		}
+		return parsed.String(), true, nil
+
+	default:
+		return input, false, fmt.Errorf("unsupported rule mode: %s", rule.Mode)
+	}
+}
+
+// compile 编译正则并缓存
+func (rw *URLRewriter) compile(pattern string) (*regexp.Regexp, error) {
+	rw.regexMu.RLock()
+	re, ok := rw.regexCache[pattern]
+	rw.regexMu.RUnlock()
+	if ok {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	rw.regexMu.Lock()
+	rw.regexCache[pattern] = re
+	rw.regexMu.Unlock()
+	return re, nil
+}
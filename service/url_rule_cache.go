@@ -0,0 +1,148 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"random-api-go/database"
+	"random-api-go/model"
+	"sync"
+	"time"
+)
+
+// urlRuleCacheRetryInterval 加载失败后的重试间隔, 期间不再查询数据库
+const urlRuleCacheRetryInterval = 5 * time.Second
+
+// 全局规则与端点规则的执行顺序（配置 url_replace_global_order）
+const (
+	URLReplaceGlobalBefore = "before" // 先执行全局规则，再执行端点规则（默认）
+	URLReplaceGlobalAfter  = "after"  // 先执行端点规则，再执行全局规则
+)
+
+// URLRuleCache URL替换规则内存缓存
+// 首次使用时从数据库加载全部启用的规则，规则增删改后整体失效重新加载，
+// 避免每次随机请求都查询数据库；重新加载失败时继续使用上次加载成功的规则，
+// 并在 urlRuleCacheRetryInterval 之后才重试
+type URLRuleCache struct {
+	mu         sync.RWMutex
+	loaded     bool
+	snapshot   bool                            // global / byEndpoint 是否来自一次成功的加载
+	retryAt    time.Time                       // 加载失败后，在此之前不再重试
+	loadErr    error                           // 最近一次加载失败的原因
+	global     []model.URLReplaceRule          // EndpointID 为空的全局规则（已排序）
+	byEndpoint map[uint][]model.URLReplaceRule // 端点规则（已排序）
+}
+
+// NewURLRuleCache 创建URL替换规则缓存
+func NewURLRuleCache() *URLRuleCache {
+	return &URLRuleCache{
+		byEndpoint: make(map[uint][]model.URLReplaceRule),
+	}
+}
+
+// Rules 获取端点的完整替换链（全局规则 + 端点规则，按配置的先后顺序拼接）
+func (c *URLRuleCache) Rules(endpointID uint) []model.URLReplaceRule {
+	if err := c.ensureLoaded(); err != nil {
+		log.Printf("加载URL替换规则失败: %v", err)
+		return nil
+	}
+
+	c.mu.RLock()
+	global := c.global
+	own := c.byEndpoint[endpointID]
+	c.mu.RUnlock()
+
+	rules := make([]model.URLReplaceRule, 0, len(global)+len(own))
+	if urlReplaceGlobalOrder() == URLReplaceGlobalAfter {
+		rules = append(rules, own...)
+		return append(rules, global...)
+	}
+	rules = append(rules, global...)
+	return append(rules, own...)
+}
+
+// EndpointRules 仅获取端点自身的规则（不含全局规则）
+func (c *URLRuleCache) EndpointRules(endpointID uint) []model.URLReplaceRule {
+	if err := c.ensureLoaded(); err != nil {
+		log.Printf("加载URL替换规则失败: %v", err)
+		return nil
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.byEndpoint[endpointID]
+}
+
+// Invalidate 使缓存失效，下次使用时重新加载（不受失败重试间隔限制）
+func (c *URLRuleCache) Invalidate() {
+	c.mu.Lock()
+	c.loaded = false
+	c.retryAt = time.Time{}
+	c.mu.Unlock()
+}
+
+// ensureLoaded 缓存失效时从数据库重新加载
+// 加载失败时有旧规则则继续使用旧规则，否则返回错误；重试间隔内直接沿用上次的结果
+func (c *URLRuleCache) ensureLoaded() error {
+	c.mu.RLock()
+	loaded := c.loaded
+	c.mu.RUnlock()
+	if loaded {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.loaded {
+		return nil
+	}
+	if time.Now().Before(c.retryAt) {
+		return c.staleResult()
+	}
+
+	var rules []model.URLReplaceRule
+	if err := database.DB.Where("is_active = ?", true).Find(&rules).Error; err != nil {
+		c.loadErr = fmt.Errorf("failed to load URL replace rules: %w", err)
+		c.retryAt = time.Now().Add(urlRuleCacheRetryInterval)
+		if c.snapshot {
+			log.Printf("重新加载URL替换规则失败, 继续使用上次加载的规则: %v", err)
+		}
+		return c.staleResult()
+	}
+
+	var global []model.URLReplaceRule
+	grouped := make(map[uint][]model.URLReplaceRule)
+	for _, rule := range rules {
+		if rule.EndpointID == nil {
+			global = append(global, rule)
+		} else {
+			grouped[*rule.EndpointID] = append(grouped[*rule.EndpointID], rule)
+		}
+	}
+
+	c.global = sortURLReplaceRules(global)
+	c.byEndpoint = make(map[uint][]model.URLReplaceRule, len(grouped))
+	for endpointID, endpointRules := range grouped {
+		c.byEndpoint[endpointID] = sortURLReplaceRules(endpointRules)
+	}
+	c.loaded = true
+	c.snapshot = true
+	c.retryAt = time.Time{}
+	c.loadErr = nil
+	return nil
+}
+
+// staleResult 加载失败时的结果: 有上次加载成功的规则时沿用, 否则返回失败原因 (调用方需持有锁)
+func (c *URLRuleCache) staleResult() error {
+	if c.snapshot {
+		return nil
+	}
+	return c.loadErr
+}
+
+// urlReplaceGlobalOrder 读取全局规则执行顺序配置
+func urlReplaceGlobalOrder() string {
+	if database.GetConfig("url_replace_global_order", URLReplaceGlobalBefore) == URLReplaceGlobalAfter {
+		return URLReplaceGlobalAfter
+	}
+	return URLReplaceGlobalBefore
+}
@@ -0,0 +1,253 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/url"
+	"random-api-go/database"
+	"random-api-go/model"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CDN URL签名算法
+const (
+	URLSignTypeA     = "type_a"     // ?auth_key={timestamp}-{rand}-{uid}-md5("{path}-{timestamp}-{rand}-{uid}-{key}")
+	URLSignTypeB     = "type_b"     // /{YYYYMMDDHHMM}/md5("{key}{YYYYMMDDHHMM}{path}"){path}
+	URLSignTypeC     = "type_c"     // /md5("{key}{path}{hexTimestamp}")/{hexTimestamp}{path}
+	URLSignHMACQuery = "hmac_query" // ?expires={unix}&token=hex(hmac_sha256(key, "{path}{expires}"))
+)
+
+// 签名参数默认值
+const (
+	defaultTypeAParam     = "auth_key"
+	defaultHMACQueryParam = "token"
+	defaultURLSignTTL     = 3600
+)
+
+// typeBLocation type_b 时间戳所用时区 (UTC+8)
+var typeBLocation = time.FixedZone("CST", 8*3600)
+
+var supportedURLSignAlgorithms = []string{URLSignTypeA, URLSignTypeB, URLSignTypeC, URLSignHMACQuery}
+
+// ValidateURLSigner 校验签名配置, hmac_query 未填写 TTL 时补上默认值
+func ValidateURLSigner(signer *model.URLSigner) error {
+	supported := false
+	for _, algorithm := range supportedURLSignAlgorithms {
+		if signer.Algorithm == algorithm {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return fmt.Errorf("unsupported sign algorithm: %s, supported algorithms: %v", signer.Algorithm, supportedURLSignAlgorithms)
+	}
+	if signer.Secret == "" {
+		return fmt.Errorf("secret is required")
+	}
+	if signer.TTL < 0 {
+		return fmt.Errorf("ttl must not be negative")
+	}
+	// type_a/b/c 只写入签名时间, 有效期由 CDN 侧配置, 设置 TTL 不会生效
+	if signer.Algorithm != URLSignHMACQuery && signer.TTL != 0 {
+		return fmt.Errorf("ttl only applies to %s, the validity of %s signatures is configured on the CDN", URLSignHMACQuery, signer.Algorithm)
+	}
+	if signer.Algorithm == URLSignHMACQuery && signer.TTL == 0 {
+		signer.TTL = defaultURLSignTTL
+	}
+	return nil
+}
+
+// MaskURLSignerSecret 隐藏签名密钥, 密钥只在创建时返回一次
+func MaskURLSignerSecret(signer *model.URLSigner) {
+	if signer.Secret != "" {
+		signer.Secret = auditMask
+	}
+}
+
+// IsMaskedURLSignerSecret 判断提交的密钥是否为隐藏后的占位符 (更新时沿用原密钥)
+func IsMaskedURLSignerSecret(secret string) bool {
+	return secret == auditMask
+}
+
+// URLSignerService CDN URL签名服务
+// 签名配置缓存在内存中，配置增删改后整体失效重新加载
+type URLSignerService struct {
+	mu         sync.RWMutex
+	loaded     bool
+	global     []model.URLSigner
+	byEndpoint map[uint][]model.URLSigner
+}
+
+// NewURLSignerService 创建URL签名服务
+func NewURLSignerService() *URLSignerService {
+	return &URLSignerService{
+		byEndpoint: make(map[uint][]model.URLSigner),
+	}
+}
+
+// Sign 对端点返回的最终URL签名
+// 端点自身的签名配置优先，其次全局配置；只使用第一个匹配主机的配置，非 http(s) URL 原样返回
+func (s *URLSignerService) Sign(rawURL string, endpointID uint) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return rawURL
+	}
+
+	signer := s.match(endpointID, parsed.Host)
+	if signer == nil {
+		return rawURL
+	}
+
+	signed, err := signURL(parsed, signer, time.Now())
+	if err != nil {
+		log.Printf("URL签名失败 (signer %d): %v", signer.ID, err)
+		return rawURL
+	}
+	return signed
+}
+
+// Invalidate 使缓存失效，下次使用时重新加载
+func (s *URLSignerService) Invalidate() {
+	s.mu.Lock()
+	s.loaded = false
+	s.mu.Unlock()
+}
+
+// match 查找适用于该主机的签名配置
+func (s *URLSignerService) match(endpointID uint, host string) *model.URLSigner {
+	if err := s.ensureLoaded(); err != nil {
+		log.Printf("加载URL签名配置失败: %v", err)
+		return nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, group := range [][]model.URLSigner{s.byEndpoint[endpointID], s.global} {
+		for i := range group {
+			if signerMatchesHost(&group[i], host) {
+				signer := group[i]
+				return &signer
+			}
+		}
+	}
+	return nil
+}
+
+// signerMatchesHost 判断签名配置是否作用于该主机（HostPattern 支持逗号分隔多个模式）
+func signerMatchesHost(signer *model.URLSigner, host string) bool {
+	if strings.TrimSpace(signer.HostPattern) == "" {
+		return true
+	}
+	return matchAnyHostPattern(strings.Split(signer.HostPattern, ","), host)
+}
+
+// ensureLoaded 缓存失效时从数据库重新加载
+func (s *URLSignerService) ensureLoaded() error {
+	s.mu.RLock()
+	loaded := s.loaded
+	s.mu.RUnlock()
+	if loaded {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.loaded {
+		return nil
+	}
+
+	var signers []model.URLSigner
+	if err := database.DB.Where("is_active = ?", true).Order("id ASC").Find(&signers).Error; err != nil {
+		return fmt.Errorf("failed to load URL signers: %w", err)
+	}
+
+	s.global = nil
+	s.byEndpoint = make(map[uint][]model.URLSigner)
+	for _, signer := range signers {
+		if signer.EndpointID == nil {
+			s.global = append(s.global, signer)
+		} else {
+			s.byEndpoint[*signer.EndpointID] = append(s.byEndpoint[*signer.EndpointID], signer)
+		}
+	}
+	s.loaded = true
+	return nil
+}
+
+// signURL 按算法生成签名URL
+// type_a/b/c 写入的是签名时间，有效期由CDN侧配置；hmac_query 按 TTL 写入过期时间
+func signURL(u *url.URL, signer *model.URLSigner, now time.Time) (string, error) {
+	signed := *u
+	uri := u.EscapedPath()
+	if uri == "" {
+		uri = "/"
+	}
+
+	switch signer.Algorithm {
+	case URLSignTypeA:
+		// rand 与 uid 不使用时固定为 0
+		timestamp := strconv.FormatInt(now.Unix(), 10)
+		hash := md5Hex(fmt.Sprintf("%s-%s-0-0-%s", uri, timestamp, signer.Secret))
+		signed.RawQuery = appendQuery(u.RawQuery, paramNameOrDefault(signer.ParamName, defaultTypeAParam), fmt.Sprintf("%s-0-0-%s", timestamp, hash))
+
+	case URLSignTypeB:
+		// 国内CDN按北京时间解析 type_b 时间戳
+		timestamp := now.In(typeBLocation).Format("200601021504")
+		hash := md5Hex(signer.Secret + timestamp + uri)
+		signed.Path = "/" + timestamp + "/" + hash + u.Path
+		signed.RawPath = ""
+
+	case URLSignTypeC:
+		timestamp := strconv.FormatInt(now.Unix(), 16)
+		hash := md5Hex(signer.Secret + uri + timestamp)
+		signed.Path = "/" + hash + "/" + timestamp + u.Path
+		signed.RawPath = ""
+
+	case URLSignHMACQuery:
+		ttl := signer.TTL
+		if ttl <= 0 {
+			ttl = defaultURLSignTTL
+		}
+		expires := strconv.FormatInt(now.Add(time.Duration(ttl)*time.Second).Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(signer.Secret))
+		mac.Write([]byte(uri + expires))
+		query := appendQuery(u.RawQuery, "expires", expires)
+		signed.RawQuery = appendQuery(query, paramNameOrDefault(signer.ParamName, defaultHMACQueryParam), hex.EncodeToString(mac.Sum(nil)))
+
+	default:
+		return "", fmt.Errorf("unsupported sign algorithm: %s", signer.Algorithm)
+	}
+
+	return signed.String(), nil
+}
+
+// md5Hex 计算MD5十六进制摘要
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// appendQuery 在原查询串末尾追加参数，保持原有参数顺序不变
+func appendQuery(rawQuery, key, value string) string {
+	param := url.QueryEscape(key) + "=" + url.QueryEscape(value)
+	if rawQuery == "" {
+		return param
+	}
+	return rawQuery + "&" + param
+}
+
+// paramNameOrDefault 签名参数名为空时使用默认值
+func paramNameOrDefault(name, defaultName string) string {
+	if name == "" {
+		return defaultName
+	}
+	return name
+}
@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// CIDRTrie 按位组织的前缀树 (radix-2), 用于 IP/CIDR 的最长前缀匹配
+// IPv4 与 IPv6 分别使用独立的根节点, IPv4-mapped IPv6 地址按 IPv4 处理
+// 非并发安全: 构建完成后只读使用, 更新时整体替换
+type CIDRTrie[V any] struct {
+	v4   *cidrNode[V]
+	v6   *cidrNode[V]
+	size int
+}
+
+type cidrNode[V any] struct {
+	children [2]*cidrNode[V]
+	prefix   netip.Prefix
+	value    V
+	set      bool
+}
+
+// NewCIDRTrie 创建空的前缀树
+func NewCIDRTrie[V any]() *CIDRTrie[V] {
+	return &CIDRTrie[V]{v4: &cidrNode[V]{}, v6: &cidrNode[V]{}}
+}
+
+// ParsePrefix 解析 IP 或 CIDR, 单个 IP 视为 /32 或 /128, 返回规范化 (主机位清零) 的前缀
+func ParsePrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid CIDR %q: %w", s, err)
+		}
+		if p.Addr().Is4In6() {
+			p = netip.PrefixFrom(p.Addr().Unmap(), max(p.Bits()-96, 0))
+		}
+		return p.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid IP %q: %w", s, err)
+	}
+	addr = addr.Unmap().WithZone("")
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// Insert 插入前缀, 相同前缀重复插入时覆盖旧值
+func (t *CIDRTrie[V]) Insert(prefix netip.Prefix, value V) {
+	prefix = prefix.Masked()
+	addr := prefix.Addr()
+	node := t.root(addr)
+	for i := 0; i < prefix.Bits(); i++ {
+		b := bitAt(addr, i)
+		if node.children[b] == nil {
+			node.children[b] = &cidrNode[V]{}
+		}
+		node = node.children[b]
+	}
+	if !node.set {
+		t.size++
+	}
+	node.prefix = prefix
+	node.value = value
+	node.set = true
+}
+
+// Get 精确查找前缀 (不做最长前缀匹配)
+func (t *CIDRTrie[V]) Get(prefix netip.Prefix) (V, bool) {
+	var value V
+	prefix = prefix.Masked()
+	addr := prefix.Addr()
+	node := t.root(addr)
+	for i := 0; i < prefix.Bits() && node != nil; i++ {
+		node = node.children[bitAt(addr, i)]
+	}
+	if node == nil || !node.set {
+		return value, false
+	}
+	return node.value, true
+}
+
+// Lookup 查找包含该地址的最长前缀
+func (t *CIDRTrie[V]) Lookup(addr netip.Addr) (V, netip.Prefix, bool) {
+	var (
+		value V
+		match netip.Prefix
+		found bool
+	)
+	if !addr.IsValid() {
+		return value, match, false
+	}
+	addr = addr.Unmap().WithZone("")
+	node := t.root(addr)
+	for i := 0; node != nil; i++ {
+		if node.set {
+			value, match, found = node.value, node.prefix, true
+		}
+		if i >= addr.BitLen() {
+			break
+		}
+		node = node.children[bitAt(addr, i)]
+	}
+	return value, match, found
+}
+
+// Contains 判断地址是否落在任一前缀内
+func (t *CIDRTrie[V]) Contains(addr netip.Addr) bool {
+	_, _, ok := t.Lookup(addr)
+	return ok
+}
+
+// Len 返回前缀数量
+func (t *CIDRTrie[V]) Len() int {
+	return t.size
+}
+
+func (t *CIDRTrie[V]) root(addr netip.Addr) *cidrNode[V] {
+	if addr.Is4() {
+		return t.v4
+	}
+	return t.v6
+}
+
+// bitAt 取地址第 i 位 (从最高位开始)
+func bitAt(addr netip.Addr, i int) int {
+	if addr.Is4() {
+		b := addr.As4()
+		return int(b[i/8]>>(7-uint(i%8))) & 1
+	}
+	b := addr.As16()
+	return int(b[i/8]>>(7-uint(i%8))) & 1
+}
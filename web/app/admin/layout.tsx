@@ -6,19 +6,21 @@ import { Button } from '@/components/ui/button'
 import { Sheet, SheetContent, SheetTrigger } from '@/components/ui/sheet'
 import LoginPage from '@/components/admin/LoginPage'
 import {
-  getUserInfo,
-  clearAuthInfo,
-  isAuthenticated,
+  fetchCurrentUser,
+  hasRole,
+  logout,
+  type AdminRole,
   type AuthUser
 } from '@/lib/auth'
 import Link from 'next/link'
 import { Menu } from 'lucide-react'
 
-const navItems = [
-  { key: 'endpoints', label: 'API端点', href: '/admin' },
-  { key: 'rules', label: 'URL替换规则', href: '/admin/rules' },
-  { key: 'home', label: '首页配置', href: '/admin/home' },
-  { key: 'stats', label: '域名统计', href: '/admin/stats' },
+// role: 可见该菜单所需的最低角色
+const allNavItems: { key: string; label: string; href: string; role: AdminRole }[] = [
+  { key: 'endpoints', label: 'API端点', href: '/admin', role: 'editor' },
+  { key: 'rules', label: 'URL替换规则', href: '/admin/rules', role: 'admin' },
+  { key: 'home', label: '首页配置', href: '/admin/home', role: 'admin' },
+  { key: 'stats', label: '域名统计', href: '/admin/stats', role: 'viewer' },
 ]
 
 export default function AdminLayout({
@@ -37,30 +39,33 @@ export default function AdminLayout({
   }, [])
 
   const checkAuth = async () => {
-    if (!isAuthenticated()) {
-      setLoading(false)
-      return
-    }
-
-    const savedUser = getUserInfo()
-    if (savedUser) {
-      setUser(savedUser)
-      setLoading(false)
-      return
-    }
-
-    // 如果没有用户信息，清除认证状态
-    clearAuthInfo()
+    // 会话 cookie 为 HttpOnly, 由后端确认是否已登录
+    const currentUser = await fetchCurrentUser()
+    setUser(currentUser)
     setLoading(false)
   }
 
-  const handleLoginSuccess = (userInfo: AuthUser) => {
-    setUser(userInfo)
+  const navItems = allNavItems.filter((item) => hasRole(user, item.role))
+
+  // 当前页面超出角色权限时跳转到第一个可访问的页面
+  useEffect(() => {
+    if (!user || navItems.length === 0) return
+    if (!navItems.some((item) => item.href === pathname)) {
+      router.replace(navItems[0].href)
+    }
+  }, [user, pathname])
+
+  const handleLoginSuccess = async (userInfo: AuthUser) => {
+    // 登录接口不返回角色, 从 /api/admin/me 获取完整信息
+    setUser((await fetchCurrentUser()) || userInfo)
     setLoading(false)
   }
 
-  const handleLogout = () => {
-    clearAuthInfo()
+  const handleLogout = async (everywhere = false) => {
+    if (everywhere && !confirm('确定要退出所有设备上的登录吗？')) {
+      return
+    }
+    await logout(everywhere)
     setUser(null)
     router.push('/admin')
   }
@@ -115,13 +120,21 @@ export default function AdminLayout({
                 欢迎, {user.name}
               </span>
               <Button
-                onClick={handleLogout}
+                onClick={() => handleLogout()}
                 variant="ghost"
                 size="sm"
                 className="text-red-600 hover:text-red-700"
               >
                 退出登录
               </Button>
+              <Button
+                onClick={() => handleLogout(true)}
+                variant="ghost"
+                size="sm"
+                className="text-muted-foreground"
+              >
+                退出所有设备
+              </Button>
             </div>
 
             {/* Mobile Menu Button */}
@@ -182,6 +195,16 @@ export default function AdminLayout({
                       >
                         退出登录
                       </Button>
+                      <Button
+                        onClick={() => {
+                          handleLogout(true)
+                          setMobileMenuOpen(false)
+                        }}
+                        variant="ghost"
+                        className="w-full justify-start text-muted-foreground"
+                      >
+                        退出所有设备
+                      </Button>
                     </div>
                   </div>
                 </SheetContent>
@@ -2,26 +2,14 @@
 
 import { useState, useEffect } from 'react'
 import { Button } from '@/components/ui/button'
+import { Input } from '@/components/ui/input'
+import { Label } from '@/components/ui/label'
 import { 
   saveAuthInfo, 
-  saveOAuthState,
-  getOAuthState,
-  clearOAuthState,
   type AuthUser
 } from '@/lib/auth'
 import type { OAuthConfig } from '@/types/admin'
 
-// OAuth2.0 端点配置
-const OAUTH_ENDPOINTS = {
-  authorizeUrl: 'https://connect.czl.net/oauth2/authorize',
-  tokenUrl: 'https://connect.czl.net/api/oauth2/token',
-  userInfoUrl: 'https://connect.czl.net/api/oauth2/userinfo',
-  // 使用配置的BASE_URL构建回调地址
-  getRedirectUri: (baseUrl: string) => {
-    return `${baseUrl}/api/admin/oauth/callback`
-  }
-}
-
 interface LoginPageProps {
   onLoginSuccess: (user: AuthUser) => void
 }
@@ -29,55 +17,28 @@ interface LoginPageProps {
 export default function LoginPage({ onLoginSuccess }: LoginPageProps) {
   const [loading, setLoading] = useState(true)
   const [oauthConfig, setOauthConfig] = useState<OAuthConfig | null>(null)
+  const [username, setUsername] = useState('')
+  const [password, setPassword] = useState('')
+  const [totpCode, setTotpCode] = useState('')
+  const [totpRequired, setTotpRequired] = useState(false)
+  const [submitting, setSubmitting] = useState(false)
 
   useEffect(() => {
-    // 首先检查URL参数中是否有token
+    // 首先检查URL参数中的登录错误
     checkURLParams()
     loadOAuthConfig()
   }, [])
 
   const checkURLParams = () => {
+    // 登录成功时后端已写入 HttpOnly 会话 cookie 并跳转回 /admin, 这里只处理失败
     const urlParams = new URLSearchParams(window.location.search)
-    const token = urlParams.get('token')
     const error = urlParams.get('error')
-    const userName = urlParams.get('user')
-    const state = urlParams.get('state')
 
     if (error) {
       alert(`登录失败: ${error}`)
       // 清理URL参数
       window.history.replaceState({}, document.title, window.location.pathname)
-      return
-    }
-
-    if (token) {
-      // 验证state参数防止CSRF攻击（如果存在的话）
-      if (state) {
-        const savedState = getOAuthState()
-        if (savedState !== state) {
-          alert('登录状态验证失败，请重新登录')
-          clearOAuthState()
-          window.history.replaceState({}, document.title, window.location.pathname)
-          return
-        }
-      } else {
-        console.warn('OAuth回调缺少state参数，可能存在安全风险')
-      }
-
-      // 保存认证信息
-      if (userName) {
-        const userInfo: AuthUser = { id: '', name: userName, email: '' }
-        saveAuthInfo(token, userInfo)
-        onLoginSuccess(userInfo)
-      }
-      
-      // 清理URL参数和OAuth状态
-      clearOAuthState()
-      window.history.replaceState({}, document.title, window.location.pathname)
-      return
     }
-
-    setLoading(false)
   }
 
   const loadOAuthConfig = async () => {
@@ -110,24 +71,42 @@ export default function LoginPage({ onLoginSuccess }: LoginPageProps) {
   }
 
   const handleLogin = () => {
-    if (!oauthConfig) {
+    if (!oauthConfig?.login_url) {
       alert('OAuth配置未加载')
       return
     }
 
-    // 生成随机state值防止CSRF攻击
-    const state = Math.random().toString(36).substring(2, 15) + Math.random().toString(36).substring(2, 15)
-    saveOAuthState(state)
+    // 由后端生成 state / PKCE 参数并跳转到登录提供方
+    window.location.href = oauthConfig.login_url
+  }
 
-    const params = new URLSearchParams({
-      client_id: oauthConfig.client_id,
-      redirect_uri: OAUTH_ENDPOINTS.getRedirectUri(oauthConfig.base_url), // 使用配置的BASE_URL
-      response_type: 'code',
-      scope: 'read write', // 根据CZL Connect文档使用正确的scope
-      state: state,
-    })
-    
-    window.location.href = `${OAUTH_ENDPOINTS.authorizeUrl}?${params.toString()}`
+  const handleLocalLogin = async (e: React.FormEvent) => {
+    e.preventDefault()
+    setSubmitting(true)
+    try {
+      const response = await fetch('/api/admin/local/login', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ username, password, totp_code: totpCode }),
+      })
+      const data = await response.json()
+      if (response.ok && data.success) {
+        const userInfo: AuthUser = { id: data.data.user_info.id, name: data.data.user_info.username, email: '' }
+        saveAuthInfo(userInfo)
+        onLoginSuccess(userInfo)
+        return
+      }
+      if (data.totp_required) {
+        setTotpRequired(true)
+      }
+      if (!data.totp_required || totpCode) {
+        alert(`登录失败: ${data.error}`)
+      }
+    } catch (error) {
+      alert(`网络错误: ${error instanceof Error ? error.message : '未知错误'}`)
+    } finally {
+      setSubmitting(false)
+    }
   }
 
   if (loading) {
@@ -151,18 +130,47 @@ export default function LoginPage({ onLoginSuccess }: LoginPageProps) {
             管理后台登录
           </h1>
           <p className="text-muted-foreground">
-            请使用 CZL Connect 账号登录
+            {oauthConfig?.oauth_enabled
+              ? `请使用 ${oauthConfig.provider_name || 'CZL Connect'} 账号登录`
+              : '请使用本地管理员账号登录'}
           </p>
         </div>
         
-        <Button
-          onClick={handleLogin}
-          className="w-full"
-          size="lg"
-          disabled={!oauthConfig}
-        >
-          {oauthConfig ? '使用 CZL Connect 登录' : '加载中...'}
-        </Button>
+        {oauthConfig?.oauth_enabled !== false && (
+          <Button
+            onClick={handleLogin}
+            className="w-full"
+            size="lg"
+            disabled={!oauthConfig}
+          >
+            {oauthConfig ? `使用 ${oauthConfig.provider_name} 登录` : '加载中...'}
+          </Button>
+        )}
+
+        {oauthConfig?.local_login && (
+          <form onSubmit={handleLocalLogin} className="space-y-4 mt-6">
+            {oauthConfig.oauth_enabled && (
+              <p className="text-center text-sm text-muted-foreground">或使用本地账号</p>
+            )}
+            <div className="space-y-2">
+              <Label htmlFor="username">用户名</Label>
+              <Input id="username" value={username} onChange={(e) => setUsername(e.target.value)} autoComplete="username" required />
+            </div>
+            <div className="space-y-2">
+              <Label htmlFor="password">密码</Label>
+              <Input id="password" type="password" value={password} onChange={(e) => setPassword(e.target.value)} autoComplete="current-password" required />
+            </div>
+            {totpRequired && (
+              <div className="space-y-2">
+                <Label htmlFor="totp">二次验证码</Label>
+                <Input id="totp" inputMode="numeric" value={totpCode} onChange={(e) => setTotpCode(e.target.value)} autoComplete="one-time-code" autoFocus />
+              </div>
+            )}
+            <Button type="submit" variant="outline" className="w-full" disabled={submitting}>
+              {submitting ? '登录中...' : '登录'}
+            </Button>
+          </form>
+        )}
       </div>
     </div>
   )
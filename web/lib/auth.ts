@@ -1,6 +1,6 @@
 import Cookies from 'js-cookie'
 
-const TOKEN_COOKIE_NAME = 'admin_token'
+// 会话令牌保存在后端设置的 HttpOnly cookie 中, 前端只缓存用于显示的用户信息
 const USER_INFO_COOKIE_NAME = 'admin_user'
 
 // Cookie配置
@@ -11,21 +11,26 @@ const COOKIE_OPTIONS = {
   path: '/'
 }
 
+export type AdminRole = 'viewer' | 'editor' | 'admin'
+
 export interface AuthUser {
   id: string
   name: string
   email: string
+  role?: AdminRole
 }
 
-// 保存认证信息
-export function saveAuthInfo(token: string, user: AuthUser) {
-  Cookies.set(TOKEN_COOKIE_NAME, token, COOKIE_OPTIONS)
-  Cookies.set(USER_INFO_COOKIE_NAME, JSON.stringify(user), COOKIE_OPTIONS)
+const ROLE_LEVELS: Record<AdminRole, number> = { viewer: 1, editor: 2, admin: 3 }
+
+// 是否具备指定角色 (高角色包含低角色的权限)
+export function hasRole(user: AuthUser | null, role: AdminRole): boolean {
+  if (!user?.role) return false
+  return ROLE_LEVELS[user.role] >= ROLE_LEVELS[role]
 }
 
-// 获取访问令牌
-export function getAccessToken(): string | null {
-  return Cookies.get(TOKEN_COOKIE_NAME) || null
+// 保存用户信息
+export function saveAuthInfo(user: AuthUser) {
+  Cookies.set(USER_INFO_COOKIE_NAME, JSON.stringify(user), COOKIE_OPTIONS)
 }
 
 // 获取用户信息
@@ -42,34 +47,58 @@ export function getUserInfo(): AuthUser | null {
 
 // 清除认证信息
 export function clearAuthInfo() {
-  Cookies.remove(TOKEN_COOKIE_NAME, { path: '/' })
   Cookies.remove(USER_INFO_COOKIE_NAME, { path: '/' })
 }
 
-// 检查是否已登录
-export function isAuthenticated(): boolean {
-  return !!getAccessToken()
+// 向后端确认会话是否有效, 有效时返回当前用户
+export async function fetchCurrentUser(): Promise<AuthUser | null> {
+  try {
+    const response = await fetch('/api/admin/me', { credentials: 'same-origin' })
+    if (!response.ok) {
+      clearAuthInfo()
+      return null
+    }
+    const data = await response.json()
+    const info = data.data.user_info
+    const user: AuthUser = {
+      id: info.id,
+      name: info.nickname || info.username,
+      email: info.email || '',
+      role: data.data.access?.role,
+    }
+    saveAuthInfo(user)
+    return user
+  } catch {
+    return getUserInfo()
+  }
+}
+
+// 退出登录; everywhere 为 true 时注销该账号在所有设备上的会话
+export async function logout(everywhere = false) {
+  try {
+    await fetch(everywhere ? '/api/admin/logout-all' : '/api/admin/logout', {
+      method: 'POST',
+      credentials: 'same-origin',
+    })
+  } finally {
+    clearAuthInfo()
+  }
 }
 
-// 创建带认证的fetch请求
+// 创建带认证的fetch请求 (会话 cookie 由浏览器自动携带)
 export async function authenticatedFetch(url: string, options: RequestInit = {}): Promise<Response> {
-  const token = getAccessToken()
-  
   const headers: Record<string, string> = {
     'Content-Type': 'application/json',
     ...(options.headers as Record<string, string> || {}),
   }
   
-  if (token) {
-    headers['Authorization'] = `Bearer ${token}`
-  }
-  
   const response = await fetch(url, {
     ...options,
     headers,
+    credentials: 'same-origin',
   })
   
-  // 如果token过期或无效，清除认证信息并重定向到登录
+  // 如果会话过期或无效，清除认证信息并重定向到登录
   if (response.status === 401) {
     clearAuthInfo()
     // 可以选择重定向到登录页面或显示登录提示
@@ -80,16 +109,3 @@ export async function authenticatedFetch(url: string, options: RequestInit = {})
   
   return response
 }
-
-// OAuth状态管理
-export function saveOAuthState(state: string) {
-  sessionStorage.setItem('oauth_state', state)
-}
-
-export function getOAuthState(): string | null {
-  return sessionStorage.getItem('oauth_state')
-}
-
-export function clearOAuthState() {
-  sessionStorage.removeItem('oauth_state')
-} 
\ No newline at end of file
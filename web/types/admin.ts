@@ -12,6 +12,8 @@ export interface APIEndpoint {
   is_active: boolean
   show_on_homepage: boolean
   sort_order: number
+  request_timeout?: number
+  managed?: boolean
   created_at: string
   updated_at: string
   data_sources?: DataSource[]
@@ -24,6 +26,7 @@ export interface DataSource {
   type: 'lankong' | 'manual' | 'api_get' | 'api_post' | 'endpoint' | 's3'
   config: string
   is_active: boolean
+  managed?: boolean
   last_sync?: string
   created_at: string
   updated_at: string
@@ -33,8 +36,27 @@ export interface URLReplaceRule {
   id: number
   endpoint_id?: number
   name: string
+  mode?: 'literal' | 'regex' | 'prefix' | 'host'
   from_url: string
   to_url: string
+  priority?: number
+  stop_on_match?: boolean
+  is_active: boolean
+  managed?: boolean
+  created_at: string
+  updated_at: string
+  endpoint?: APIEndpoint
+}
+
+export interface URLSigner {
+  id: number
+  endpoint_id?: number
+  name: string
+  algorithm: 'type_a' | 'type_b' | 'type_c' | 'hmac_query'
+  secret: string
+  ttl: number
+  param_name?: string
+  host_pattern?: string
   is_active: boolean
   created_at: string
   updated_at: string
@@ -42,8 +64,14 @@ export interface URLReplaceRule {
 }
 
 export interface OAuthConfig {
-  client_id: string
-  base_url: string
+  client_id?: string
+  base_url?: string
+  provider?: string
+  provider_name?: string
+  login_url?: string
+  oauth_enabled: boolean
+  local_login: boolean
+  oauth_error?: string
 }
 
 export interface DomainStatsResult {
@@ -83,7 +111,232 @@ export interface DomainTrendData {
 export interface BlockedDomain {
   id: number
   domain: string
+  match_type: 'exact' | 'suffix' | 'etld1' | 'regex'
+  path: string
   reason: string
+  source: 'manual' | 'auto'
+  managed?: boolean
+  expires_at?: string
+  hit_count: number
+  last_hit_at?: string
+  created_at: string
+  updated_at: string
+}
+
+export interface AbuseEvent {
+  id: number
+  domain: string
+  rule: 'rpm' | 'daily' | 'share' | 'new_domain_spike'
+  value: number
+  threshold: number
+  reason: string
+  blocked_until: string
+  created_at: string
+}
+
+export interface EndpointPolicy {
+  id?: number
+  endpoint_id: number
+  referer_allowlist_enabled: boolean
+  referer_allowlist: string[]
+  allow_empty_referer: boolean
+  referer_fallback_url: string
+  require_api_key: boolean
+  require_signature: boolean
+  bot_mode: '' | 'off' | 'browser_only' | 'block_bots'
+  bot_allow_ua: string[]
+  bot_deny_ua: string[]
+  block_preview_bots: boolean
+  cors_mode: '' | 'enabled' | 'disabled'
+  cors_origins: string[]
+  cors_methods: string[]
+  cors_headers: string[]
+  cors_max_age: number
+  created_at?: string
+  updated_at?: string
+}
+
+export interface IPRule {
+  id: number
+  endpoint_id?: number
+  cidr: string
+  action: 'allow' | 'deny'
+  reason: string
+  is_active: boolean
+  created_at: string
+  updated_at: string
+  endpoint?: APIEndpoint
+}
+
+export interface RateLimitPolicy {
+  id: number
+  name: string
+  scope: 'global' | 'endpoint' | 'referer'
+  endpoint_id?: number
+  referer_pattern?: string
+  requests: number
+  window_seconds: number
+  burst: number
+  ipv6_prefix: number
+  shared: boolean
+  is_active: boolean
+  created_at: string
+  updated_at: string
+  endpoint?: APIEndpoint
+}
+
+export interface APIKey {
+  id: number
+  name: string
+  owner: string
+  prefix: string
+  allowed_endpoint_ids: number[] | null
+  rate_requests: number
+  rate_window_seconds: number
+  burst: number
+  unlimited: boolean
+  daily_quota: number
+  expires_at?: string
+  revoked: boolean
+  last_used_at?: string
+  created_at: string
+  updated_at: string
+}
+
+export interface DailyAPIKeyUsage {
+  api_key_id: number
+  path: string
+  date: string
+  count: number
+}
+
+export interface SignedLinkRequest {
+  endpoint_id: number
+  expires_in?: number
+  expires_at?: number
+  referer?: string
+  ip?: string
+}
+
+export interface SignedLink {
+  url: string
+  path: string
+  expires_at: string
+}
+
+export interface BotBlockStats {
+  path: string
+  reason: 'deny_ua' | 'not_browser' | 'bot' | 'preview_bot'
+  count: number
+}
+
+export type AdminRole = 'viewer' | 'editor' | 'admin'
+
+export interface AdminUser {
+  id: number
+  identifier: string
+  role: AdminRole
+  endpoint_ids: string
+  note: string
+  created_by: string
+  created_at: string
+  updated_at: string
+}
+
+export interface AdminUserList {
+  users: AdminUser[]
+  owner: string
+  env_users: string[]
+  open: boolean
+}
+
+export interface LocalAdmin {
+  id: number
+  username: string
+  role: AdminRole
+  endpoint_ids: string
+  totp_enabled: boolean
+  disabled: boolean
+  last_login_at?: string
   created_at: string
   updated_at: string
 }
+
+export interface AuditChange {
+  before?: unknown
+  after?: unknown
+}
+
+export interface AuditEvent {
+  id: number
+  actor: string
+  actor_id: string
+  action: string
+  target_type: string
+  target_id: string
+  changes: Record<string, AuditChange> | null
+  ip: string
+  created_at: string
+}
+
+export interface AuditEventList {
+  events: AuditEvent[]
+  total: number
+}
+
+export interface EndpointRevision {
+  id: number
+  endpoint_id: number
+  revision: number
+  action: string
+  actor: string
+  snapshot?: APIEndpoint
+  created_at: string
+}
+
+export interface RevisionItemDiff {
+  id: number
+  name: string
+  status: 'added' | 'removed' | 'changed'
+  changes: Record<string, AuditChange>
+}
+
+export interface RevisionDiff {
+  from: number
+  to: number
+  endpoint: Record<string, AuditChange>
+  data_sources: RevisionItemDiff[]
+  url_replace_rules: RevisionItemDiff[]
+}
+
+export interface ImportChange {
+  kind: 'endpoint' | 'data_source' | 'url_replace_rule' | 'blocked_domain' | 'config'
+  key: string
+  action: 'create' | 'update' | 'delete'
+  changes?: Record<string, AuditChange>
+}
+
+export interface ImportPlan {
+  mode: 'merge' | 'replace'
+  dry_run: boolean
+  applied: boolean
+  changes: ImportChange[]
+  warnings: string[]
+  errors: string[]
+  summary: {
+    create: number
+    update: number
+    delete: number
+    unchanged: number
+  }
+}
+
+export interface ConfigFileStatus {
+  enabled: boolean
+  path: string
+  drift_mode: 'reject' | 'flag'
+  last_reconciled_at: string | null
+  last_error: string
+  last_summary: ImportPlan['summary'] | null
+  drift: ImportPlan | null
+}